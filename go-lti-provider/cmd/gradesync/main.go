@@ -0,0 +1,113 @@
+// Command gradesync recomputes grades from the stored submissions and
+// re-posts the ones that differ from the platform's gradebook (AGS results).
+//
+// It reads the same environment as the tool (SUBMISSIONS_DIR, PROBLEMS_FILE,
+// PLATFORM_REGISTRY_FILE, LTI_CLIENT_SECRET, ...). Check first with a dry run:
+//
+//	gradesync -issuer https://moodle.example.edu -context 42 -resource-link 7 -dry-run
+//	gradesync -all -rate 30
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"go-lti-provider/config"
+	"go-lti-provider/logging"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+func main() {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatal("Failed to set up logging: ", err)
+	}
+
+	issuer := flag.String("issuer", "", "platform issuer (may be empty with a single registered platform)")
+	contextID := flag.String("context", "", "context (course) ID")
+	resourceLink := flag.String("resource-link", "", "resource link ID")
+	all := flag.Bool("all", false, "resync every resource link with stored submissions")
+	lineItem := flag.String("lineitem", "", "line item URL for attempts that did not record one")
+	dryRun := flag.Bool("dry-run", false, "only report the differences, do not save or send anything")
+	rate := flag.Int("rate", cfg.ResyncRateLimit, "grade posts per minute (0 = unlimited)")
+	by := flag.String("by", "gradesync", "name recorded in the audit log")
+	flag.Parse()
+
+	if !*all && (*contextID == "" || *resourceLink == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	platforms, err := services.NewPlatformRegistry(cfg.PlatformRegistryFile, services.PlatformsFromConfig(cfg)...)
+	if err != nil {
+		log.Fatal("Failed to load platform registry:", err)
+	}
+	problems, err := services.NewProblemStore(cfg.ProblemsFile, cfg.ProblemDirs...)
+	if err != nil {
+		log.Fatal("Failed to load problems:", err)
+	}
+	submissions, err := services.NewSubmissionStore(cfg.SubmissionsDir)
+	if err != nil {
+		log.Fatal("Failed to load submissions:", err)
+	}
+	audit, err := services.NewAuditLog(cfg.AuditLogFile)
+	if err != nil {
+		log.Fatal("Failed to load audit log:", err)
+	}
+	toolKey, err := services.LoadToolKey(cfg.ToolPrivateKeyFile)
+	if err != nil {
+		log.Fatal("Failed to load tool key:", err)
+	}
+
+	keys := []models.ResourceKey{{Issuer: *issuer, ContextID: *contextID, ResourceLinkID: *resourceLink}}
+	if *all {
+		keys = submissions.ResourceKeys()
+	} else if *issuer == "" {
+		// Attempts are stored under the platform's issuer
+		if platform, ok := platforms.Find("", ""); ok {
+			keys[0].Issuer = platform.Issuer
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	resync := services.NewGradeResync(submissions, problems, audit)
+	opts := services.ResyncOptions{DryRun: *dryRun, PerMinute: *rate, LineItem: *lineItem, By: *by}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	failed := false
+	for _, key := range keys {
+		platform, ok := platforms.Find(key.Issuer, "")
+		if !ok {
+			log.Printf("❌ No platform registered for issuer %q, skipping %s", key.Issuer, key.String())
+			failed = true
+			continue
+		}
+		ags := services.NewAGSService(platform.TokenURL, platform.ClientID, cfg.ClientSecret, toolKey)
+
+		report, err := resync.Run(ctx, ags, key, opts)
+		if err != nil {
+			log.Printf("❌ Resync of %s failed: %v", key.String(), err)
+			failed = true
+			continue
+		}
+		out.Encode(report)
+		if report.Counts[models.ResyncFailed] > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+// Command mockplatform runs a fake Moodle LTI 1.3 platform for local development.
+//
+// Point the tool at it with:
+//
+//	MOODLE_AUTH_URL=http://localhost:8888/mod/lti/auth.php
+//	MOODLE_TOKEN_URL=http://localhost:8888/mod/lti/token.php
+//	MOODLE_KEYSET_URL=http://localhost:8888/mod/lti/certs.php
+//	PLATFORM_ISSUER=http://localhost:8888
+//	LTI_CLIENT_ID=<client-id>
+//
+// and start a launch from http://localhost:8888/mod/lti/launch.php?roles=learner&lineitem=1
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"go-lti-provider/mockplatform"
+)
+
+func main() {
+	addr := flag.String("addr", ":8888", "listen address")
+	baseURL := flag.String("base-url", "http://localhost:8888", "public base URL of the platform")
+	issuer := flag.String("issuer", "", "platform issuer (defaults to base URL)")
+	clientID := flag.String("client-id", "mock-client-id", "client ID registered for the tool")
+	clientSecret := flag.String("client-secret", "", "client secret accepted by token.php (empty accepts any)")
+	deploymentID := flag.String("deployment-id", "1", "deployment ID")
+	toolLogin := flag.String("tool-login", "http://localhost:8080/lti/login", "tool OIDC login initiation URL")
+	toolLaunch := flag.String("tool-launch", "http://localhost:8080/lti/launch", "tool launch (redirect) URL")
+	flag.Parse()
+
+	platform, err := mockplatform.New(mockplatform.Config{
+		Issuer:        *issuer,
+		BaseURL:       *baseURL,
+		ClientID:      *clientID,
+		ClientSecret:  *clientSecret,
+		DeploymentID:  *deploymentID,
+		ToolLoginURL:  *toolLogin,
+		ToolLaunchURL: *toolLaunch,
+	})
+	if err != nil {
+		log.Fatal("Failed to create mock platform:", err)
+	}
+
+	cfg := platform.Config()
+	log.Printf("🧪 Mock LTI platform: %s (issuer %s, client_id %s)", cfg.BaseURL, cfg.Issuer, cfg.ClientID)
+	log.Printf("🔗 Start a launch: %s%s?roles=learner&lineitem=1", cfg.BaseURL, mockplatform.LaunchPath)
+
+	if err := http.ListenAndServe(*addr, platform.Handler()); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
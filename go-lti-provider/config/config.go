@@ -1,108 +1,381 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // LTI 1.3 Configuration
+//
+// Every field is read from a config file (YAML or TOML, see Load) and from
+// the environment variable in its env tag, which wins over the file. The key
+// in the file is the env name in lower case; nested sections are joined with
+// "_", so judge0: {url: ...} sets JUDGE0_URL. Lists are comma separated in
+// the environment and durations use Go syntax ("8h", "90s") or seconds.
 type Config struct {
 	// Server settings
-	Port string `env:"PORT" default:"8080"`
+	Port string `env:"PORT" default:"8080" required:"true" restart:"true"`
+
+	// HTTP server timeouts; WRITE_TIMEOUT must cover a graded submit
+	ReadTimeout  time.Duration `env:"READ_TIMEOUT" default:"15s" restart:"true"`
+	WriteTimeout time.Duration `env:"WRITE_TIMEOUT" default:"120s" restart:"true"`
+	IdleTimeout  time.Duration `env:"IDLE_TIMEOUT" default:"60s" restart:"true"`
+
+	// Graceful shutdown: readiness fails for DRAIN_DELAY before the server
+	// stops, then in-flight requests and background grading get SHUTDOWN_TIMEOUT
+	DrainDelay      time.Duration `env:"DRAIN_DELAY" default:"5s" restart:"true"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"60s" restart:"true"`
+
+	// Logging: LOG_LEVEL is debug, info, warn or error and applies on reload;
+	// LOG_FORMAT is text or json
+	LogLevel  string `env:"LOG_LEVEL" default:"info"`
+	LogFormat string `env:"LOG_FORMAT" default:"text" restart:"true"`
+
+	// Tracing: TRACING_EXPORTER is none or otlp (OTLP over HTTP to
+	// OTEL_EXPORTER_OTLP_ENDPOINT, default http://localhost:4318). Incoming
+	// trace context is passed on to Judge0 and the platform either way.
+	TracingExporter    string  `env:"TRACING_EXPORTER" default:"none" restart:"true"`
+	TracingEndpoint    string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT" restart:"true"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1" restart:"true"` // Tỉ lệ trace mới được ghi lại
+	TracingServiceName string  `env:"OTEL_SERVICE_NAME" default:"go-lti-provider" restart:"true"`
+
+	// Dependency health checks behind /healthz and /readyz: run every
+	// HEALTH_CHECK_INTERVAL, each given HEALTH_CHECK_TIMEOUT
+	HealthCheckInterval time.Duration `env:"HEALTH_CHECK_INTERVAL" default:"10s" restart:"true"`
+	HealthCheckTimeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT" default:"3s" restart:"true"`
+
+	// Config file reload: the file is checked this often, 0 = only on SIGHUP
+	ConfigPollInterval time.Duration `env:"CONFIG_POLL_INTERVAL" default:"5s" restart:"true"`
 
 	// LTI Platform (Moodle) settings
-	PlatformIssuer   string // Moodle's issuer URL
-	PlatformJWKSURL  string // Moodle's JWKS endpoint
-	PlatformTokenURL string // Moodle's OAuth2 token endpoint
-	PlatformAuthURL  string // Moodle's OIDC auth endpoint
+	PlatformIssuer   string `env:"PLATFORM_ISSUER" default:"http://localhost:8888"`                      // Moodle's issuer URL
+	PlatformJWKSURL  string `env:"PLATFORM_JWKS_URL" default:"http://localhost:8888/mod/lti/certs.php"`  // Moodle's JWKS endpoint
+	PlatformTokenURL string `env:"PLATFORM_TOKEN_URL" default:"http://localhost:8888/mod/lti/token.php"` // Moodle's OAuth2 token endpoint
+	PlatformAuthURL  string `env:"PLATFORM_AUTH_URL" default:"http://localhost:8888/mod/lti/auth.php"`   // Moodle's OIDC auth endpoint
+
+	// Platform JWKS cache: keys are kept for the platform's Cache-Control
+	// max-age but at least JWKS_MIN_REFRESH_INTERVAL. A launch signed with an
+	// unknown kid refreshes them, at most once per JWKS_KID_REFRESH_INTERVAL.
+	JWKSMinRefreshInterval time.Duration `env:"JWKS_MIN_REFRESH_INTERVAL" default:"5m" restart:"true"`
+	JWKSKidRefreshInterval time.Duration `env:"JWKS_KID_REFRESH_INTERVAL" default:"30s"`
 
 	// Tool settings
-	ClientID     string // LTI Tool Client ID trong Moodle
-	ClientSecret string // LTI Tool Client Secret (nếu cần)
-	ToolIssuer   string // Tool's issuer URL (localhost cho dev)
+	ClientID     string `env:"LTI_CLIENT_ID"`                                                // LTI Tool Client ID trong Moodle (để trống nếu dùng Dynamic Registration)
+	ClientSecret string `env:"LTI_CLIENT_SECRET" default:"your-client-secret" secret:"true"` // LTI Tool Client Secret (nếu cần)
+	ToolIssuer   string `env:"TOOL_ISSUER" default:"http://localhost:8080" required:"true"`  // Tool's issuer URL (localhost cho dev)
+	ToolName     string `env:"TOOL_NAME" default:"Code Runner"`                              // Tên tool hiển thị khi đăng ký với platform
+
+	// Tool signing key (PEM): published at /.well-known/jwks.json and used to
+	// sign the client assertions of AGS/NRPS token requests. Generated at the
+	// first start when the file does not exist.
+	ToolPrivateKeyFile string `env:"TOOL_PRIVATE_KEY_FILE" default:"data/tool_key.pem" restart:"true"`
+
+	// Platform registry (LTI Dynamic Registration)
+	PlatformRegistryFile string `env:"PLATFORM_REGISTRY_FILE" default:"data/platforms.json" restart:"true"`
+
+	// Who may use /lti/register: platforms on REGISTRATION_ALLOWED_HOSTS
+	// (exact hosts or *.example.edu), or anyone opening the registration URL
+	// with ?token=REGISTRATION_TOKEN. With neither, registration is disabled.
+	RegistrationAllowedHosts []string `env:"REGISTRATION_ALLOWED_HOSTS"`
+	RegistrationToken        string   `env:"REGISTRATION_TOKEN" secret:"true"`
+
+	// Platforms registered in the config file, next to the one above
+	Platforms []PlatformConfig `file:"platforms"`
 
 	// Deployment settings
-	DeploymentID string // LTI Deployment ID
+	DeploymentID string `env:"LTI_DEPLOYMENT_ID" default:"1"` // LTI Deployment ID
 
 	// AGS settings
-	AGSScope string // Assignment and Grade Services scope
+	AGSScope string `env:"AGS_SCOPE" default:"https://purl.imsglobal.org/spec/lti-ags/scope/score"` // Assignment and Grade Services scope
 
 	// Judge0 settings
-	Judge0URL       string
-	Judge0AuthToken string // Nếu Judge0 có authentication
+	Languages       map[string]int `file:"languages"` // Thêm/ghi đè language -> Judge0 language ID
+	DefaultLanguage string         `env:"DEFAULT_LANGUAGE" default:"go"`
+	Judge0URL       string         `env:"JUDGE0_URL" default:"http://localhost:2358" required:"true"`
+	Judge0AuthToken string         `env:"JUDGE0_AUTH_TOKEN" secret:"true"` // Nếu Judge0 có authentication
+	Judge0Timeout   time.Duration  `env:"JUDGE0_TIMEOUT" default:"30s"`    // Thời gian chờ mỗi lần chạy
+	Judge0MaxQueue  int            `env:"JUDGE0_MAX_QUEUE" default:"50"`   // Health check cảnh báo khi hàng đợi Judge0 dài hơn (0 = tắt)
+
+	// Judge0 protection: new runs are refused while Judge0's queue is longer
+	// than JUDGE0_ADMISSION_QUEUE, and Judge0 is not called for
+	// JUDGE0_BREAKER_COOLDOWN after JUDGE0_BREAKER_FAILURES failed runs in a row (0 = off)
+	Judge0AdmissionQueue  int           `env:"JUDGE0_ADMISSION_QUEUE" default:"200"`
+	Judge0BreakerFailures int           `env:"JUDGE0_BREAKER_FAILURES" default:"5"`
+	Judge0BreakerCooldown time.Duration `env:"JUDGE0_BREAKER_COOLDOWN" default:"30s"`
 
 	// Security settings
-	JWTSigningMethod string
-	AllowedOrigins   []string
+	JWTSigningMethod string `env:"JWT_SIGNING_METHOD" default:"RS256"`
+
+	// CORS for /api: origins the frontend is served from, exact or with a
+	// wildcard subdomain (https://*.moodle.example.edu). Empty = FRONTEND_URL's
+	// origin; "*" allows any origin but never with credentials.
+	AllowedOrigins       []string      `env:"ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,X-Request-ID,traceparent"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS"` // Chỉ cần khi frontend gửi cookie; session token đi trong Authorization
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m"`
+
+	// Launch sessions
+	SessionSecret string        `env:"SESSION_SECRET" secret:"true" restart:"true"`      // Khóa ký session token (để trống = random mỗi lần khởi động)
+	SessionTTL    time.Duration `env:"SESSION_TTL" default:"8h" restart:"true"`          // Thời hạn của session token
+	TAPermissions []string      `env:"TA_PERMISSIONS" default:"submit,view_submissions"` // Quyền của Teaching Assistant
+
+	// Rate limits (số request mỗi phút cho mỗi user, 0 = không giới hạn)
+	RunRateLimit    int `env:"RUN_RATE_LIMIT" default:"20" restart:"true"`
+	SubmitRateLimit int `env:"SUBMIT_RATE_LIMIT" default:"5" restart:"true"`
+	ResyncRateLimit int `env:"RESYNC_RATE_LIMIT" default:"60"` // Số điểm gửi lại mỗi phút khi resync
+
+	// Runs and submits per minute for a whole course and for the whole tool
+	// (0 = không giới hạn), runs in progress per user, and the largest source accepted
+	ContextRateLimit int `env:"CONTEXT_RATE_LIMIT" default:"300" restart:"true"`
+	GlobalRateLimit  int `env:"GLOBAL_RATE_LIMIT" default:"1200" restart:"true"`
+	UserConcurrency  int `env:"USER_CONCURRENCY" default:"2"`
+	MaxSourceBytes   int `env:"MAX_SOURCE_BYTES" default:"65536"`
+
+	// Số bài chấm lại song song trên Judge0
+	RegradeConcurrency int `env:"REGRADE_CONCURRENCY" default:"4" restart:"true"`
+
+	// Health check cảnh báo khi số bài chấm/gửi điểm đang chờ nhiều hơn (0 = tắt)
+	GradingMaxBacklog int `env:"GRADING_MAX_BACKLOG" default:"100"`
+
+	// Storage
+	ProblemsFile   string   `env:"PROBLEMS_FILE" default:"data/problems.json" restart:"true"`
+	ProblemDirs    []string `env:"PROBLEM_DIRS" restart:"true"`                               // Thư mục chứa đề bài có sẵn (một file JSON mỗi resource link)
+	SubmissionsDir string   `env:"SUBMISSIONS_DIR" default:"data/submissions" restart:"true"` // Thư mục lưu lịch sử bài nộp
+	AuditLogFile   string   `env:"AUDIT_LOG_FILE" default:"data/audit.log" restart:"true"`    // Nhật ký thay đổi điểm (JSON lines)
+	ThemesFile     string   `env:"THEMES_FILE" default:"data/themes.json" restart:"true"`     // Giao diện các trang server-rendered theo platform
 
 	// Moodle settings
 	MoodleBaseURL  string `env:"MOODLE_BASE_URL" default:"http://localhost:8888"`
 	AuthLoginURL   string `env:"MOODLE_AUTH_URL" default:"http://localhost:8888/mod/lti/auth.php"`
 	TokenEndpoint  string `env:"MOODLE_TOKEN_URL" default:"http://localhost:8888/mod/lti/token.php"`
 	KeysetEndpoint string `env:"MOODLE_KEYSET_URL" default:"http://localhost:8888/mod/lti/certs.php"`
-	FrontendURL    string `env:"FRONTEND_URL" default:"http://localhost:3000"`
+	FrontendURL    string `env:"FRONTEND_URL" default:"http://localhost:3000" required:"true"`
+
+	// Frontend routes cho từng experience
+	FrontendInstructorPath string `env:"FRONTEND_INSTRUCTOR_PATH" default:"/instructor"`
+	FrontendLearnerPath    string `env:"FRONTEND_LEARNER_PATH" default:"/"`
+	FrontendReviewPath     string `env:"FRONTEND_REVIEW_PATH" default:"/review"` // Submission review mở từ gradebook
 }
 
-// LoadConfig loads configuration from environment variables với default values
+// PlatformConfig is a platform registration in the config file
+type PlatformConfig struct {
+	Issuer        string   `yaml:"issuer" toml:"issuer" json:"issuer"`
+	ClientID      string   `yaml:"client_id" toml:"client_id" json:"client_id"`
+	DeploymentIDs []string `yaml:"deployment_ids,omitempty" toml:"deployment_ids" json:"deployment_ids,omitempty"`
+	AuthLoginURL  string   `yaml:"auth_login_url" toml:"auth_login_url" json:"auth_login_url"`
+	TokenURL      string   `yaml:"token_url" toml:"token_url" json:"token_url"`
+	JWKSURL       string   `yaml:"jwks_url" toml:"jwks_url" json:"jwks_url"`
+	ProductFamily string   `yaml:"product_family,omitempty" toml:"product_family" json:"product_family,omitempty"`
+}
+
+var current atomic.Pointer[Config]
+
+// LoadConfig returns the configuration in use. Commands load it at startup
+// with Load and Use, and exit when it is invalid. Without that it is loaded
+// the same way from CONFIG_FILE and the environment on first use; a missing
+// or invalid config then panics rather than running with empty settings.
 func LoadConfig() *Config {
-	return &Config{
-		// Server settings
-		Port: getEnv("PORT", "8080"),
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	cfg, err := Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		panic(fmt.Sprintf("config: %v", err))
+	}
+	current.CompareAndSwap(nil, cfg)
+	return current.Load()
+}
 
-		// Platform settings cho Moodle local
-		PlatformIssuer:   getEnv("PLATFORM_ISSUER", "http://localhost:8888"),
-		PlatformJWKSURL:  getEnv("PLATFORM_JWKS_URL", "http://localhost:8888/mod/lti/certs.php"),
-		PlatformTokenURL: getEnv("PLATFORM_TOKEN_URL", "http://localhost:8888/mod/lti/token.php"),
-		PlatformAuthURL:  getEnv("PLATFORM_AUTH_URL", "http://localhost:8888/mod/lti/auth.php"),
+// Use makes cfg the configuration returned by LoadConfig
+func Use(cfg *Config) {
+	current.Store(cfg)
+}
 
-		// Tool settings
-		ClientID:     getEnv("LTI_CLIENT_ID", "wAWXk7ifY0o9tCU"),
-		ClientSecret: getEnv("LTI_CLIENT_SECRET", "your-client-secret"),
-		ToolIssuer:   getEnv("TOOL_ISSUER", "http://localhost:8080"),
+// Validate kiểm tra cấu hình có hợp lệ không
+func (c *Config) Validate() error {
+	var errs []error
 
-		// Deployment
-		DeploymentID: getEnv("LTI_DEPLOYMENT_ID", "1"),
+	for _, setting := range []struct{ name, value string }{
+		{"TOOL_ISSUER", c.ToolIssuer},
+		{"JUDGE0_URL", c.Judge0URL},
+		{"FRONTEND_URL", c.FrontendURL},
+		{"PLATFORM_ISSUER", c.PlatformIssuer},
+		{"MOODLE_AUTH_URL", c.AuthLoginURL},
+		{"MOODLE_TOKEN_URL", c.TokenEndpoint},
+		{"MOODLE_KEYSET_URL", c.KeysetEndpoint},
+	} {
+		if err := checkURL(setting.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", setting.name, err))
+		}
+	}
 
-		// AGS
-		AGSScope: getEnv("AGS_SCOPE", "https://purl.imsglobal.org/spec/lti-ags/scope/score"),
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port", c.Port))
+	}
+	if c.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("SESSION_TTL must be positive"))
+	}
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("READ_TIMEOUT, WRITE_TIMEOUT, IDLE_TIMEOUT and SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.DrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("DRAIN_DELAY must not be negative"))
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: %q is not one of debug, info, warn, error", c.LogLevel))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT: %q is not text or json", c.LogFormat))
+	}
+	if c.TracingExporter != "none" && c.TracingExporter != "otlp" {
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER: %q is not none or otlp", c.TracingExporter))
+	}
+	if c.TracingEndpoint != "" {
+		if err := checkURL(c.TracingEndpoint); err != nil {
+			errs = append(errs, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT: %w", err))
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	if c.HealthCheckInterval <= 0 || c.HealthCheckTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("HEALTH_CHECK_INTERVAL and HEALTH_CHECK_TIMEOUT must be positive"))
+	}
+	if c.Judge0MaxQueue < 0 || c.GradingMaxBacklog < 0 {
+		errs = append(errs, fmt.Errorf("JUDGE0_MAX_QUEUE and GRADING_MAX_BACKLOG must not be negative"))
+	}
+	if c.ConfigPollInterval < 0 {
+		errs = append(errs, fmt.Errorf("CONFIG_POLL_INTERVAL must not be negative"))
+	}
+	if c.JWKSMinRefreshInterval <= 0 || c.JWKSKidRefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("JWKS_MIN_REFRESH_INTERVAL must be positive and JWKS_KID_REFRESH_INTERVAL not negative"))
+	}
+	if c.Judge0Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("JUDGE0_TIMEOUT must be positive"))
+	}
+	if c.RunRateLimit < 0 || c.SubmitRateLimit < 0 || c.ResyncRateLimit < 0 || c.ContextRateLimit < 0 || c.GlobalRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate limits must not be negative"))
+	}
+	if c.UserConcurrency < 0 || c.Judge0AdmissionQueue < 0 || c.Judge0BreakerFailures < 0 {
+		errs = append(errs, fmt.Errorf("USER_CONCURRENCY, JUDGE0_ADMISSION_QUEUE and JUDGE0_BREAKER_FAILURES must not be negative"))
+	}
+	if c.MaxSourceBytes <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_SOURCE_BYTES must be positive"))
+	}
+	if c.Judge0BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("JUDGE0_BREAKER_COOLDOWN must be positive"))
+	}
+	if c.RegradeConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("REGRADE_CONCURRENCY must be at least 1"))
+	}
 
-		// Judge0
-		Judge0URL:       getEnv("JUDGE0_URL", "http://localhost:2358"),
-		Judge0AuthToken: getEnv("JUDGE0_AUTH_TOKEN", ""),
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				errs = append(errs, fmt.Errorf("ALLOWED_ORIGINS: \"*\" cannot be used with CORS_ALLOW_CREDENTIALS"))
+			}
+			continue
+		}
+		if err := checkOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("ALLOWED_ORIGINS: %w", err))
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("CORS_MAX_AGE must not be negative"))
+	}
 
-		// Security
-		JWTSigningMethod: getEnv("JWT_SIGNING_METHOD", "RS256"),
-		AllowedOrigins:   []string{getEnv("ALLOWED_ORIGINS", "*")},
+	for i, p := range c.Platforms {
+		if p.Issuer == "" || p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("platforms[%d]: issuer and client_id are required", i))
+			continue
+		}
+		for _, raw := range []string{p.AuthLoginURL, p.TokenURL, p.JWKSURL} {
+			if err := checkURL(raw); err != nil {
+				errs = append(errs, fmt.Errorf("platforms[%d] (%s): %w", i, p.Issuer, err))
+			}
+		}
+	}
 
-		// Moodle settings
-		MoodleBaseURL:  getEnv("MOODLE_BASE_URL", "http://localhost:8888"),
-		AuthLoginURL:   getEnv("MOODLE_AUTH_URL", "http://localhost:8888/mod/lti/auth.php"),
-		TokenEndpoint:  getEnv("MOODLE_TOKEN_URL", "http://localhost:8888/mod/lti/token.php"),
-		KeysetEndpoint: getEnv("MOODLE_KEYSET_URL", "http://localhost:8888/mod/lti/certs.php"),
-		FrontendURL:    getEnv("FRONTEND_URL", "http://localhost:3000"),
+	if _, ok := c.languageID(c.DefaultLanguage); !ok {
+		errs = append(errs, fmt.Errorf("DEFAULT_LANGUAGE: unknown language %q", c.DefaultLanguage))
+	}
+	for name, id := range c.Languages {
+		if id <= 0 {
+			errs = append(errs, fmt.Errorf("languages: %s has invalid Judge0 ID %d", name, id))
+		}
 	}
-}
 
-// getEnv gets environment variable với fallback default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	for _, dir := range c.ProblemDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("PROBLEM_DIRS: %s is not a directory", dir))
+		}
 	}
-	return defaultValue
+
+	return errors.Join(errs...)
 }
 
-// Validate kiểm tra cấu hình có hợp lệ không
-func (c *Config) Validate() []string {
-	var errors []string
+// Warnings lists settings that are valid but probably not what production wants
+func (c *Config) Warnings() []string {
+	var warnings []string
+
+	if c.ClientID == "" && c.PlatformRegistryFile == "" && len(c.Platforms) == 0 {
+		warnings = append(warnings, "⚠️ No platform configured - set LTI_CLIENT_ID, platforms or PLATFORM_REGISTRY_FILE")
+	}
+
+	if c.SessionSecret == "" {
+		warnings = append(warnings, "⚠️ SESSION_SECRET not set - launch sessions will not survive a restart")
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			warnings = append(warnings, "⚠️ ALLOWED_ORIGINS is \"*\" - any website can call the API with a learner's session token")
+			break
+		}
+	}
+
+	return warnings
+}
 
-	if c.PlatformIssuer == "http://localhost:8080" && c.ClientID == "wAWXk7ifY0o9tCU" {
-		errors = append(errors, "⚠️ Using default values - configure LTI_CLIENT_ID for production")
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%q is not an http(s) URL", raw)
 	}
+	return nil
+}
 
-	if c.Port == "" {
-		errors = append(errors, "PORT không được để trống")
+// checkOrigin accepts scheme://host[:port], where the host may start with a
+// "*." wildcard for its subdomains
+func checkOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%q is not an origin (scheme://host[:port])", origin)
+	}
+	if host := strings.TrimPrefix(u.Hostname(), "*."); host == "" || strings.Contains(host, "*") {
+		return fmt.Errorf("%q: only a leading *. wildcard is supported", origin)
 	}
+	return nil
+}
 
-	return errors
+// GetCORSOrigins returns the origins allowed to call the API: ALLOWED_ORIGINS,
+// or the frontend's origin when it is not set
+func (c *Config) GetCORSOrigins() []string {
+	if len(c.AllowedOrigins) > 0 {
+		return c.AllowedOrigins
+	}
+	if u, err := url.Parse(c.FrontendURL); err == nil && u.Host != "" {
+		return []string{u.Scheme + "://" + u.Host}
+	}
+	return nil
 }
 
 // GetJudge0SubmissionURL returns full Judge0 submission URL
@@ -122,10 +395,15 @@ func (c *Config) GetToolLaunchURL() string {
 
 // GetToolJWKSURL returns tool's JWKS endpoint URL (nếu tool cũng cần serve JWKS)
 func (c *Config) GetToolJWKSURL() string {
-	return c.ToolIssuer + "/lti/jwks"
+	return c.ToolIssuer + "/.well-known/jwks.json"
 }
 
-// Language mappings cho Judge0
+// GetToolRegistrationURL returns tool's LTI Dynamic Registration endpoint URL
+func (c *Config) GetToolRegistrationURL() string {
+	return c.ToolIssuer + "/lti/register"
+}
+
+// Language mappings cho Judge0; the languages setting adds to and overrides them
 var LanguageMap = map[string]int{
 	"go":         75, // Go
 	"python":     71, // Python 3
@@ -139,10 +417,28 @@ var LanguageMap = map[string]int{
 	"swift":      83, // Swift
 }
 
-// GetLanguageID returns Judge0 language ID from language name
+// GetLanguageID returns Judge0 language ID from language name, using the
+// configuration in use
 func GetLanguageID(language string) int {
-	if id, exists := LanguageMap[language]; exists {
+	return LoadConfig().LanguageID(language)
+}
+
+// LanguageID returns the Judge0 language ID of a language, or the ID of the
+// default language when it is unknown
+func (c *Config) LanguageID(language string) int {
+	if id, ok := c.languageID(language); ok {
+		return id
+	}
+	if id, ok := c.languageID(c.DefaultLanguage); ok {
 		return id
 	}
 	return 75 // Default to Go
 }
+
+func (c *Config) languageID(language string) (int, bool) {
+	if id, ok := c.Languages[language]; ok {
+		return id, true
+	}
+	id, ok := LanguageMap[language]
+	return id, ok
+}
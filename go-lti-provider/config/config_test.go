@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// defaults loads the configuration without a file, as from an empty environment
+func defaults(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() of the defaults = %v", err)
+	}
+	return cfg
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg := defaults(t)
+	if cfg.Port != "8080" || cfg.SessionTTL != 8*time.Hour || cfg.ToolIssuer == "" {
+		t.Errorf("defaults = port %q, session TTL %s, tool issuer %q", cfg.Port, cfg.SessionTTL, cfg.ToolIssuer)
+	}
+	if len(cfg.AllowedOrigins) != 0 {
+		t.Errorf("AllowedOrigins = %v, want none (the frontend's origin)", cfg.AllowedOrigins)
+	}
+	if got := cfg.GetCORSOrigins(); !reflect.DeepEqual(got, []string{"http://localhost:3000"}) {
+		t.Errorf("GetCORSOrigins() = %v", got)
+	}
+}
+
+func TestLoadFileAndEnvironment(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+port: 9000
+log_level: debug
+judge0:
+  url: http://judge0.internal:2358
+allowed_origins:
+  - https://app.example.edu
+  - https://*.moodle.example.edu
+platforms:
+  - issuer: https://lms.example.edu
+    client_id: tool
+    auth_login_url: https://lms.example.edu/auth
+    token_url: https://lms.example.edu/token
+    jwks_url: https://lms.example.edu/jwks
+`)
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if cfg.Port != "9100" {
+		t.Errorf("Port = %q, want the environment to override the file", cfg.Port)
+	}
+	if cfg.LogLevel != "debug" || cfg.Judge0URL != "http://judge0.internal:2358" {
+		t.Errorf("LogLevel, Judge0URL = %q, %q", cfg.LogLevel, cfg.Judge0URL)
+	}
+	if want := []string{"https://app.example.edu", "https://*.moodle.example.edu"}; !reflect.DeepEqual(cfg.AllowedOrigins, want) {
+		t.Errorf("AllowedOrigins = %v, want %v", cfg.AllowedOrigins, want)
+	}
+	if len(cfg.Platforms) != 1 || cfg.Platforms[0].ClientID != "tool" {
+		t.Errorf("Platforms = %+v", cfg.Platforms)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+		want string
+	}{
+		{"missing file", func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing.yaml") }, "failed to read config file"},
+		{"unknown format", func(t *testing.T) string { return writeFile(t, "config.json", "{}") }, "must be .yaml"},
+		{"invalid YAML", func(t *testing.T) string { return writeFile(t, "config.yaml", "port: [") }, "failed to parse"},
+		{"invalid value", func(t *testing.T) string { return writeFile(t, "config.yaml", "session:\n  ttl: forever\n") }, "is not a duration"},
+		{"invalid setting", func(t *testing.T) string { return writeFile(t, "config.yaml", "port: 70000\n") }, "PORT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.path(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() = %v, want an error about %s", err, tt.want)
+			}
+			if cfg != nil {
+				t.Errorf("Load() returned a config with its error")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFailsOnInvalidFile(t *testing.T) {
+	previous := current.Load()
+	current.Store(nil)
+	t.Cleanup(func() { current.Store(previous) })
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("LoadConfig() returned a config for a missing CONFIG_FILE")
+		}
+	}()
+	LoadConfig()
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string // "" = valid
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"bad tool issuer", func(c *Config) { c.ToolIssuer = "localhost:8080" }, "TOOL_ISSUER"},
+		{"bad port", func(c *Config) { c.Port = "http" }, "PORT"},
+		{"zero session TTL", func(c *Config) { c.SessionTTL = 0 }, "SESSION_TTL"},
+		{"bad log level", func(c *Config) { c.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"sample ratio above 1", func(c *Config) { c.TracingSampleRatio = 1.5 }, "TRACING_SAMPLE_RATIO"},
+		{"negative rate limit", func(c *Config) { c.SubmitRateLimit = -1 }, "rate limits"},
+		{"zero source limit", func(c *Config) { c.MaxSourceBytes = 0 }, "MAX_SOURCE_BYTES"},
+		{"no regrade workers", func(c *Config) { c.RegradeConcurrency = 0 }, "REGRADE_CONCURRENCY"},
+		{"any origin", func(c *Config) { c.AllowedOrigins = []string{"*"} }, ""},
+		{"any origin with credentials", func(c *Config) {
+			c.AllowedOrigins = []string{"*"}
+			c.CORSAllowCredentials = true
+		}, "cannot be used with CORS_ALLOW_CREDENTIALS"},
+		{"exact origin with credentials", func(c *Config) {
+			c.AllowedOrigins = []string{"https://app.example.edu"}
+			c.CORSAllowCredentials = true
+		}, ""},
+		{"subdomain wildcard", func(c *Config) { c.AllowedOrigins = []string{"https://*.example.edu:8443"} }, ""},
+		{"origin with a path", func(c *Config) { c.AllowedOrigins = []string{"https://app.example.edu/"} }, "is not an origin"},
+		{"origin without scheme", func(c *Config) { c.AllowedOrigins = []string{"app.example.edu"} }, "is not an origin"},
+		{"wildcard inside the host", func(c *Config) { c.AllowedOrigins = []string{"https://app.*.example.edu"} }, "only a leading"},
+		{"bare wildcard host", func(c *Config) { c.AllowedOrigins = []string{"https://*."} }, "only a leading"},
+		{"negative CORS max age", func(c *Config) { c.CORSMaxAge = -time.Second }, "CORS_MAX_AGE"},
+		{"platform without client", func(c *Config) {
+			c.Platforms = []PlatformConfig{{Issuer: "https://lms.example.edu"}}
+		}, "issuer and client_id are required"},
+		{"platform with a bad URL", func(c *Config) {
+			c.Platforms = []PlatformConfig{{Issuer: "https://lms.example.edu", ClientID: "tool",
+				AuthLoginURL: "https://lms.example.edu/auth", TokenURL: "/token", JWKSURL: "https://lms.example.edu/jwks"}}
+		}, "platforms[0]"},
+		{"unknown default language", func(c *Config) { c.DefaultLanguage = "cobol" }, "DEFAULT_LANGUAGE"},
+		{"problem dir that does not exist", func(c *Config) { c.ProblemDirs = []string{"/does/not/exist"} }, "PROBLEM_DIRS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults(t)
+			tt.change(cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want valid", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
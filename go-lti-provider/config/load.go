@@ -0,0 +1,257 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration from a YAML (.yaml, .yml) or TOML (.toml)
+// file, lets environment variables override it and fills in defaults. An
+// empty path reads the environment only. Missing required values, values
+// that do not parse and unknown keys in the file fail the load, as does
+// Validate.
+func Load(path string) (*Config, error) {
+	cfg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// load fills in as much of the configuration as it can and reports every
+// problem it found on the way
+func load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	values, file, err := readFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Platforms = file.Platforms
+	cfg.Languages = file.Languages
+
+	var errs []error
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		env := field.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		key := strings.ToLower(env)
+
+		var raw interface{}
+		source := ""
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			raw, source = value, "environment variable "+env
+		} else if value, ok := values[key]; ok {
+			raw, source = value, "key "+key+" in "+path
+		} else if def, ok := field.Tag.Lookup("default"); ok {
+			raw, source = def, "default of "+env
+		}
+		delete(values, key)
+
+		if raw != nil {
+			if err := setField(v.Field(i), raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", source, err))
+				continue
+			}
+		}
+		if field.Tag.Get("required") == "true" && v.Field(i).IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required", env))
+		}
+	}
+
+	// Keys left over are typos or settings this version does not have
+	unknown := make([]string, 0, len(values))
+	for key := range values {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("unknown key %q in %s", key, path))
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// fileOnly holds the settings that only a config file can give
+type fileOnly struct {
+	Platforms []PlatformConfig `yaml:"platforms" toml:"platforms"`
+	Languages map[string]int   `yaml:"languages" toml:"languages"`
+}
+
+// readFile decodes the config file into flat lower-case keys, plus the
+// settings that have no environment variable
+func readFile(path string) (map[string]interface{}, fileOnly, error) {
+	values := make(map[string]interface{})
+	var file fileOnly
+	if path == "" {
+		return values, file, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, file, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+		if err == nil {
+			err = yaml.Unmarshal(data, &file)
+		}
+	case ".toml":
+		_, err = toml.Decode(string(data), &doc)
+		if err == nil {
+			_, err = toml.NewDecoder(bytes.NewReader(data)).Decode(&file)
+		}
+	default:
+		return nil, file, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, file, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for key, value := range doc {
+		if strings.EqualFold(key, "platforms") || strings.EqualFold(key, "languages") {
+			continue
+		}
+		flatten(values, strings.ToLower(key), value)
+	}
+	return values, file, nil
+}
+
+// flatten turns nested sections into "section_key" entries
+func flatten(values map[string]interface{}, prefix string, value interface{}) {
+	section, ok := value.(map[string]interface{})
+	if !ok {
+		values[prefix] = value
+		return
+	}
+	for key, v := range section {
+		flatten(values, prefix+"_"+strings.ToLower(key), v)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField sets a field from an environment string or a decoded file value
+func setField(field reflect.Value, raw interface{}) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+		var list []string
+		switch values := raw.(type) {
+		case []interface{}:
+			for _, v := range values {
+				list = append(list, strings.TrimSpace(fmt.Sprint(v)))
+			}
+		default:
+			for _, v := range strings.Split(fmt.Sprint(raw), ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					list = append(list, v)
+				}
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+		return nil
+	}
+
+	switch raw.(type) {
+	case []interface{}, map[string]interface{}:
+		return fmt.Errorf("expected a single value, got %T", raw)
+	}
+	s := strings.TrimSpace(fmt.Sprint(raw))
+
+	switch {
+	case field.Type() == durationType:
+		d, err := parseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(s)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// parseDuration accepts a Go duration ("8h", "90s") or a number of seconds
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return 0, fmt.Errorf("%q is not a duration", s)
+}
+
+// Redacted returns the effective configuration as YAML, with secrets masked
+func (c *Config) Redacted() string {
+	values := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.ToLower(field.Tag.Get("env"))
+		if key == "" {
+			key = field.Tag.Get("file")
+		}
+		if key == "" {
+			continue
+		}
+
+		value := v.Field(i).Interface()
+		switch {
+		case field.Tag.Get("secret") == "true":
+			if !v.Field(i).IsZero() {
+				value = "********"
+			}
+		case field.Type == durationType:
+			value = value.(time.Duration).String()
+		}
+		values[key] = value
+	}
+
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Sprintf("# failed to print configuration: %v\n", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reloader reloads the config file on SIGHUP and when the file changes. A
+// new config is validated before it replaces the one in use; requests that
+// already read the old config finish with it. Settings tagged restart keep
+// their startup values until the process restarts.
+type Reloader struct {
+	path string
+
+	mu      sync.Mutex
+	hooks   []func(*Config)
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader creates a Reloader for the config file at path
+func NewReloader(path string) *Reloader {
+	r := &Reloader{path: path}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime, r.size = info.ModTime(), info.Size()
+	}
+	return r
+}
+
+// OnReload registers fn to run with every config that replaces the current one
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, fn)
+}
+
+// Reload loads and validates the config file and, when it is valid, makes it
+// the config in use. An invalid file leaves the current config in place.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := Load(r.path)
+	if err != nil {
+		slog.Error("❌ Config reload rejected, keeping the current config", "file", r.path, "error", err)
+		return err
+	}
+
+	old := LoadConfig()
+	changed, restart := diff(old, cfg)
+	if len(changed) == 0 && len(restart) == 0 {
+		slog.Info("🔄 Config reloaded: no changes", "file", r.path)
+		return nil
+	}
+
+	// Settings read only at startup keep the values the process runs with
+	keepRestartSettings(old, cfg)
+	Use(cfg)
+	for _, hook := range r.hooks {
+		hook(cfg)
+	}
+
+	if len(changed) > 0 {
+		slog.Info("🔄 Config reloaded", "file", r.path, "changed", strings.Join(changed, ", "))
+	}
+	if len(restart) > 0 {
+		slog.Warn("⚠️ Config changes that need a restart to apply, keeping the current values", "settings", strings.Join(restart, ", "))
+	}
+	return nil
+}
+
+// Run reloads on SIGHUP and, with a positive interval, when the file's size
+// or modification time changes. It returns when ctx is done.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("📨 SIGHUP received, reloading", "file", r.path)
+			r.Reload()
+		case <-tick:
+			if r.fileChanged() {
+				r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) fileChanged() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true
+}
+
+// diff lists the settings that differ between two configs, split into the
+// ones applied live and the ones (restart tag) read only at startup
+func diff(old, cfg *Config) (changed, restart []string) {
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(cfg).Elem()
+	t := nv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+
+		field := t.Field(i)
+		name := strings.ToLower(field.Tag.Get("env"))
+		if name == "" {
+			name = field.Tag.Get("file")
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if field.Tag.Get("restart") == "true" {
+			restart = append(restart, name)
+		} else {
+			changed = append(changed, name)
+		}
+	}
+	return changed, restart
+}
+
+// keepRestartSettings copies the settings tagged restart from old into cfg
+func keepRestartSettings(old, cfg *Config) {
+	ov := reflect.ValueOf(old).Elem()
+	nv := reflect.ValueOf(cfg).Elem()
+	t := nv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("restart") == "true" {
+			nv.Field(i).Set(ov.Field(i))
+		}
+	}
+}
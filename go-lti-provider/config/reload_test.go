@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	previous := current.Load()
+	t.Cleanup(func() { current.Store(previous) })
+
+	path := writeFile(t, "config.yaml", "port: 8080\nlog_level: info\nsubmissions_dir: data/submissions\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Use(cfg)
+
+	var hooked *Config
+	r := NewReloader(path)
+	r.OnReload(func(cfg *Config) { hooked = cfg })
+
+	// Live settings change; restart settings keep the values in use
+	if err := os.WriteFile(path, []byte("port: 9090\nlog_level: debug\nsubmissions_dir: /srv/submissions\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload() = %v", err)
+	}
+
+	got := LoadConfig()
+	if got.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want the reloaded debug", got.LogLevel)
+	}
+	if got.Port != "8080" || got.SubmissionsDir != "data/submissions" {
+		t.Errorf("Port, SubmissionsDir = %q, %q; want the startup values until a restart", got.Port, got.SubmissionsDir)
+	}
+	if hooked != got {
+		t.Error("OnReload hook did not get the config in use")
+	}
+
+	// An invalid file leaves the config in use
+	if err := os.WriteFile(path, []byte("log_level: loud\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload() of an invalid file succeeded")
+	}
+	if LoadConfig() != got {
+		t.Error("invalid reload replaced the config in use")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Config{Port: "8080", LogLevel: "info", AllowedOrigins: []string{"https://a.example.edu"}}
+	cfg := &Config{Port: "9090", LogLevel: "info", AllowedOrigins: []string{"https://b.example.edu"}}
+
+	changed, restart := diff(old, cfg)
+	if len(changed) != 1 || changed[0] != "allowed_origins" {
+		t.Errorf("changed = %v, want [allowed_origins]", changed)
+	}
+	if len(restart) != 1 || restart[0] != "port" {
+		t.Errorf("restart = %v, want [port]", restart)
+	}
+}
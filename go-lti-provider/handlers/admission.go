@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/services"
+)
+
+// stdinAllowance is how much of a run request may be stdin and JSON around
+// the source
+const stdinAllowance = 64 << 10
+
+type executionSlotKey struct{}
+
+// executionSlot is the admission of one request, released when the
+// handler returns unless the handler kept it for work it left running
+type executionSlot struct {
+	release func()
+	kept    bool
+}
+
+// AdmitExecution refuses runs and submits Judge0 cannot take now (see
+// services.Admission) with 429, or 503 while Judge0 is failing, and a
+// Retry-After. It also caps the request body. Must run after WithSession.
+func AdmitExecution(admission *services.Admission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, course := clientIP(r), ""
+			if session := sessionFromContext(r.Context()); session != nil {
+				user = session.Issuer + "|" + session.UserID
+				course = session.Issuer + "|" + session.ContextID
+			}
+
+			release, err := admission.Admit(user, course)
+			if err != nil {
+				var rejected *services.AdmissionError
+				errors.As(err, &rejected)
+				slog.WarnContext(r.Context(), "⛔ Execution not admitted", "reason", rejected.Code, "path", r.URL.Path)
+
+				status := http.StatusTooManyRequests
+				if rejected.Code == "judge0_unavailable" {
+					status = http.StatusServiceUnavailable
+				}
+				sendRetryAfter(w, rejected.Reason, status, rejected.RetryAfter)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, int64(2*config.LoadConfig().MaxSourceBytes+stdinAllowance))
+			slot := &executionSlot{release: release}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), executionSlotKey{}, slot)))
+			if !slot.kept {
+				release()
+			}
+		})
+	}
+}
+
+// keepExecutionSlot hands the request's admission over to work that goes on
+// after the handler returns, e.g. background grading. The returned function
+// releases it.
+func keepExecutionSlot(ctx context.Context) func() {
+	slot, ok := ctx.Value(executionSlotKey{}).(*executionSlot)
+	if !ok {
+		return func() {}
+	}
+	slot.kept = true
+	return slot.release
+}
+
+// sendDecodeError rejects a request body that could not be decoded
+func sendDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendErrorResponse(w, "Request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+}
+
+// sourceTooLarge rejects source code over MAX_SOURCE_BYTES
+func sourceTooLarge(w http.ResponseWriter, code string) bool {
+	max := config.LoadConfig().MaxSourceBytes
+	if len(code) <= max {
+		return false
+	}
+	sendErrorResponse(w, fmt.Sprintf("Source code is too large (max %d bytes)", max), http.StatusRequestEntityTooLarge)
+	return true
+}
+
+// sendExecutionError reports a failed run; while Judge0's circuit breaker is
+// open the client is told when to try again
+func sendExecutionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrJudge0Unavailable) {
+		_, wait := services.Judge0Ready()
+		sendRetryAfter(w, "Code execution is temporarily unavailable, please try again shortly", http.StatusServiceUnavailable, wait)
+		return
+	}
+	sendErrorResponse(w, fmt.Sprintf("Execution error: %v", err), http.StatusInternalServerError)
+}
+
+func sendRetryAfter(w http.ResponseWriter, message string, status int, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+	sendErrorResponse(w, message, status)
+}
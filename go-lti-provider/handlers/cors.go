@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-lti-provider/config"
+)
+
+// CORSPolicy says which cross-origin requests a group of routes accepts
+type CORSPolicy struct {
+	Origins       []string // exact origins, https://*.example.edu patterns, or "*" for any origin
+	Methods       []string
+	Headers       []string // request headers the browser may send
+	ExposeHeaders []string // response headers the page may read
+	Credentials   bool     // cookies; never sent with "*"
+	MaxAge        time.Duration
+}
+
+// APICORSPolicy lets the frontend, wherever it is embedded, call /api with
+// the learner's session token. It is read from the config in use, so a
+// config reload applies to the next request.
+func APICORSPolicy() CORSPolicy {
+	cfg := config.LoadConfig()
+	return CORSPolicy{
+		Origins:       cfg.GetCORSOrigins(),
+		Methods:       cfg.CORSAllowedMethods,
+		Headers:       cfg.CORSAllowedHeaders,
+		ExposeHeaders: []string{"Retry-After", "X-Request-ID"},
+		Credentials:   cfg.CORSAllowCredentials,
+		MaxAge:        cfg.CORSMaxAge,
+	}
+}
+
+// PublicCORSPolicy lets any origin read public documents such as the tool's
+// JWKS, without credentials
+func PublicCORSPolicy() CORSPolicy {
+	return CORSPolicy{
+		Origins: []string{"*"},
+		Methods: []string{http.MethodGet},
+		MaxAge:  config.LoadConfig().CORSMaxAge,
+	}
+}
+
+// CORS applies the CORS policy returned by policy to the routes it wraps and
+// answers their preflight requests. Use it as router middleware (r.Use) so
+// OPTIONS requests reach it.
+func CORS(policy func() CORSPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := policy()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// Response phụ thuộc vào Origin: cache (CDN, browser) không được dùng chung giữa các origin
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			allowOrigin, ok := p.allowOrigin(origin)
+			if !ok {
+				if preflight {
+					slog.DebugContext(r.Context(), "⛔ CORS preflight from an origin not allowed", "origin", origin, "path", r.URL.Path)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				// Không có header CORS: browser không cho trang đọc response
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight && !p.allowPreflight(r, origin) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			if p.Credentials && allowOrigin != "*" {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(p.ExposeHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.ExposeHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.Methods, ", "))
+			if len(p.Headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.Headers, ", "))
+			}
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowPreflight reports whether the method and headers a preflight asks
+// for are allowed
+func (p CORSPolicy) allowPreflight(r *http.Request, origin string) bool {
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !containsFold(p.Methods, method) {
+		slog.DebugContext(r.Context(), "⛔ CORS preflight for a method not allowed", "origin", origin, "method", method)
+		return false
+	}
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" && !containsFold(p.Headers, header) {
+			slog.DebugContext(r.Context(), "⛔ CORS preflight for a header not allowed", "origin", origin, "header", header)
+			return false
+		}
+	}
+	return true
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin
+func (p CORSPolicy) allowOrigin(origin string) (string, bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	for _, allowed := range p.Origins {
+		switch {
+		case allowed == "*":
+			if p.Credentials {
+				continue
+			}
+			return "*", true
+		case strings.EqualFold(allowed, origin):
+			return origin, true
+		case matchOriginPattern(allowed, u):
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// matchOriginPattern matches an origin against scheme://*.example.edu[:port],
+// which allows the subdomains of example.edu but not example.edu itself
+func matchOriginPattern(pattern string, origin *url.URL) bool {
+	p, err := url.Parse(pattern)
+	if err != nil || !strings.HasPrefix(p.Hostname(), "*.") {
+		return false
+	}
+	suffix := strings.ToLower(strings.TrimPrefix(p.Hostname(), "*"))
+	host := strings.ToLower(origin.Hostname())
+	return strings.EqualFold(p.Scheme, origin.Scheme) && p.Port() == origin.Port() &&
+		strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
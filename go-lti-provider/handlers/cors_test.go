@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicyAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    CORSPolicy
+		origin    string
+		want      string
+		wantAllow bool
+	}{
+		{"exact origin", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "https://app.example.edu", "https://app.example.edu", true},
+		{"exact origin, other case", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "https://APP.example.edu", "https://APP.example.edu", true},
+		{"other origin", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "https://evil.example.com", "", false},
+		{"other scheme", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "http://app.example.edu", "", false},
+		{"other port", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "https://app.example.edu:8443", "", false},
+		{"suffix of the allowed host", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "https://app.example.edu.evil.net", "", false},
+		{"subdomain pattern", CORSPolicy{Origins: []string{"https://*.example.edu"}}, "https://moodle.example.edu", "https://moodle.example.edu", true},
+		{"nested subdomain", CORSPolicy{Origins: []string{"https://*.example.edu"}}, "https://a.b.example.edu", "https://a.b.example.edu", true},
+		{"pattern does not match its own domain", CORSPolicy{Origins: []string{"https://*.example.edu"}}, "https://example.edu", "", false},
+		{"pattern is not a plain suffix", CORSPolicy{Origins: []string{"https://*.example.edu"}}, "https://evilexample.edu", "", false},
+		{"pattern scheme", CORSPolicy{Origins: []string{"https://*.example.edu"}}, "http://moodle.example.edu", "", false},
+		{"pattern port", CORSPolicy{Origins: []string{"https://*.example.edu:8443"}}, "https://moodle.example.edu", "", false},
+		{"any origin", CORSPolicy{Origins: []string{"*"}}, "https://anywhere.example.com", "*", true},
+		{"any origin is ignored with credentials", CORSPolicy{Origins: []string{"*"}, Credentials: true}, "https://anywhere.example.com", "", false},
+		{"null origin", CORSPolicy{Origins: []string{"https://app.example.edu"}}, "null", "", false},
+		{"no origins", CORSPolicy{}, "https://app.example.edu", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.policy.allowOrigin(tt.origin)
+			if got != tt.want || ok != tt.wantAllow {
+				t.Errorf("allowOrigin(%q) = %q, %v; want %q, %v", tt.origin, got, ok, tt.want, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	policy := CORSPolicy{
+		Origins:       []string{"https://app.example.edu"},
+		Methods:       []string{http.MethodGet, http.MethodPost},
+		Headers:       []string{"Authorization", "Content-Type"},
+		ExposeHeaders: []string{"Retry-After"},
+		MaxAge:        10 * time.Minute,
+	}
+	reached := false
+	handler := CORS(func() CORSPolicy { return policy })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantReached bool
+		wantHeaders map[string]string // "" = must not be set
+	}{
+		{
+			name:        "same-origin request",
+			method:      http.MethodGet,
+			wantStatus:  http.StatusOK,
+			wantReached: true,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			name:        "allowed origin",
+			method:      http.MethodPost,
+			headers:     map[string]string{"Origin": "https://app.example.edu"},
+			wantStatus:  http.StatusOK,
+			wantReached: true,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.edu",
+				"Access-Control-Expose-Headers":    "Retry-After",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:        "origin not allowed gets no CORS headers",
+			method:      http.MethodPost,
+			headers:     map[string]string{"Origin": "https://evil.example.com"},
+			wantStatus:  http.StatusOK,
+			wantReached: true,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.edu",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "authorization, content-type",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.edu",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:        "preflight from an origin not allowed",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "POST"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "preflight for a method not allowed",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://app.example.edu", "Access-Control-Request-Method": "DELETE"},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:   "preflight for a header not allowed",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.edu",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Authorization, X-Forwarded-User",
+			},
+			wantStatus:  http.StatusForbidden,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(tt.method, "/api/submit", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if reached != tt.wantReached {
+				t.Errorf("handler reached = %v, want %v", reached, tt.wantReached)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	policy := CORSPolicy{Origins: []string{"https://app.example.edu"}, Methods: []string{http.MethodGet}, Credentials: true}
+	handler := CORS(func() CORSPolicy { return policy })(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/session", nil)
+	req.Header.Set("Origin", "https://app.example.edu")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.edu" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the request's origin", got)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/metrics"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+	"go-lti-provider/tracing"
+	"go-lti-provider/utils"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Dependencies are the shared services used by the handlers. main wires them
+// up once at startup with Init.
+type Dependencies struct {
+	Platforms   *services.PlatformRegistry
+	Sessions    *services.SessionService
+	Problems    *services.ProblemStore
+	Submissions *services.SubmissionStore
+	Grading     *services.GradingService
+	Audit       *services.AuditLog
+	Regrader    *services.Regrader
+	Themes      *services.ThemeStore
+	Lifecycle   *services.Lifecycle
+	Health      *services.HealthChecker
+	ToolKey     *services.ToolKey
+}
+
+var deps Dependencies
+
+// Init sets the shared handler dependencies
+func Init(d Dependencies) {
+	deps = d
+}
+
+// findPlatform looks up a platform registration, falling back to the
+// environment configuration when no registry is wired up
+func findPlatform(issuer, clientID string) (*models.PlatformRegistration, bool) {
+	if deps.Platforms != nil {
+		return deps.Platforms.Find(issuer, clientID)
+	}
+
+	reg, ok := services.PlatformFromConfig(config.LoadConfig())
+	if !ok || (issuer != "" && reg.Issuer != issuer) || (clientID != "" && reg.ClientID != clientID) {
+		return nil, false
+	}
+	return &reg, true
+}
+
+// newAGSService creates an AGS client for the platform of the launch session.
+// Without a session (legacy API calls) it uses the only registered platform
+// and falls back to the environment configuration.
+func newAGSService(cfg *config.Config, session *models.LaunchSession) *services.AGSService {
+	issuer, clientID := "", ""
+	if session != nil {
+		issuer, clientID = session.Issuer, session.ClientID
+	}
+	if platform, ok := findPlatform(issuer, clientID); ok {
+		return services.NewAGSService(platform.TokenURL, platform.ClientID, cfg.ClientSecret, deps.ToolKey)
+	}
+	return services.NewAGSService(cfg.TokenEndpoint, cfg.ClientID, cfg.ClientSecret, deps.ToolKey)
+}
+
+// gradingService returns the shared grading service, or one for this request
+// when main did not wire one up
+func gradingService(cfg *config.Config) *services.GradingService {
+	if deps.Grading != nil {
+		return deps.Grading
+	}
+	return services.NewGradingService(services.Judge0FromConfig(cfg), deps.Submissions)
+}
+
+// reportProgress tells the platform a learner has opened or is working on the
+// activity. Only learners without any attempt are reported, so an existing
+// grade is never replaced by a progress update.
+func reportProgress(ctx context.Context, cfg *config.Config, session *models.LaunchSession, activity string) {
+	if session == nil || session.LineItem == "" || session.Experience != models.ExperienceLearner {
+		return
+	}
+	if len(userAttempts(session)) > 0 {
+		return
+	}
+	gradingService(cfg).ReportProgress(ctx, newAGSService(cfg, session), session.LineItem, session.UserID, activity)
+}
+
+// verifyLaunchToken verifies an id_token against the keys of the platform
+// that issued it and checks it was meant for a registered client and
+// deployment. Rejections are counted by reason; once the platform is known
+// it is returned with the error too.
+func verifyLaunchToken(ctx context.Context, idToken string) (claims *lti.Claims, platform *models.PlatformRegistration, err error) {
+	ctx, span := tracing.Start(ctx, "lti.verify_launch")
+	defer func() {
+		if platform != nil {
+			span.SetAttributes(attribute.String("lti.platform", platform.Issuer))
+		}
+		tracing.End(span, err)
+	}()
+
+	issuer, audience, err := lti.PeekIssuer(idToken)
+	if err != nil {
+		return nil, nil, rejectToken("malformed", err)
+	}
+
+	for _, clientID := range audience {
+		if reg, ok := findPlatform(issuer, clientID); ok {
+			platform = reg
+			break
+		}
+	}
+	if platform == nil {
+		return nil, nil, rejectToken("unknown_platform", fmt.Errorf("no registration for issuer %q and audience %v", issuer, audience))
+	}
+
+	claims, err = utils.VerifyIDToken(ctx, idToken, platform.JWKSURL)
+	if err != nil {
+		return nil, platform, rejectToken(verifyFailureReason(err), err)
+	}
+	if err := claims.Validate(); err != nil {
+		return nil, platform, rejectToken("invalid_claims", err)
+	}
+
+	if !platform.HasDeployment(claims.DeploymentID) {
+		return nil, platform, rejectToken("unknown_deployment", fmt.Errorf("unknown deployment %q for client %s", claims.DeploymentID, platform.ClientID))
+	}
+
+	return claims, platform, nil
+}
+
+// verifyFailureReason tells why VerifyIDToken rejected a token
+func verifyFailureReason(err error) string {
+	switch {
+	case errors.Is(err, utils.ErrJWKSUnavailable):
+		return "jwks_unavailable"
+	case errors.Is(err, lti.ErrInvalidSignature):
+		return "invalid_signature"
+	case errors.Is(err, jwt.ErrTokenExpired()):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotYetValid()), errors.Is(err, jwt.ErrInvalidIssuedAt()):
+		return "not_yet_valid"
+	default:
+		return "invalid_token"
+	}
+}
+
+func rejectToken(reason string, err error) error {
+	metrics.JWTFailures.WithLabelValues(reason).Inc()
+	return err
+}
+
+// countLaunch counts a launch by platform and outcome
+func countLaunch(platform *models.PlatformRegistration, outcome string) {
+	issuer := metrics.UnknownPlatform
+	if platform != nil {
+		issuer = platform.Issuer
+	}
+	metrics.Launches.WithLabelValues(issuer, outcome).Inc()
+}
@@ -2,11 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"go-lti-provider/config"
 	"go-lti-provider/models"
 	"go-lti-provider/services"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -15,87 +14,133 @@ import (
 type ExecuteRequest struct {
 	Code     string  `json:"code"`
 	Language string  `json:"language"`
-	UserID   string  `json:"user_id"`
-	LineItem string  `json:"lineitem"`
 	IDToken  string  `json:"id_token"`
 	MaxScore float64 `json:"max_score"`
+	Async    bool    `json:"async"` // respond 202 right away instead of waiting for the grade
 }
 
 // ExecuteResponse represents the response from code execution
 type ExecuteResponse struct {
-	Success bool                   `json:"success"`
-	Result  *models.Judge0Response `json:"result,omitempty"`
-	Score   float64                `json:"score,omitempty"`
-	Error   string                 `json:"error,omitempty"`
+	Success      bool                    `json:"success"`
+	SubmissionID string                  `json:"submission_id,omitempty"`
+	EventsURL    string                  `json:"events_url,omitempty"` // live progress (SSE) of an async submit
+	Status       models.SubmissionStatus `json:"status,omitempty"`
+	Result       *models.Judge0Response  `json:"result,omitempty"`
+	Tests        []models.TestResult     `json:"tests,omitempty"`
+	Score        float64                 `json:"score,omitempty"`
+	LatePenalty  float64                 `json:"late_penalty,omitempty"`
+	Grade        float64                 `json:"grade,omitempty"` // score sent to the gradebook
+	AttemptsLeft *int                    `json:"attempts_left,omitempty"`
+	Error        string                  `json:"error,omitempty"`
 }
 
-// ExecuteHandler handles code execution and grade submission
+// ExecuteHandler handles graded submissions (/api/submit, and the older
+// /api/execute): all test cases are run, the assignment policy is enforced
+// and the grade is sent to the platform. The learner and the line item come
+// from the launch session only (RequirePermission(PermissionSubmit)).
 func ExecuteHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("⚡ Code execution request received")
+	ctx := r.Context()
+	slog.InfoContext(ctx, "⚡ Graded submission received")
 
 	// Parse request body
 	var req ExecuteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("❌ Error parsing request: %v", err)
-		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		slog.WarnContext(ctx, "❌ Error parsing request", "error", err)
+		sendDecodeError(w, err)
 		return
 	}
 
 	// Validate required fields
 	if req.Code == "" || req.Language == "" {
-		log.Println("❌ Missing required fields: code or language")
+		slog.WarnContext(ctx, "❌ Missing required fields: code or language")
 		sendErrorResponse(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
+	if sourceTooLarge(w, req.Code) {
+		return
+	}
+
+	session := sessionFromContext(ctx)
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
 
 	// Load config
 	cfg := config.LoadConfig()
 
 	// Initialize services
-	judge0Service := services.NewJudge0Service(cfg.Judge0AuthToken)
-	agsService := services.NewAGSService(
-		cfg.TokenEndpoint,
-		cfg.ClientID,
-		cfg.ClientSecret,
-	)
-
-	// Get language ID and submit code
-	langID := config.GetLanguageID(req.Language)
-	result, err := judge0Service.SubmitCode(req.Code, langID)
-	if err != nil {
-		log.Printf("❌ Judge0 error: %v", err)
-		sendErrorResponse(w, fmt.Sprintf("Execution error: %v", err), http.StatusInternalServerError)
+	agsService := newAGSService(cfg, session)
+	grading := gradingService(cfg)
+
+	// Build the attempt record
+	record := &models.SubmissionRecord{
+		ResourceKey: session.ResourceKey,
+		UserID:      session.UserID,
+		Source:      req.Code,
+		Language:    req.Language,
+		LanguageID:  config.GetLanguageID(req.Language),
+		MaxScore:    req.MaxScore,
+		CreatedAt:   time.Now(),
+	}
+
+	var problem *models.Problem
+	if deps.Problems != nil {
+		problem, _ = deps.Problems.Get(session.ResourceKey)
+	}
+	lineItem := session.LineItem
+	if lineItem == "" && problem != nil {
+		lineItem = problem.LineItem
+	}
+
+	// Enforce attempt limits, cooldown and cutoff before running anything;
+	// the attempt is reserved in the same step so parallel submits count
+	policy := assignmentPolicy(ctx, agsService, session, problem)
+	if err := reserveAttempt(policy, record); err != nil {
+		sendPolicyViolation(w, r, err)
 		return
 	}
 
-	// Calculate score
-	score := judge0Service.CalculateScore(result, req.MaxScore)
-
-	// Submit grade to Moodle if lineitem is available
-	if req.LineItem != "" && req.UserID != "" {
-		go func() {
-			gradeReq := models.AGSGradeRequest{
-				LineItemURL: req.LineItem,
-				UserID:      req.UserID,
-				Score:       score,
-				MaxScore:    req.MaxScore,
-				Comment:     fmt.Sprintf("Auto-graded at %s", time.Now().Format(time.RFC3339)),
-			}
-
-			if err := agsService.SubmitGrade(gradeReq); err != nil {
-				log.Printf("⚠️ Failed to submit grade: %v", err)
-			} else {
-				log.Printf("✅ Grade submitted - User: %s, Score: %.2f/%.2f",
-					req.UserID, score, req.MaxScore)
-			}
-		}()
+	// Grade in the background; the gradebook sees Submitted/Pending meanwhile
+	job := services.GradingJob{Record: record, Problem: problem, Policy: policy, Release: keepExecutionSlot(ctx)}
+	if lineItem != "" {
+		job.AGS, job.LineItem, job.UserID = agsService, lineItem, session.UserID
+		record.LineItem = lineItem
+	}
+	done := grading.Submit(ctx, job)
+
+	// Async clients follow /api/executions/{id}/events, or poll /api/submissions/{id}
+	if req.Async && record.ID != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ExecuteResponse{
+			Success:      true,
+			SubmissionID: record.ID,
+			EventsURL:    "/api/executions/" + record.ID + "/events",
+			Status:       models.SubmissionPending,
+		})
+		return
+	}
+
+	outcome := <-done
+	if outcome.Err != nil {
+		slog.ErrorContext(ctx, "❌ Judge0 error", "error", outcome.Err)
+		sendExecutionError(w, outcome.Err)
+		return
 	}
 
 	// Send response
+	view := record.LearnerView()
 	response := ExecuteResponse{
-		Success: true,
-		Result:  result,
-		Score:   score,
+		Success:      true,
+		SubmissionID: record.ID,
+		Status:       record.Status,
+		Result:       view.Result,
+		Tests:        view.Tests,
+		Score:        record.Score,
+		LatePenalty:  record.LatePenalty,
+		Grade:        outcome.Grade,
+		AttemptsLeft: attemptsLeft(policy, userAttempts(session)),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-lti-provider/models"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/websocket"
+)
+
+// eventsKeepAlive is how often an idle stream sends a comment (SSE) or a
+// ping (WebSocket), so proxies do not close it while a test case runs
+const eventsKeepAlive = 15 * time.Second
+
+// ExecutionEventsHandler streams the progress of a graded submission as
+// Server-Sent Events: queued, compiling, running (test N of M), test (its
+// verdict), score, grade_sync, failed and, last, done. Each event's id is its
+// seq; a client reconnecting with Last-Event-ID gets the events it missed.
+// EventSource cannot send headers, so the session may be passed as ?session=.
+func ExecutionEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	after, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	past, live, cancel, ok := subscribeExecution(w, r, after)
+	if !ok {
+		return
+	}
+	defer cancel()
+
+	// Stream dài hơn WRITE_TIMEOUT; stream kết thúc khi có event done
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(ctx, "⚠️ Failed to clear the write deadline", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: do not buffer the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 2000\n\n")
+
+	send := func(event models.ExecutionEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	if err := streamExecution(past, live, keepAlive.C, ctx.Done(), send, func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}); err != nil {
+		slog.DebugContext(ctx, "Execution event stream ended", "error", err)
+	}
+}
+
+// ExecutionSocketHandler streams the same events as ExecutionEventsHandler
+// over a WebSocket, one JSON message each, for networks whose proxies buffer
+// SSE. ?after= plays the part of Last-Event-ID. The connection is closed
+// after the done event; messages from the client are ignored.
+func ExecutionSocketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+	past, live, cancel, ok := subscribeExecution(w, r, after)
+	if !ok {
+		return
+	}
+	defer cancel()
+
+	server := websocket.Server{
+		// Như CORS của API: chỉ frontend được mở socket
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if _, allowed := APICORSPolicy().allowOrigin(origin); !allowed {
+					return fmt.Errorf("origin %q not allowed", origin)
+				}
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// Đọc để nhận close frame và ping của client
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			keepAlive := time.NewTicker(eventsKeepAlive)
+			defer keepAlive.Stop()
+			err := streamExecution(past, live, keepAlive.C, closed, func(event models.ExecutionEvent) error {
+				ws.SetWriteDeadline(time.Now().Add(eventsKeepAlive))
+				return websocket.JSON.Send(ws, event)
+			}, func() error {
+				ws.SetWriteDeadline(time.Now().Add(eventsKeepAlive))
+				ws.PayloadType = websocket.PingFrame
+				_, err := ws.Write(nil)
+				ws.PayloadType = websocket.TextFrame
+				return err
+			})
+			if err != nil {
+				slog.DebugContext(ctx, "Execution socket ended", "error", err)
+			}
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// subscribeExecution checks that the session may follow the submission in
+// the URL and subscribes to its events after seq. A submission graded too
+// long ago (or by another instance) gets a done event with its status;
+// one still pending there gets 404, and the client falls back to polling
+// /api/submissions/{id}.
+func subscribeExecution(w http.ResponseWriter, r *http.Request, after int) (past []models.ExecutionEvent, live <-chan models.ExecutionEvent, cancel func(), ok bool) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return nil, nil, nil, false
+	}
+
+	id := chi.URLParam(r, "id")
+	record, found := deps.Submissions.Get(id)
+	if !found || record.ResourceKey != session.ResourceKey ||
+		(record.UserID != session.UserID && !session.Can(models.PermissionViewSubmissions)) {
+		sendErrorResponse(w, "Submission not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	if past, live, cancel, ok = deps.Grading.Events.Subscribe(id, after); ok {
+		return past, live, cancel, true
+	}
+	if record.Status == models.SubmissionPending {
+		sendErrorResponse(w, "No live progress for this submission", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	finished := make(chan models.ExecutionEvent)
+	close(finished)
+	done := models.ExecutionEvent{Seq: after + 1, Type: models.EventDone, Time: record.CompletedAt, Status: record.Status}
+	return []models.ExecutionEvent{done}, finished, func() {}, true
+}
+
+// streamExecution sends the past events, then the live ones until the final
+// event, the live channel closing (the client fell behind and reconnects)
+// or stop; keepAlive is sent whenever tick fires
+func streamExecution(past []models.ExecutionEvent, live <-chan models.ExecutionEvent, tick <-chan time.Time, stop <-chan struct{},
+	send func(models.ExecutionEvent) error, keepAlive func() error) error {
+	for _, event := range past {
+		if err := send(event); err != nil {
+			return err
+		}
+		if event.Final() {
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case event, open := <-live:
+			if !open {
+				return nil
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+			if event.Final() {
+				return nil
+			}
+		case <-tick:
+			if err := keepAlive(); err != nil {
+				return err
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
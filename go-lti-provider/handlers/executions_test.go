@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestStreamExecution(t *testing.T) {
+	queued := models.ExecutionEvent{Seq: 1, Type: models.EventQueued}
+	running := models.ExecutionEvent{Seq: 2, Type: models.EventRunning}
+	score := models.ExecutionEvent{Seq: 3, Type: models.EventScore}
+	done := models.ExecutionEvent{Seq: 4, Type: models.EventDone}
+	after := models.ExecutionEvent{Seq: 5, Type: models.EventGradeSync}
+	errSend := errors.New("client gone")
+
+	tests := []struct {
+		name      string
+		past      []models.ExecutionEvent
+		live      []models.ExecutionEvent
+		closeLive bool
+		ticks     int  // keep-alives before the live events
+		stop      bool // stop closed before streaming
+		failOn    int  // seq whose send fails, 0 = none
+		want      []int
+		wantPings int
+		wantErr   error
+	}{
+		{"past then live until done", []models.ExecutionEvent{queued, running}, []models.ExecutionEvent{score, done, after}, false, 0, false, 0, []int{1, 2, 3, 4}, 0, nil},
+		{"done among the past events", []models.ExecutionEvent{queued, done}, []models.ExecutionEvent{after}, false, 0, false, 0, []int{1, 4}, 0, nil},
+		{"live channel closed", []models.ExecutionEvent{queued}, []models.ExecutionEvent{running}, true, 0, false, 0, []int{1, 2}, 0, nil},
+		{"keep-alive while idle", nil, []models.ExecutionEvent{done}, false, 2, false, 0, []int{4}, 2, nil},
+		{"stopped by the client", []models.ExecutionEvent{queued}, nil, false, 0, true, 0, []int{1}, 0, nil},
+		{"send of a past event fails", []models.ExecutionEvent{queued, running}, []models.ExecutionEvent{done}, false, 0, false, 1, nil, 0, errSend},
+		{"send of a live event fails", []models.ExecutionEvent{queued}, []models.ExecutionEvent{running, done}, false, 0, false, 2, []int{1}, 0, errSend},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The keep-alives fire first, while no live event is waiting
+			tick := make(chan time.Time)
+			live := make(chan models.ExecutionEvent, len(tt.live))
+			go func() {
+				for i := 0; i < tt.ticks; i++ {
+					tick <- time.Now()
+				}
+				for _, event := range tt.live {
+					live <- event
+				}
+				if tt.closeLive {
+					close(live)
+				}
+			}()
+			stop := make(chan struct{})
+			if tt.stop {
+				close(stop)
+			}
+
+			var sent []int
+			pings := 0
+			err := streamExecution(tt.past, live, tick, stop, func(event models.ExecutionEvent) error {
+				if event.Seq == tt.failOn {
+					return errSend
+				}
+				sent = append(sent, event.Seq)
+				return nil
+			}, func() error {
+				pings++
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("streamExecution() = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(sent, tt.want) {
+				t.Errorf("sent = %v, want %v", sent, tt.want)
+			}
+			if pings != tt.wantPings {
+				t.Errorf("keep-alives = %d, want %d", pings, tt.wantPings)
+			}
+		})
+	}
+}
+
+func TestSubscribeExecution(t *testing.T) {
+	store, err := services.NewSubmissionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := services.NewExecutionEvents()
+	previous := deps
+	deps = Dependencies{Submissions: store, Grading: &services.GradingService{Submissions: store, Events: events}}
+	t.Cleanup(func() { deps = previous })
+
+	key := models.ResourceKey{Issuer: "https://lms.example.edu", ContextID: "c1", ResourceLinkID: "r1"}
+	save := func(userID string, status models.SubmissionStatus) string {
+		record := &models.SubmissionRecord{ResourceKey: key, UserID: userID, Status: status, CreatedAt: time.Now()}
+		if err := store.Save(record); err != nil {
+			t.Fatal(err)
+		}
+		return record.ID
+	}
+	live := save("learner", models.SubmissionPending)
+	events.Publish(live, models.ExecutionEvent{Type: models.EventQueued})
+	finished := save("learner", models.SubmissionCompleted)
+	lost := save("learner", models.SubmissionPending) // graded by another instance
+	other := save("other-learner", models.SubmissionPending)
+	events.Publish(other, models.ExecutionEvent{Type: models.EventQueued})
+
+	learner := &models.LaunchSession{ResourceKey: key, UserID: "learner", Permissions: models.PermissionsFor(models.ExperienceLearner, nil)}
+	ta := &models.LaunchSession{ResourceKey: key, UserID: "ta", Permissions: []models.Permission{models.PermissionViewSubmissions}}
+	otherCourse := *learner
+	otherCourse.ResourceKey.ContextID = "c2"
+
+	tests := []struct {
+		name       string
+		session    *models.LaunchSession
+		id         string
+		wantStatus int // 0 = subscribed
+		wantPast   []string
+	}{
+		{"own execution", learner, live, 0, []string{models.EventQueued}},
+		{"own finished submission", learner, finished, 0, []string{models.EventDone}},
+		{"own submission pending elsewhere", learner, lost, http.StatusNotFound, nil},
+		{"no session", nil, live, http.StatusUnauthorized, nil},
+		{"unknown submission", learner, "missing", http.StatusNotFound, nil},
+		{"another learner's submission", learner, other, http.StatusNotFound, nil},
+		{"another course", &otherCourse, live, http.StatusNotFound, nil},
+		{"TA follows a learner", ta, other, 0, []string{models.EventQueued}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeCtx := chi.NewRouteContext()
+			routeCtx.URLParams.Add("id", tt.id)
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, routeCtx)
+			if tt.session != nil {
+				ctx = context.WithValue(ctx, sessionContextKey{}, tt.session)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/submissions/"+tt.id+"/events", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			past, _, cancel, ok := subscribeExecution(rec, req, 0)
+			if tt.wantStatus != 0 {
+				if ok || rec.Code != tt.wantStatus {
+					t.Errorf("subscribeExecution() = %v, status %d; want %d", ok, rec.Code, tt.wantStatus)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("subscribeExecution() = false, status %d", rec.Code)
+			}
+			defer cancel()
+			var types []string
+			for _, event := range past {
+				types = append(types, event.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantPast) {
+				t.Errorf("past events = %v, want %v", types, tt.wantPast)
+			}
+		})
+	}
+}
@@ -1,201 +1,272 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
-)
 
-// AGS Grade submission structures
-type Grade struct {
-	ScoreGiven       float64 `json:"scoreGiven"`
-	ScoreMaximum     float64 `json:"scoreMaximum"`
-	Comment          string  `json:"comment,omitempty"`
-	ActivityProgress string  `json:"activityProgress"` // Initialized, InProgress, Submitted, Completed
-	GradingProgress  string  `json:"gradingProgress"`  // FullyGraded, Pending, PendingManual, Failed, NotReady
-	Timestamp        string  `json:"timestamp"`
-	UserID           string  `json:"userId"`
-}
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
 
-// OAuth2 Token Response
-type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
-	Scope       string `json:"scope"`
-}
+	"github.com/go-chi/chi/v5"
+)
 
-// AGS Grade Request
-type AGSGradeRequest struct {
-	LineItemURL string  `json:"lineitem_url"`
-	UserID      string  `json:"user_id"`
-	Score       float64 `json:"score"`
-	MaxScore    float64 `json:"max_score"`
-	Comment     string  `json:"comment"`
-	AccessToken string  `json:"access_token"`
+// GradeRequest is the body of a manual grade submission
+type GradeRequest struct {
+	LineItemURL string   `json:"lineitem_url"`
+	UserID      string   `json:"user_id"`
+	Score       *float64 `json:"score"`
+	MaxScore    float64  `json:"max_score"`
+	Comment     string   `json:"comment"`
+	AccessToken string   `json:"access_token"`
+
+	// Override of a stored submission: the learner, line item and maximum
+	// score come from the submission, and the score may be given by rubric
+	SubmissionID string               `json:"submission_id,omitempty"`
+	Rubric       []models.RubricScore `json:"rubric,omitempty"`
 }
 
 // GradeHandler xử lý việc gửi điểm về Moodle qua AGS
 func GradeHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("📊 AGS Grade submission received")
+	ctx := r.Context()
+	slog.InfoContext(ctx, "📊 AGS grade submission received")
 
 	// Parse request body
-	var gradeReq AGSGradeRequest
+	var gradeReq GradeRequest
 	if err := json.NewDecoder(r.Body).Decode(&gradeReq); err != nil {
-		log.Printf("❌ Error parsing grade request: %v", err)
+		slog.WarnContext(ctx, "❌ Error parsing grade request", "error", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate required fields
-	if gradeReq.LineItemURL == "" || gradeReq.UserID == "" {
-		log.Println("❌ Missing required fields: lineitem_url or user_id")
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+	// Overrides of a stored submission (POST /api/instructor/submissions/{id}/grade)
+	session := sessionFromContext(ctx)
+	if session == nil {
+		http.Error(w, "Launch session required", http.StatusUnauthorized)
 		return
 	}
+	if id := chi.URLParam(r, "id"); id != "" {
+		gradeReq.SubmissionID = id
+	}
 
-	// Get access token if not provided
-	accessToken := gradeReq.AccessToken
-	if accessToken == "" {
-		token, err := getAccessToken()
+	// The override is only computed here; nothing is stored until every
+	// check has passed
+	var record *models.SubmissionRecord
+	if gradeReq.SubmissionID != "" {
+		var status int
+		var err error
+		record, status, err = overrideSubmission(ctx, session, &gradeReq)
 		if err != nil {
-			log.Printf("❌ Failed to get access token: %v", err)
-			http.Error(w, "Failed to authenticate with platform", http.StatusInternalServerError)
+			slog.WarnContext(ctx, "❌ Grade override rejected", "error", err)
+			http.Error(w, err.Error(), status)
 			return
 		}
-		accessToken = token
 	}
 
-	// Submit grade to Moodle
-	err := submitGradeToMoodle(gradeReq, accessToken)
-	if err != nil {
-		log.Printf("❌ Failed to submit grade: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to submit grade: %v", err), http.StatusInternalServerError)
+	// Instructors can only grade the line item they launched from; a launch
+	// without a line item cannot send grades at all
+	if gradeReq.LineItemURL != "" && gradeReq.LineItemURL != session.LineItem {
+		slog.WarnContext(ctx, "⛔ Line item does not belong to the launch session", "lineitem", gradeReq.LineItemURL)
+		http.Error(w, "Line item does not match launch", http.StatusForbidden)
 		return
 	}
 
-	log.Printf("✅ Grade submitted successfully - User: %s, Score: %.2f/%.2f",
-		gradeReq.UserID, gradeReq.Score, gradeReq.MaxScore)
-
-	// Return success response
-	response := map[string]interface{}{
-		"success": true,
-		"message": "Grade submitted successfully",
-		"data": map[string]interface{}{
-			"user_id":   gradeReq.UserID,
-			"score":     gradeReq.Score,
-			"max_score": gradeReq.MaxScore,
-			"comment":   gradeReq.Comment,
-			"timestamp": time.Now().Format(time.RFC3339),
-		},
+	// Không có line item: điểm override chỉ được lưu trong tool
+	if record != nil && gradeReq.LineItemURL == "" {
+		if _, err := commitGrade(ctx, session, record, gradeReq); err != nil {
+			http.Error(w, "Failed to save override", http.StatusInternalServerError)
+			return
+		}
+		writeGradeResponse(w, gradeReq, record, false)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func getAccessToken() (string, error) {
-	// OAuth2 Client Credentials flow cho Moodle local
-	tokenURL := "http://localhost:8888/mod/lti/token.php"
-	clientID := "wAWXk7ifY0o9tCU"
-	clientSecret := "your-client-secret"
-	scope := "https://purl.imsglobal.org/spec/lti-ags/scope/score"
-
-	// Prepare form data
-	data := fmt.Sprintf("grant_type=client_credentials&client_id=%s&client_secret=%s&scope=%s",
-		clientID, clientSecret, scope)
+	// Validate required fields
+	if gradeReq.LineItemURL == "" || gradeReq.UserID == "" || gradeReq.Score == nil {
+		slog.WarnContext(ctx, "❌ Missing required fields: lineitem_url, user_id or score")
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(data))
+	// The grade is audited (and an override stored) before anything is sent
+	// to the platform
+	entry, err := commitGrade(ctx, session, record, gradeReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to create token request: %w", err)
+		http.Error(w, "Failed to save grade", http.StatusInternalServerError)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	err = newAGSService(config.LoadConfig(), session).SubmitGrade(ctx, models.AGSGradeRequest{
+		LineItemURL:      gradeReq.LineItemURL,
+		UserID:           gradeReq.UserID,
+		Score:            gradeReq.Score,
+		MaxScore:         gradeReq.MaxScore,
+		Comment:          gradeReq.Comment,
+		ActivityProgress: lti.ActivityCompleted,
+		GradingProgress:  lti.GradingFullyGraded,
+		AccessToken:      gradeReq.AccessToken,
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to request token: %w", err)
+		slog.ErrorContext(ctx, "❌ Failed to submit grade", "error", err)
+		if record != nil {
+			// The override is saved; a grade resync can send it later
+			http.Error(w, fmt.Sprintf("Grade saved but not sent to the platform: %v", err), http.StatusBadGateway)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to submit grade: %v", err), http.StatusInternalServerError)
+		return
 	}
-	defer resp.Body.Close()
+	auditSynced(ctx, entry)
+
+	slog.InfoContext(ctx, "✅ Grade submitted", "learner_id", gradeReq.UserID,
+		"score", *gradeReq.Score, "max_score", gradeReq.MaxScore)
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	writeGradeResponse(w, gradeReq, record, true)
+}
+
+// overrideSubmission applies an instructor override to a copy of a stored
+// submission of the session's resource link and fills in the grade request
+// from it. The override is stored by commitGrade.
+func overrideSubmission(ctx context.Context, session *models.LaunchSession, gradeReq *GradeRequest) (*models.SubmissionRecord, int, error) {
+	if session == nil || deps.Submissions == nil {
+		return nil, http.StatusUnauthorized, fmt.Errorf("launch session required")
 	}
 
-	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+	record, ok := deps.Submissions.Get(gradeReq.SubmissionID)
+	if !ok || record.ResourceKey != session.ResourceKey {
+		return nil, http.StatusNotFound, fmt.Errorf("submission not found")
 	}
 
-	return tokenResp.AccessToken, nil
-}
+	var problem *models.Problem
+	if deps.Problems != nil {
+		problem, _ = deps.Problems.Get(session.ResourceKey)
+	}
 
-func submitGradeToMoodle(gradeReq AGSGradeRequest, accessToken string) error {
-	// Create AGS Grade payload
-	grade := Grade{
-		ScoreGiven:       gradeReq.Score,
-		ScoreMaximum:     gradeReq.MaxScore,
-		Comment:          gradeReq.Comment,
-		ActivityProgress: "Completed",
-		GradingProgress:  "FullyGraded",
-		Timestamp:        time.Now().Format(time.RFC3339),
-		UserID:           gradeReq.UserID,
+	if gradeReq.Comment == "" {
+		gradeReq.Comment = fmt.Sprintf("Graded by instructor at %s", time.Now().Format(time.RFC3339))
 	}
 
-	jsonData, err := json.Marshal(grade)
+	override, err := services.ApplyOverride(record, problem, gradeReq.Score, gradeReq.Rubric, gradeReq.Comment, session.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal grade data: %w", err)
+		return nil, http.StatusBadRequest, err
 	}
 
-	// AGS Score submission endpoint
-	// URL format: {line_item_url}/scores
-	scoreURL := gradeReq.LineItemURL + "/scores"
+	gradeReq.UserID = record.UserID
+	gradeReq.Score = &override.Score
+	gradeReq.MaxScore = record.MaxScore
+	if gradeReq.LineItemURL == "" {
+		gradeReq.LineItemURL = session.LineItem
+	}
+	return record, http.StatusOK, nil
+}
 
-	req, err := http.NewRequest("POST", scoreURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create grade request: %w", err)
+// commitGrade audits a grade and stores the override (record may be nil for
+// a plain grade). The audit entry is written first: a grade that cannot be
+// audited is not stored nor sent.
+func commitGrade(ctx context.Context, session *models.LaunchSession, record *models.SubmissionRecord, gradeReq GradeRequest) (models.AuditEntry, error) {
+	entry := gradeAuditEntry(session, record, gradeReq)
+	if deps.Audit != nil {
+		if err := deps.Audit.Append(entry); err != nil {
+			slog.ErrorContext(ctx, "❌ Failed to write audit log", "error", err)
+			return entry, err
+		}
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/vnd.ims.lis.v1.score+json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if record != nil {
+		if err := deps.Submissions.Save(record); err != nil {
+			slog.ErrorContext(ctx, "❌ Failed to save override", "submission_id", record.ID, "error", err)
+			return entry, err
+		}
+		slog.InfoContext(ctx, "📝 Submission overridden", "submission_id", record.ID, "learner_id", record.UserID,
+			"previous_score", record.Override.PreviousScore, "score", record.Override.Score)
+	}
+	return entry, nil
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to submit grade: %w", err)
+// gradeAuditEntry records who set which grade. It is written before the
+// grade is sent, so it is not synced yet.
+func gradeAuditEntry(session *models.LaunchSession, record *models.SubmissionRecord, gradeReq GradeRequest) models.AuditEntry {
+	entry := models.AuditEntry{
+		ResourceKey: session.ResourceKey,
+		Action:      models.AuditManualGrade,
+		By:          session.UserID,
+		UserID:      gradeReq.UserID,
+		LineItem:    gradeReq.LineItemURL,
+		MaxScore:    gradeReq.MaxScore,
+		Comment:     gradeReq.Comment,
+	}
+	if gradeReq.Score != nil {
+		entry.NewScore = *gradeReq.Score
 	}
-	defer resp.Body.Close()
+	if record != nil && record.Override != nil {
+		previous := record.Override.PreviousScore
+		entry.Action = models.AuditGradeOverride
+		entry.SubmissionID = record.ID
+		entry.OldScore = &previous
+		entry.Rubric = record.Override.Rubric
+	}
+	return entry
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("grade submission failed with status %d", resp.StatusCode)
+// auditSynced records that an audited grade reached the platform. The grade
+// itself is already audited, so a failure here is only logged.
+func auditSynced(ctx context.Context, entry models.AuditEntry) {
+	if deps.Audit == nil {
+		return
 	}
 
-	log.Printf("✅ Grade submitted to AGS endpoint: %s", scoreURL)
-	return nil
+	entry.At = time.Time{}
+	entry.Action = models.AuditGradeSynced
+	entry.Synced = true
+	if err := deps.Audit.Append(entry); err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to write audit log", "error", err)
+	}
 }
 
-// Helper function để test grade submission từ launch
-func SubmitTestGrade(lineItemURL, userID string, score, maxScore float64) error {
-	gradeReq := AGSGradeRequest{
-		LineItemURL: lineItemURL,
-		UserID:      userID,
-		Score:       score,
-		MaxScore:    maxScore,
-		Comment:     "Auto-graded by LTI Tool",
+func writeGradeResponse(w http.ResponseWriter, gradeReq GradeRequest, record *models.SubmissionRecord, synced bool) {
+	data := map[string]interface{}{
+		"user_id":   gradeReq.UserID,
+		"score":     gradeReq.Score,
+		"max_score": gradeReq.MaxScore,
+		"comment":   gradeReq.Comment,
+		"synced":    synced,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if record != nil {
+		data["submission"] = record
 	}
 
-	accessToken, err := getAccessToken()
-	if err != nil {
-		return fmt.Errorf("failed to get access token: %w", err)
+	// Return success response
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Grade submitted successfully",
+		"data":    data,
+	}
+	if !synced {
+		response["message"] = "Grade saved"
 	}
 
-	return submitGradeToMoodle(gradeReq, accessToken)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Helper function để test grade submission từ launch
+func SubmitTestGrade(lineItemURL, userID string, score, maxScore float64) error {
+	return newAGSService(config.LoadConfig(), nil).SubmitGrade(context.Background(), models.AGSGradeRequest{
+		LineItemURL:      lineItemURL,
+		UserID:           userID,
+		Score:            &score,
+		MaxScore:         maxScore,
+		Comment:          "Auto-graded by LTI Tool",
+		ActivityProgress: lti.ActivityCompleted,
+		GradingProgress:  lti.GradingFullyGraded,
+	})
 }
 
 // ParseScore parses score từ string và validate
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGradeHandler(t *testing.T) {
+	// The platform's score service: the line item URL carries a query
+	var posted []lti.Score
+	var postedPaths []string
+	platform := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var score lti.Score
+		if err := json.NewDecoder(r.Body).Decode(&score); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		posted = append(posted, score)
+		postedPaths = append(postedPaths, r.URL.Path+"?"+r.URL.RawQuery)
+	}))
+	defer platform.Close()
+	lineItem := platform.URL + "/lineitems/7?type_id=3"
+
+	key := models.ResourceKey{Issuer: "https://lms.example.edu", ContextID: "course-1", ResourceLinkID: "link-1"}
+	teacher := &models.LaunchSession{ResourceKey: key, UserID: "teacher", LineItem: lineItem}
+	noLineItem := &models.LaunchSession{ResourceKey: key, UserID: "teacher"}
+
+	tests := []struct {
+		name         string
+		session      *models.LaunchSession
+		submissionID string
+		body         string
+		auditFails   bool
+		wantStatus   int
+		wantPosted   bool
+		wantActions  []string
+		wantScore    float64 // stored score of the submission
+	}{
+		{
+			name:       "no session",
+			body:       `{"lineitem_url":"` + lineItem + `","user_id":"learner","score":5,"max_score":10,"access_token":"t"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantScore:  4,
+		},
+		{
+			name:       "other line item",
+			session:    teacher,
+			body:       `{"lineitem_url":"` + platform.URL + `/lineitems/8","user_id":"learner","score":5,"max_score":10,"access_token":"t"}`,
+			wantStatus: http.StatusForbidden,
+			wantScore:  4,
+		},
+		{
+			name:       "launch without a line item",
+			session:    noLineItem,
+			body:       `{"lineitem_url":"` + lineItem + `","user_id":"learner","score":5,"max_score":10,"access_token":"t"}`,
+			wantStatus: http.StatusForbidden,
+			wantScore:  4,
+		},
+		{
+			name:        "plain grade",
+			session:     teacher,
+			body:        `{"lineitem_url":"` + lineItem + `","user_id":"learner","score":5,"max_score":10,"access_token":"t"}`,
+			wantStatus:  http.StatusOK,
+			wantPosted:  true,
+			wantActions: []string{models.AuditManualGrade, models.AuditGradeSynced},
+			wantScore:   4,
+		},
+		{
+			name:         "override",
+			session:      teacher,
+			submissionID: "sub-1",
+			body:         `{"score":7,"access_token":"t"}`,
+			wantStatus:   http.StatusOK,
+			wantPosted:   true,
+			wantActions:  []string{models.AuditGradeOverride, models.AuditGradeSynced},
+			wantScore:    7,
+		},
+		{
+			name:         "override without a line item is only stored",
+			session:      noLineItem,
+			submissionID: "sub-1",
+			body:         `{"score":7}`,
+			wantStatus:   http.StatusOK,
+			wantActions:  []string{models.AuditGradeOverride},
+			wantScore:    7,
+		},
+		{
+			name:         "override that cannot be audited",
+			session:      teacher,
+			submissionID: "sub-1",
+			body:         `{"score":7,"access_token":"t"}`,
+			auditFails:   true,
+			wantStatus:   http.StatusInternalServerError,
+			wantScore:    4,
+		},
+		{
+			name:       "plain grade that cannot be audited",
+			session:    teacher,
+			body:       `{"lineitem_url":"` + lineItem + `","user_id":"learner","score":5,"max_score":10,"access_token":"t"}`,
+			auditFails: true,
+			wantStatus: http.StatusInternalServerError,
+			wantScore:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posted, postedPaths = nil, nil
+			dir := t.TempDir()
+
+			store, err := services.NewSubmissionStore(filepath.Join(dir, "submissions"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Save(&models.SubmissionRecord{ID: "sub-1", ResourceKey: key, UserID: "learner",
+				Status: models.SubmissionCompleted, Score: 4, MaxScore: 10}); err != nil {
+				t.Fatal(err)
+			}
+
+			audit, err := services.NewAuditLog(filepath.Join(dir, "audit", "audit.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// An audit log below a regular file cannot be written
+			if tt.auditFails {
+				if err := os.WriteFile(filepath.Join(dir, "audit"), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			previous := deps
+			deps = Dependencies{Submissions: store, Audit: audit}
+			t.Cleanup(func() { deps = previous })
+
+			req := httptest.NewRequest(http.MethodPost, "/api/grade", strings.NewReader(tt.body))
+			ctx := req.Context()
+			if tt.submissionID != "" {
+				route := chi.NewRouteContext()
+				route.URLParams.Add("id", tt.submissionID)
+				ctx = context.WithValue(ctx, chi.RouteCtxKey, route)
+			}
+			if tt.session != nil {
+				ctx = context.WithValue(ctx, sessionContextKey{}, tt.session)
+			}
+			rec := httptest.NewRecorder()
+			GradeHandler(rec, req.WithContext(ctx))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantPosted {
+				if len(posted) != 1 || postedPaths[0] != "/lineitems/7/scores?type_id=3" {
+					t.Fatalf("posted %+v to %v, want one score to the line item's score service", posted, postedPaths)
+				}
+				if posted[0].UserID != "learner" || posted[0].ScoreGiven == nil || posted[0].GradingProgress != lti.GradingFullyGraded ||
+					posted[0].ActivityProgress != lti.ActivityCompleted {
+					t.Errorf("posted score = %+v", posted[0])
+				}
+			} else if len(posted) != 0 {
+				t.Errorf("posted %+v, want nothing sent to the platform", posted)
+			}
+
+			var actions []string // oldest first
+			for _, entry := range audit.List(key, "learner") {
+				actions = append([]string{entry.Action}, actions...)
+				if entry.Synced != (entry.Action == models.AuditGradeSynced) {
+					t.Errorf("%s entry synced = %v", entry.Action, entry.Synced)
+				}
+			}
+			if !tt.auditFails && strings.Join(actions, ",") != strings.Join(tt.wantActions, ",") {
+				t.Errorf("audit actions = %v, want %v", actions, tt.wantActions)
+			}
+
+			if record, ok := store.Get("sub-1"); !ok || record.Score != tt.wantScore {
+				t.Errorf("stored submission = %+v, want score %g", record, tt.wantScore)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-lti-provider/models"
+)
+
+// drainRetryAfter is what clients refused during a drain are told to wait;
+// by then another instance should be taking the traffic
+const drainRetryAfter = 5 * time.Second
+
+// LivenessHandler reports that the process is up (GET /healthz), with the
+// last dependency report for information. It is always 200, also while
+// draining or when Judge0 is down: restarting the pod would fix neither.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, healthReport(), http.StatusOK)
+}
+
+// ReadinessHandler reports whether the server should get new traffic
+// (GET /readyz): 503 as soon as shutdown starts, before the first dependency
+// check finished and while a critical check (Judge0, storage) fails.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := healthReport()
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthReport(w, report, status)
+}
+
+// healthReport is the last dependency report with the server's own state
+func healthReport() models.HealthReport {
+	report := models.HealthReport{Status: models.HealthPass, Checks: []models.HealthCheckResult{}}
+	checked := true
+	if deps.Health != nil {
+		if last := deps.Health.Report(); last != nil {
+			report = *last
+		} else {
+			report.Status, checked = models.HealthWarn, false
+		}
+	}
+
+	report.Draining = deps.Lifecycle.Draining()
+	report.Ready = checked && report.Status != models.HealthFail &&
+		(deps.Lifecycle == nil || deps.Lifecycle.Ready())
+	return report
+}
+
+func writeHealthReport(w http.ResponseWriter, report models.HealthReport, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// RejectWhileDraining refuses new work (launches, regrades) once shutdown has
+// started; requests of sessions already launched are still served
+func RejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deps.Lifecycle.Draining() {
+			slog.InfoContext(r.Context(), "⛔ Refusing request: shutting down", "method", r.Method, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(drainRetryAfter/time.Second)))
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Service is restarting, please try again", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,19 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
 )
 
+// JWKSHandler publishes the tool's public key, which platforms use to check
+// the client assertions of its token requests (the jwks_uri of registration)
 func JWKSHandler(w http.ResponseWriter, r *http.Request) {
-	// Serve public key as JWK Set
-	jwks := map[string]interface{}{
-		"keys": []map[string]interface{}{
-			{
-				"kty": "RSA",
-				"kid": "your-key-id",
-				// Add other public key information
-			},
-		},
+	set := jwk.NewSet()
+	if deps.ToolKey != nil {
+		set = deps.ToolKey.PublicSet()
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jwks)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(set)
 }
@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+
+	"go-lti-provider/config"
+	"go-lti-provider/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // LTI 1.3 OIDC Login Parameters
@@ -15,79 +21,100 @@ type OIDCLoginRequest struct {
 	LTIMessageHint  string `json:"lti_message_hint,omitempty"`
 	ClientID        string `json:"client_id,omitempty"`
 	LTIDeploymentID string `json:"lti_deployment_id,omitempty"`
+	// Frame của platform nhận postMessage storage (LTI Client Side postMessage Storage)
+	LTIStorageTarget string `json:"lti_storage_target,omitempty"`
 }
 
 // LoginHandler xử lý OIDC Initiate Login request từ Moodle
 // Đây là bước đầu tiên trong LTI 1.3 flow
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("🔐 LTI 1.3 OIDC Initiate Login received")
+	ctx := r.Context()
+	slog.InfoContext(ctx, "🔐 LTI 1.3 OIDC Initiate Login received")
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		log.Printf("❌ Error parsing form: %v", err)
+		slog.WarnContext(ctx, "❌ Error parsing form", "error", err)
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
 
 	// Extract OIDC parameters
 	loginReq := OIDCLoginRequest{
-		IssuerID:        r.FormValue("iss"),
-		LoginHint:       r.FormValue("login_hint"),
-		TargetLinkURI:   r.FormValue("target_link_uri"),
-		LTIMessageHint:  r.FormValue("lti_message_hint"),
-		ClientID:        r.FormValue("client_id"),
-		LTIDeploymentID: r.FormValue("lti_deployment_id"),
+		IssuerID:         r.FormValue("iss"),
+		LoginHint:        r.FormValue("login_hint"),
+		TargetLinkURI:    r.FormValue("target_link_uri"),
+		LTIMessageHint:   r.FormValue("lti_message_hint"),
+		ClientID:         r.FormValue("client_id"),
+		LTIDeploymentID:  r.FormValue("lti_deployment_id"),
+		LTIStorageTarget: r.FormValue("lti_storage_target"),
 	}
 
 	// Validate required parameters
 	if loginReq.IssuerID == "" || loginReq.LoginHint == "" || loginReq.TargetLinkURI == "" {
-		log.Printf("❌ Missing required OIDC parameters: iss=%s, login_hint=%s, target_link_uri=%s",
-			loginReq.IssuerID, loginReq.LoginHint, loginReq.TargetLinkURI)
+		slog.WarnContext(ctx, "❌ Missing required OIDC parameters",
+			"iss", loginReq.IssuerID,
+			"has_login_hint", loginReq.LoginHint != "",
+			"target_link_uri", loginReq.TargetLinkURI)
 		http.Error(w, "Missing required OIDC parameters", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("✅ OIDC Login - Issuer: %s, LoginHint: %s", loginReq.IssuerID, loginReq.LoginHint)
+	// Tìm platform đã đăng ký cho issuer/client_id này
+	platform, ok := findPlatform(loginReq.IssuerID, loginReq.ClientID)
+	if !ok {
+		slog.WarnContext(ctx, "❌ Unknown platform", "iss", loginReq.IssuerID, "client_id", loginReq.ClientID)
+		http.Error(w, "Unknown platform", http.StatusBadRequest)
+		return
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("lti.platform", platform.Issuer))
+	slog.InfoContext(ctx, "✅ OIDC Login", "platform", platform.Issuer, "client_id", platform.ClientID)
+
+	// State và nonce: ký trong state, kèm cookie partitioned cho trình duyệt này
+	login, stateParam, err := startLogin(w, platform, loginReq.LTIStorageTarget)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Error starting login", "error", err)
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
 
 	// Build authorization URL để redirect về Moodle
-	authURL, err := buildAuthorizationURL(loginReq)
+	authURL, err := buildAuthorizationURL(loginReq, platform, stateParam, login.Nonce)
 	if err != nil {
-		log.Printf("❌ Error building authorization URL: %v", err)
+		slog.ErrorContext(ctx, "❌ Error building authorization URL", "error", err)
 		http.Error(w, "Failed to build authorization URL", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("🔄 Redirecting to Moodle authorization: %s", authURL)
+	// Chỉ log endpoint: query có login_hint, state và nonce
+	slog.InfoContext(ctx, "🔄 Redirecting to Moodle authorization", "url", platform.AuthLoginURL,
+		"platform_storage", loginReq.LTIStorageTarget != "")
+
+	// Trong iframe, Safari chặn cả cookie partitioned: lưu state vào platform storage trước
+	if loginReq.LTIStorageTarget != "" {
+		renderStoreLoginState(w, platform, login, authURL)
+		return
+	}
 
 	// Redirect về Moodle với authorization request
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
-func buildAuthorizationURL(loginReq OIDCLoginRequest) (string, error) {
-	// Moodle local auth endpoint
-	authEndpoint := "http://localhost:8888/mod/lti/auth.php"
+func buildAuthorizationURL(loginReq OIDCLoginRequest, platform *models.PlatformRegistration, state, nonce string) (string, error) {
+	cfg := config.LoadConfig()
 
 	params := url.Values{
 		"response_type":    {"id_token"},
 		"response_mode":    {"form_post"},
 		"scope":            {"openid"},
-		"client_id":        {"wAWXk7ifY0o9tCU"},                  // LTI Tool client ID từ Moodle
-		"redirect_uri":     {"http://localhost:8080/lti/launch"}, // Launch URL của tool
+		"client_id":        {platform.ClientID},      // LTI Tool client ID từ platform
+		"redirect_uri":     {cfg.GetToolLaunchURL()}, // Launch URL của tool
 		"login_hint":       {loginReq.LoginHint},
-		"state":            {generateState()}, // Random state for security
-		"nonce":            {generateNonce()}, // Random nonce for security
+		"state":            {state},
+		"nonce":            {nonce},
 		"prompt":           {"none"},
 		"lti_message_hint": {loginReq.LTIMessageHint},
 	}
 
-	return fmt.Sprintf("%s?%s", authEndpoint, params.Encode()), nil
-}
-
-// Utility functions - trong production nên dùng crypto/rand
-func generateState() string {
-	return "random-state-123" // Demo only
-}
-
-func generateNonce() string {
-	return "random-nonce-456" // Demo only
+	return fmt.Sprintf("%s?%s", platform.AuthLoginURL, params.Encode()), nil
 }
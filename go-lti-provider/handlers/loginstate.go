@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/metrics"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+// stateCookiePrefix names the cookie that ties a launch to the browser that
+// started the login; the login state ID follows it
+const stateCookiePrefix = "lti_state_"
+
+// platformStoragePage is the data of the page that keeps the login state in
+// the platform's postMessage storage (LTI Client Side postMessage Storage),
+// for browsers that block the state cookie inside the platform's iframe
+type platformStoragePage struct {
+	Action   string `json:"action"` // put (login) or get (launch)
+	Target   string `json:"target"` // lti_storage_target: the frame to send messages to
+	Origin   string `json:"origin"` // the platform origin messages go to and come from
+	StateKey string `json:"state_key"`
+	State    string `json:"state"`
+	NonceKey string `json:"nonce_key"`
+	Nonce    string `json:"nonce"`
+
+	RedirectURL string `json:"redirect_url,omitempty"` // put: the authentication request
+
+	// get: the launch is posted again from the tool's own origin
+	LaunchURL  string `json:"-"`
+	IDToken    string `json:"-"`
+	StateParam string `json:"-"`
+}
+
+// startLogin creates the state of a login, signed for the state parameter,
+// and sets its cookie: SameSite=None so it comes back with the platform's
+// cross-site form post, Partitioned so browsers that block third-party
+// cookies still keep it inside the platform's iframe
+func startLogin(w http.ResponseWriter, platform *models.PlatformRegistration, storageTarget string) (*services.LoginState, string, error) {
+	if deps.Sessions == nil {
+		return nil, "", fmt.Errorf("no session service to sign the login state")
+	}
+	login, err := services.NewLoginState(platform.Issuer, platform.ClientID, storageTarget)
+	if err != nil {
+		return nil, "", err
+	}
+	stateParam, err := deps.Sessions.IssueLoginState(login)
+	if err != nil {
+		return nil, "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:        stateCookiePrefix + login.ID,
+		Value:       login.ID,
+		Path:        "/lti",
+		MaxAge:      int(services.LoginStateTTL.Seconds()),
+		Secure:      true,
+		HttpOnly:    true,
+		SameSite:    http.SameSiteNoneMode,
+		Partitioned: true,
+	})
+	return login, stateParam, nil
+}
+
+// renderStoreLoginState saves the login's state and nonce in the platform's
+// storage, then goes on to the authentication request
+func renderStoreLoginState(w http.ResponseWriter, platform *models.PlatformRegistration, login *services.LoginState, authURL string) {
+	page := newPlatformStoragePage("put", platform, login)
+	page.RedirectURL = authURL
+	renderPage(w, "platform_storage", "Launching…", platform, page)
+}
+
+// checkLoginState checks that a launch answers a login of this tool, for
+// this platform, in this browser: the state is signed by the tool, the
+// id_token carries its nonce (once), and the browser has the state cookie.
+// Without the cookie, when the login used platform storage, a page reads
+// the state back from the platform and posts the launch again. It reports
+// whether the launch may go on; otherwise the response has been written.
+func checkLoginState(w http.ResponseWriter, r *http.Request, claims *lti.Claims, platform *models.PlatformRegistration, idToken string) bool {
+	ctx := r.Context()
+	reject := func(reason, message string) bool {
+		slog.WarnContext(ctx, "❌ Launch state check failed", "reason", reason)
+		countLaunch(platform, metrics.LaunchInvalidState)
+		http.Error(w, message, http.StatusUnauthorized)
+		return false
+	}
+
+	if deps.Sessions == nil {
+		return reject("no_session_service", "Launch state cannot be checked")
+	}
+	stateParam := r.FormValue("state")
+	login, err := deps.Sessions.VerifyLoginState(stateParam)
+	if err != nil {
+		slog.WarnContext(ctx, "❌ Invalid launch state", "error", err)
+		return reject("invalid_state", "Invalid or expired launch state, please launch the tool again")
+	}
+	if login.Issuer != platform.Issuer || login.ClientID != platform.ClientID {
+		return reject("platform_mismatch", "Invalid launch state")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(login.Nonce)) != 1 {
+		return reject("nonce_mismatch", "Invalid launch nonce")
+	}
+
+	cookieName := stateCookiePrefix + login.ID
+	switch cookie, err := r.Cookie(cookieName); {
+	case err == nil && cookie.Value == login.ID:
+		slog.DebugContext(ctx, "🍪 Launch state checked with cookie")
+	case login.StorageTarget == "":
+		return reject("no_state_cookie", "Your browser blocked the launch cookie, please allow cookies for this tool or open it in a new window")
+	case r.FormValue("lti_storage_checked") == "":
+		// Safari & co.: đọc state từ platform storage rồi post lại launch
+		slog.InfoContext(ctx, "📦 No state cookie, checking platform storage", "target", login.StorageTarget)
+		page := newPlatformStoragePage("get", platform, login)
+		page.LaunchURL = config.LoadConfig().GetToolLaunchURL()
+		page.IDToken, page.StateParam = idToken, stateParam
+		renderPage(w, "platform_storage", "Launching…", platform, page)
+		return false
+	case !fromToolOrigin(r):
+		// Chỉ trang kiểm tra của tool (cùng origin) được post lại launch
+		return reject("storage_check_cross_origin", "Invalid launch state")
+	default:
+		slog.DebugContext(ctx, "📦 Launch state checked with platform storage")
+	}
+
+	if !services.UseNonce(login.Nonce) {
+		return reject("nonce_replayed", "This launch was already used, please launch the tool again")
+	}
+	http.SetCookie(w, &http.Cookie{Name: cookieName, Path: "/lti", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: http.SameSiteNoneMode, Partitioned: true})
+	return true
+}
+
+func newPlatformStoragePage(action string, platform *models.PlatformRegistration, login *services.LoginState) platformStoragePage {
+	return platformStoragePage{
+		Action:   action,
+		Target:   login.StorageTarget,
+		Origin:   platformStorageOrigin(platform),
+		StateKey: "state_" + login.ID,
+		State:    login.ID,
+		NonceKey: "nonce_" + login.Nonce,
+		Nonce:    login.Nonce,
+	}
+}
+
+// platformStorageOrigin is the origin of the platform's OIDC authentication
+// endpoint, which the spec says its storage frame is served from
+func platformStorageOrigin(platform *models.PlatformRegistration) string {
+	u, err := url.Parse(platform.AuthLoginURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// fromToolOrigin reports whether the browser sent r from one of the tool's
+// own pages
+func fromToolOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	u, err := url.Parse(config.LoadConfig().ToolIssuer)
+	return err == nil && u.Host != "" && strings.EqualFold(r.Header.Get("Origin"), u.Scheme+"://"+u.Host)
+}
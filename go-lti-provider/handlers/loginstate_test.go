@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+func TestCheckLoginState(t *testing.T) {
+	sessions, err := services.NewSessionService("state-secret", time.Hour, "https://tool.example.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := deps
+	deps = Dependencies{Sessions: sessions}
+	t.Cleanup(func() { deps = previous })
+
+	platform := &models.PlatformRegistration{Issuer: "https://lms.example.edu", ClientID: "tool", AuthLoginURL: "https://lms.example.edu/auth"}
+
+	type launch struct {
+		state   string
+		nonce   string
+		cookie  *http.Cookie
+		form    url.Values
+		headers map[string]string
+	}
+	// newLaunch starts a login like the login handler does and returns the launch answering it
+	newLaunch := func(t *testing.T, issuer, clientID, storageTarget string) (*services.LoginState, launch) {
+		t.Helper()
+		login, state, err := startLogin(httptest.NewRecorder(), &models.PlatformRegistration{Issuer: issuer, ClientID: clientID}, storageTarget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return login, launch{
+			state:  state,
+			nonce:  login.Nonce,
+			cookie: &http.Cookie{Name: stateCookiePrefix + login.ID, Value: login.ID},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		launch func(t *testing.T) launch
+		wantOK bool
+	}{
+		{"state, nonce and cookie", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			return l
+		}, true},
+		{"no state", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.state = ""
+			return l
+		}, false},
+		{"forged state", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.state = l.state[:len(l.state)-4] + "AAAA"
+			return l
+		}, false},
+		{"state of another platform", func(t *testing.T) launch {
+			_, l := newLaunch(t, "https://other.example.edu", platform.ClientID, "")
+			return l
+		}, false},
+		{"state of another client", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, "other-tool", "")
+			return l
+		}, false},
+		{"other nonce", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.nonce = "not-the-nonce"
+			return l
+		}, false},
+		{"no nonce", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.nonce = ""
+			return l
+		}, false},
+		{"no cookie", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.cookie = nil
+			return l
+		}, false},
+		{"cookie of another login", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			_, other := newLaunch(t, platform.Issuer, platform.ClientID, "")
+			l.cookie = other.cookie
+			return l
+		}, false},
+		{"platform storage checked by the tool's page", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "_parent")
+			l.cookie = nil
+			l.form = url.Values{"lti_storage_checked": {"1"}}
+			l.headers = map[string]string{"Sec-Fetch-Site": "same-origin"}
+			return l
+		}, true},
+		{"platform storage check posted cross-site", func(t *testing.T) launch {
+			_, l := newLaunch(t, platform.Issuer, platform.ClientID, "_parent")
+			l.cookie = nil
+			l.form = url.Values{"lti_storage_checked": {"1"}}
+			l.headers = map[string]string{"Sec-Fetch-Site": "cross-site"}
+			return l
+		}, false},
+	}
+
+	check := func(l launch) (bool, *httptest.ResponseRecorder) {
+		form := url.Values{"state": {l.state}, "id_token": {"token"}}
+		for k, v := range l.form {
+			form[k] = v
+		}
+		req := httptest.NewRequest(http.MethodPost, "/lti/launch", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range l.headers {
+			req.Header.Set(k, v)
+		}
+		if l.cookie != nil {
+			req.AddCookie(l.cookie)
+		}
+		rec := httptest.NewRecorder()
+		return checkLoginState(rec, req, &lti.Claims{Nonce: l.nonce}, platform, "token"), rec
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.launch(t)
+			ok, rec := check(l)
+			if ok != tt.wantOK {
+				t.Fatalf("checkLoginState() = %v (status %d), want %v", ok, rec.Code, tt.wantOK)
+			}
+			if !tt.wantOK {
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("status = %d, want 401", rec.Code)
+				}
+				return
+			}
+
+			// Accepted launches clear the cookie, and the same launch cannot be replayed
+			if l.cookie != nil && !strings.Contains(rec.Header().Get("Set-Cookie"), "Max-Age=0") {
+				t.Errorf("Set-Cookie = %q, want the state cookie cleared", rec.Header().Get("Set-Cookie"))
+			}
+			if ok, replay := check(l); ok || replay.Code != http.StatusUnauthorized {
+				t.Errorf("replayed launch = %v, status %d; want 401", ok, replay.Code)
+			}
+		})
+	}
+}
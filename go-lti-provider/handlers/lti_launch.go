@@ -1,20 +1,25 @@
 package handlers
 
 import (
-	"fmt"
-	"go-lti-provider/utils"
-	"log"
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/metrics"
+	"go-lti-provider/models"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // LTILaunchRedirectHandler handles LTI 1.3 launch and redirects to frontend
 func LTILaunchRedirectHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("🚀 LTI 1.3 Launch received - Redirecting to frontend")
+	ctx := r.Context()
+	slog.InfoContext(ctx, "🚀 LTI 1.3 Launch received - Redirecting to frontend")
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		log.Printf("❌ Error parsing form: %v", err)
+		slog.WarnContext(ctx, "❌ Error parsing form", "error", err)
+		countLaunch(nil, metrics.LaunchBadRequest)
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
@@ -22,43 +27,104 @@ func LTILaunchRedirectHandler(w http.ResponseWriter, r *http.Request) {
 	// Get id_token from form
 	idToken := r.FormValue("id_token")
 	if idToken == "" {
-		log.Println("❌ Missing id_token")
+		slog.WarnContext(ctx, "❌ Missing id_token")
+		countLaunch(nil, metrics.LaunchBadRequest)
 		http.Error(w, "Missing id_token", http.StatusBadRequest)
 		return
 	}
 
 	// Verify JWT and extract claims
-	claims, err := utils.VerifyJWT(idToken)
+	claims, platform, err := verifyLaunchToken(ctx, idToken)
 	if err != nil {
-		log.Printf("❌ JWT verification failed: %v", err)
+		slog.WarnContext(ctx, "❌ JWT verification failed", "error", err)
+		countLaunch(platform, metrics.LaunchInvalidToken)
 		http.Error(w, "Invalid JWT token", http.StatusUnauthorized)
 		return
 	}
 
-	// Extract required information
-	userID := claims["sub"].(string)
-	context := claims["https://purl.imsglobal.org/spec/lti/claim/context"].(map[string]interface{})
-	contextID := context["id"].(string)
+	// State và nonce của login, trong đúng trình duyệt đã login
+	if !checkLoginState(w, r, claims, platform, idToken) {
+		return
+	}
+
+	// Build the launch session: user, placement, roles and permissions
+	session := newLaunchSession(claims, platform)
+	ctx = withLaunchIDs(ctx, session)
+	if session.UserID == "" || session.ContextID == "" {
+		slog.WarnContext(ctx, "❌ Launch is missing sub or context claim")
+		countLaunch(platform, metrics.LaunchInvalidClaims)
+		http.Error(w, "Invalid LTI claims", http.StatusBadRequest)
+		return
+	}
 
-	// Extract AGS endpoint if available
-	lineitem := ""
-	if endpoint, ok := claims["https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"].(map[string]interface{}); ok {
-		if li, ok := endpoint["lineitem"].(string); ok {
-			lineitem = li
+	// Submission review: mở tool tại bài nộp của learner từ gradebook
+	if session.MessageType == lti.MessageSubmissionReview {
+		if session.ForUserID != session.UserID && !session.Can(models.PermissionViewSubmissions) {
+			slog.InfoContext(ctx, "⛔ Submission review not allowed", "for_user_id", session.ForUserID)
+			countLaunch(platform, metrics.LaunchForbidden)
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
 		}
 	}
 
-	// Build frontend redirect URL with parameters
-	feURL := fmt.Sprintf("http://localhost:3000?id_token=%s&user=%s&context=%s",
-		url.QueryEscape(idToken),
-		url.QueryEscape(userID),
-		url.QueryEscape(contextID))
+	sessionToken := ""
+	if deps.Sessions != nil {
+		sessionToken, err = deps.Sessions.Issue(session)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Failed to issue session", "error", err)
+			countLaunch(platform, metrics.LaunchError)
+			http.Error(w, "Failed to start session", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	// Add lineitem if available
-	if lineitem != "" {
-		feURL += fmt.Sprintf("&lineitem=%s", url.QueryEscape(lineitem))
+	// Moodle hiển thị trạng thái "Initialized" cho learner chưa nộp bài
+	if session.MessageType != lti.MessageSubmissionReview {
+		reportProgress(ctx, config.LoadConfig(), session, lti.ActivityInitialized)
 	}
 
-	log.Printf("✅ Redirecting to frontend: %s", feURL)
+	feURL := buildFrontendURL(session, idToken, sessionToken)
+
+	countLaunch(platform, metrics.LaunchSuccess)
+	// The frontend URL carries the id_token and session token: not logged
+	slog.InfoContext(ctx, "✅ Redirecting to frontend", "experience", session.Experience, "message_type", session.MessageType)
 	http.Redirect(w, r, feURL, http.StatusSeeOther)
 }
+
+// buildFrontendURL routes instructors and teaching assistants to the
+// instructor console and everyone else to the learner workspace. Submission
+// review launches go to the review view.
+func buildFrontendURL(session *models.LaunchSession, idToken, sessionToken string) string {
+	cfg := config.LoadConfig()
+
+	path := cfg.FrontendLearnerPath
+	if session.Experience != models.ExperienceLearner {
+		path = cfg.FrontendInstructorPath
+	}
+	if session.MessageType == lti.MessageSubmissionReview {
+		path = cfg.FrontendReviewPath
+	}
+
+	params := url.Values{
+		"id_token": {idToken},
+		"user":     {session.UserID},
+		"context":  {session.ContextID},
+		"role":     {string(session.Experience)},
+	}
+	if sessionToken != "" {
+		params.Set("session", sessionToken)
+	}
+	if session.LineItem != "" {
+		params.Set("lineitem", session.LineItem)
+	}
+	if session.ForUserID != "" {
+		params.Set("for_user", session.ForUserID)
+		if deps.Submissions != nil {
+			if latest := deps.Submissions.ListForUser(session.ResourceKey, session.ForUserID); len(latest) > 0 {
+				params.Set("submission", latest[0].ID)
+			}
+		}
+	}
+
+	return strings.TrimRight(cfg.FrontendURL, "/") + "/" + strings.TrimLeft(path, "/") + "?" + params.Encode()
+}
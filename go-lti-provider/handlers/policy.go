@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+// assignmentPolicy resolves the policy for a launch: the problem's policy,
+// the due date of the launch's line item and the launch custom parameters
+func assignmentPolicy(ctx context.Context, agsService *services.AGSService, session *models.LaunchSession, problem *models.Problem) models.AssignmentPolicy {
+	var base models.AssignmentPolicy
+	if problem != nil {
+		base = problem.Policy
+	}
+
+	lineItemURL := session.LineItem
+	if lineItemURL == "" && problem != nil {
+		lineItemURL = problem.LineItem
+	}
+
+	var lineItem *lti.LineItem
+	if lineItemURL != "" {
+		item, err := agsService.GetLineItem(ctx, lineItemURL)
+		if err != nil {
+			slog.WarnContext(ctx, "⚠️ Could not read line item", "lineitem", lineItemURL, "error", err)
+		} else {
+			lineItem = item
+		}
+	}
+
+	return services.ResolvePolicy(base, lineItem, session.Custom)
+}
+
+// userAttempts returns the session user's attempts on the resource link, newest first
+func userAttempts(session *models.LaunchSession) []models.SubmissionRecord {
+	if deps.Submissions == nil {
+		return nil
+	}
+	return deps.Submissions.ListForUser(session.ResourceKey, session.UserID)
+}
+
+// reserveAttempt checks a new graded attempt against the policy and stores
+// it as pending, atomically with the learner's other attempts
+func reserveAttempt(policy models.AssignmentPolicy, record *models.SubmissionRecord) error {
+	check := func(attempts []models.SubmissionRecord) error {
+		return services.CheckAttempt(policy, attempts, record.CreatedAt)
+	}
+	if deps.Submissions == nil {
+		return check(nil)
+	}
+	return deps.Submissions.Reserve(record, check)
+}
+
+// attemptsLeft returns how many graded attempts remain, nil when unlimited
+func attemptsLeft(policy models.AssignmentPolicy, attempts []models.SubmissionRecord) *int {
+	if policy.MaxAttempts <= 0 {
+		return nil
+	}
+	left := policy.MaxAttempts - services.CountAttempts(attempts)
+	if left < 0 {
+		left = 0
+	}
+	return &left
+}
+
+// sendPolicyViolation rejects an attempt the assignment policy does not allow
+func sendPolicyViolation(w http.ResponseWriter, r *http.Request, err error) {
+	var violation *services.PolicyViolation
+	if !errors.As(err, &violation) {
+		// Lỗi lưu attempt, không phải do policy
+		slog.ErrorContext(r.Context(), "❌ Failed to reserve the attempt", "error", err)
+		sendErrorResponse(w, "Failed to save the submission", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "⛔ Attempt rejected", "reason", violation.Reason)
+	if violation.RetryAfter > 0 {
+		sendRetryAfter(w, violation.Reason, http.StatusTooManyRequests, violation.RetryAfter)
+		return
+	}
+	sendErrorResponse(w, violation.Reason, http.StatusForbidden)
+}
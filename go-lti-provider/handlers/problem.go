@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+// ProblemHandler returns the problem of the launch's resource link as the
+// learner sees it (hidden test cases removed)
+func ProblemHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+
+	problem, ok := deps.Problems.Get(session.ResourceKey)
+	if !ok {
+		sendErrorResponse(w, "No problem configured for this activity", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(problem.PublicView())
+}
+
+// InstructorProblemHandler returns the full problem configuration, including
+// hidden test cases
+func InstructorProblemHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	problem, ok := deps.Problems.Get(session.ResourceKey)
+	if !ok {
+		sendErrorResponse(w, "No problem configured for this activity", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(problem)
+}
+
+// SaveProblemHandler lets instructors configure the problem for their resource link
+func SaveProblemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := sessionFromContext(ctx)
+
+	var problem models.Problem
+	if err := json.NewDecoder(r.Body).Decode(&problem); err != nil {
+		slog.WarnContext(ctx, "❌ Error parsing problem", "error", err)
+		sendErrorResponse(w, "Invalid problem", http.StatusBadRequest)
+		return
+	}
+
+	if problem.Title == "" {
+		sendErrorResponse(w, "Problem title is required", http.StatusBadRequest)
+		return
+	}
+	if err := problem.Policy.Validate(); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Keep the line item created earlier; create one when the resource
+	// link has no gradebook column yet
+	if existing, ok := deps.Problems.Get(session.ResourceKey); ok && problem.LineItem == "" {
+		problem.LineItem = existing.LineItem
+	}
+	if problem.LineItem == "" && session.LineItem == "" && session.LineItems != "" {
+		lineItem, err := createLineItem(ctx, session, problem)
+		if err != nil {
+			slog.WarnContext(ctx, "⚠️ Failed to create line item", "error", err)
+		} else {
+			problem.LineItem = lineItem.ID
+		}
+	}
+
+	saved, err := deps.Problems.Save(session.ResourceKey, problem, session.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to save problem", "error", err)
+		sendErrorResponse(w, "Failed to save problem", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(ctx, "✅ Problem saved", "test_cases", len(saved.TestCases))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// createLineItem adds a gradebook column for the resource link. It declares
+// submission review so instructors can open submissions from the gradebook.
+func createLineItem(ctx context.Context, session *models.LaunchSession, problem models.Problem) (*lti.LineItem, error) {
+	cfg := config.LoadConfig()
+
+	maxScore := problem.MaxScore
+	if maxScore <= 0 {
+		maxScore = 100
+	}
+
+	lineItem, err := newAGSService(cfg, session).CreateLineItem(ctx, session.LineItems, lti.LineItem{
+		Label:          problem.Title,
+		ScoreMaximum:   maxScore,
+		ResourceLinkID: session.ResourceLinkID,
+		ResourceID:     session.ResourceLinkID,
+		SubmissionReview: &lti.SubmissionReview{
+			ReachableGradingProgress: []string{lti.GradingFullyGraded, lti.GradingPendingManual, lti.GradingFailed},
+			URL:                      cfg.GetToolLaunchURL(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "📊 Line item created", "lineitem", lineItem.ID)
+	return lineItem, nil
+}
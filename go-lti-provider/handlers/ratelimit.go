@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+
+	"go-lti-provider/services"
+)
+
+// RateLimit limits requests per launch user (or per client IP for calls
+// without a launch session). Must run after WithSession.
+func RateLimit(limiter *services.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := clientIP(r)
+			if session := sessionFromContext(r.Context()); session != nil {
+				key = session.Issuer + "|" + session.UserID
+			}
+
+			if ok, retryAfter := limiter.Allow(key); !ok {
+				slog.WarnContext(r.Context(), "⛔ Rate limit exceeded", "path", r.URL.Path, "key", key)
+				sendRetryAfter(w, "Too many requests, please slow down", http.StatusTooManyRequests, retryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+// RegistrationHandler xử lý LTI Dynamic Registration request từ platform.
+// Platform mở URL này với openid_configuration và registration_token; tool
+// đăng ký chính nó với platform và lưu client_id/deployment vào registry.
+// Only platforms allowed by registrationAllowed may register.
+func RegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	slog.InfoContext(ctx, "📝 LTI Dynamic Registration request received")
+
+	if err := r.ParseForm(); err != nil {
+		slog.WarnContext(ctx, "❌ Error parsing form", "error", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	openIDConfigURL := r.FormValue("openid_configuration")
+	registrationToken := r.FormValue("registration_token")
+	if openIDConfigURL == "" {
+		slog.WarnContext(ctx, "❌ Missing openid_configuration")
+		http.Error(w, "Missing openid_configuration", http.StatusBadRequest)
+		return
+	}
+
+	cfg := config.LoadConfig()
+	if !registrationAllowed(cfg, openIDConfigURL, r.FormValue("token")) {
+		slog.WarnContext(ctx, "⛔ Dynamic registration not allowed", "openid_configuration", openIDConfigURL)
+		http.Error(w, "Registration is not allowed for this platform", http.StatusForbidden)
+		return
+	}
+
+	if deps.Platforms == nil {
+		slog.ErrorContext(ctx, "❌ Platform registry is not configured")
+		http.Error(w, "Dynamic registration is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	registrationService := services.NewRegistrationService(services.ToolInfo{
+		Name:      cfg.ToolName,
+		LoginURL:  cfg.GetToolLoginURL(),
+		LaunchURL: cfg.GetToolLaunchURL(),
+		JWKSURL:   cfg.GetToolJWKSURL(),
+		Scopes: []string{
+			"https://purl.imsglobal.org/spec/lti-ags/scope/lineitem",
+			"https://purl.imsglobal.org/spec/lti-ags/scope/lineitem.readonly",
+			"https://purl.imsglobal.org/spec/lti-ags/scope/result.readonly",
+			"https://purl.imsglobal.org/spec/lti-ags/scope/score",
+			"https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly",
+		},
+		Messages: []services.ToolMessage{
+			{Type: lti.MessageResourceLink},
+			{Type: lti.MessageSubmissionReview},
+		},
+	})
+
+	platform, err := registrationService.Register(openIDConfigURL, registrationToken)
+	if err != nil {
+		slog.ErrorContext(ctx, "❌ Dynamic registration failed", "openid_configuration", openIDConfigURL, "error", err)
+		http.Error(w, "Registration with platform failed", http.StatusBadGateway)
+		return
+	}
+
+	if err := deps.Platforms.Register(*platform); err != nil {
+		slog.ErrorContext(ctx, "❌ Failed to save platform registration", "error", err)
+		http.Error(w, "Failed to save registration", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(ctx, "✅ Registered with platform",
+		"platform", platform.Issuer, "client_id", platform.ClientID, "deployments", platform.DeploymentIDs)
+
+	renderRegistrationComplete(w, platform)
+}
+
+// renderRegistrationComplete tells the platform window the registration is done
+func renderRegistrationComplete(w http.ResponseWriter, platform *models.PlatformRegistration) {
+	renderPage(w, "registration_complete", "Registration Complete", platform, nil)
+}
+
+// registrationAllowed reports whether a registration may fetch
+// openIDConfigURL: its host is on REGISTRATION_ALLOWED_HOSTS, or the admin
+// opened the registration URL with the registration token. The URL is only
+// fetched after this check.
+func registrationAllowed(cfg *config.Config, openIDConfigURL, token string) bool {
+	if cfg.RegistrationToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.RegistrationToken)) == 1 {
+		return true
+	}
+	u, err := url.Parse(openIDConfigURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, allowed := range cfg.RegistrationAllowedHosts {
+		if matchHost(allowed, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost matches a URL against a host[:port], or *.example.edu for the
+// subdomains of example.edu on any port
+func matchHost(pattern string, u *url.URL) bool {
+	if suffix, ok := strings.CutPrefix(strings.ToLower(pattern), "*"); ok {
+		host := strings.ToLower(u.Hostname())
+		return strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return strings.EqualFold(pattern, u.Host)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/config"
+	"go-lti-provider/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RegradeRequest is the body of a regrade; all fields are optional
+type RegradeRequest struct {
+	Publish     bool `json:"publish"`               // send the new grades to the platform when done
+	Concurrency int  `json:"concurrency,omitempty"` // at most REGRADE_CONCURRENCY
+	RateLimit   *int `json:"rate_limit,omitempty"`  // grade posts per minute when publishing
+}
+
+// RegradeHandler starts running every attempt on the launch's resource link
+// against the problem's current test cases (POST /api/instructor/regrade).
+// It responds 202 with the job; progress is at /api/instructor/regrade/{id}.
+func RegradeHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	var req RegradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cfg := config.LoadConfig()
+	opts := services.RegradeOptions{
+		Publish:     req.Publish,
+		Concurrency: req.Concurrency,
+		PerMinute:   cfg.ResyncRateLimit,
+		LineItem:    session.LineItem,
+		By:          session.UserID,
+	}
+	if req.RateLimit != nil {
+		opts.PerMinute = *req.RateLimit
+	}
+
+	job, err := deps.Regrader.Start(r.Context(), newAGSService(cfg, session), session.ResourceKey, opts)
+	if errors.Is(err, services.ErrRegradeRunning) {
+		sendErrorResponse(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.InfoContext(r.Context(), "🔁 Regrade started", "regrade_job", job.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// RegradeStatusHandler returns the progress of a regrade job, or of the
+// latest one on the resource link when no ID is given
+func RegradeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	job, ok := deps.Regrader.Latest(session.ResourceKey)
+	if id := chi.URLParam(r, "id"); id != "" {
+		job, ok = deps.Regrader.Get(id)
+	}
+	if !ok || job.ResourceKey != session.ResourceKey {
+		sendErrorResponse(w, "Regrade not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
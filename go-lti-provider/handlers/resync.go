@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/config"
+	"go-lti-provider/services"
+)
+
+// ResyncRequest is the body of a grade resync; all fields are optional
+type ResyncRequest struct {
+	DryRun    bool   `json:"dry_run"`
+	RateLimit *int   `json:"rate_limit,omitempty"` // posts per minute, default RESYNC_RATE_LIMIT
+	LineItem  string `json:"lineitem,omitempty"`
+}
+
+// ResyncHandler recomputes the grades of the launch's resource link from the
+// stored submissions and re-posts the ones that differ from the platform
+// (POST /api/instructor/grades/resync). With dry_run nothing is changed and
+// the report shows what would be sent.
+func ResyncHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+
+	var req ResyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cfg := config.LoadConfig()
+	opts := services.ResyncOptions{
+		DryRun:    req.DryRun,
+		PerMinute: cfg.ResyncRateLimit,
+		LineItem:  req.LineItem,
+		By:        session.UserID,
+	}
+	if req.RateLimit != nil {
+		opts.PerMinute = *req.RateLimit
+	}
+	if opts.LineItem == "" {
+		opts.LineItem = session.LineItem
+	}
+
+	slog.InfoContext(r.Context(), "🔄 Grade resync requested", "dry_run", req.DryRun)
+
+	resync := services.NewGradeResync(deps.Submissions, deps.Problems, deps.Audit)
+	report, err := resync.Run(r.Context(), newAGSService(cfg, session), session.ResourceKey, opts)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "❌ Grade resync failed", "error", err)
+		sendErrorResponse(w, "Grade resync failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
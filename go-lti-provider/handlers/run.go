@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+// RunRequest is a practice run: the code is run with the learner's own stdin
+// and against the problem's visible sample tests
+type RunRequest struct {
+	Code     string `json:"code"`
+	Language string `json:"language"`
+	Stdin    string `json:"stdin"`
+}
+
+// RunResponse is the outcome of a practice run. Nothing is graded or stored.
+type RunResponse struct {
+	Success bool                   `json:"success"`
+	Result  *models.Judge0Response `json:"result,omitempty"`
+	Tests   []models.TestResult    `json:"tests,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// RunHandler runs code for practice. Hidden tests are never run here and no
+// grade is sent; use /api/submit for graded attempts.
+func RunHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(ctx, "❌ Error parsing run request", "error", err)
+		sendDecodeError(w, err)
+		return
+	}
+	if req.Code == "" || req.Language == "" {
+		sendErrorResponse(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if sourceTooLarge(w, req.Code) {
+		return
+	}
+
+	// RequirePermission(PermissionSubmit) đã kiểm tra session
+	session := sessionFromContext(ctx)
+	cfg := config.LoadConfig()
+	judge0Service := services.Judge0FromConfig(cfg)
+	langID := config.GetLanguageID(req.Language)
+
+	var samples []models.TestCase
+	if deps.Problems != nil {
+		if problem, ok := deps.Problems.Get(session.ResourceKey); ok {
+			samples = problem.PublicView().TestCases
+		}
+	}
+
+	reportProgress(ctx, cfg, session, lti.ActivityInProgress)
+
+	response := RunResponse{Success: true}
+
+	// Custom stdin run (also when there is nothing else to run)
+	if req.Stdin != "" || len(samples) == 0 {
+		result, err := judge0Service.RunWithInput(ctx, req.Code, langID, req.Stdin)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Judge0 error", "error", err)
+			sendExecutionError(w, err)
+			return
+		}
+		response.Result = result
+	}
+
+	if len(samples) > 0 {
+		tests, err := judge0Service.RunTests(ctx, req.Code, langID, samples)
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Judge0 error", "error", err)
+			sendExecutionError(w, err)
+			return
+		}
+		response.Tests = tests
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
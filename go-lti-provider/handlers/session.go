@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-lti-provider/config"
+	"go-lti-provider/logging"
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sessionContextKey struct{}
+
+// newLaunchSession builds the session for a verified launch, resolving the
+// user's experience and permissions from the roles claim
+func newLaunchSession(claims *lti.Claims, platform *models.PlatformRegistration) *models.LaunchSession {
+	cfg := config.LoadConfig()
+
+	experience := models.ParseRoles(claims.Roles).Experience()
+
+	taPermissions := make([]models.Permission, len(cfg.TAPermissions))
+	for i, p := range cfg.TAPermissions {
+		taPermissions[i] = models.Permission(p)
+	}
+
+	custom := make(map[string]string, len(claims.Custom))
+	for name, value := range claims.Custom {
+		custom[name] = value
+	}
+
+	session := &models.LaunchSession{
+		ResourceKey: models.ResourceKey{
+			Issuer:         platform.Issuer,
+			ContextID:      claims.ContextID(),
+			ResourceLinkID: claims.ResourceLinkID(),
+		},
+		MessageType:  claims.MessageType,
+		ClientID:     platform.ClientID,
+		DeploymentID: claims.DeploymentID,
+		UserID:       claims.Subject,
+		ForUserID:    claims.ForUserID(),
+		Name:         claims.Name,
+		ReturnURL:    safeReturnURL(claims.ReturnURL(), platform),
+		Custom:       custom,
+		Roles:        claims.Roles,
+		Experience:   experience,
+		Permissions:  models.PermissionsFor(experience, taPermissions),
+	}
+	if claims.Context != nil {
+		session.ContextTitle = claims.Context.Title
+	}
+	if claims.ResourceLink != nil {
+		session.ResourceLinkTitle = claims.ResourceLink.Title
+	}
+	if claims.AGSEndpoint != nil {
+		session.LineItem = claims.AGSEndpoint.LineItem
+		session.LineItems = claims.AGSEndpoint.LineItems
+	}
+	return session
+}
+
+// WithSession reads the launch session token from the Authorization header
+// (or the session query parameter) and puts the session in the request
+// context. Requests without a token pass through; invalid tokens are rejected.
+func WithSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("session")
+		}
+		if token == "" || deps.Sessions == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := deps.Sessions.Verify(token)
+		if err != nil {
+			slog.InfoContext(r.Context(), "❌ Session verification failed", "error", err)
+			sendErrorResponse(w, "Invalid or expired session", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), sessionContextKey{}, session)
+		next.ServeHTTP(w, r.WithContext(withLaunchIDs(ctx, session)))
+	})
+}
+
+// RequirePermission rejects requests whose session lacks the permission
+func RequirePermission(permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := sessionFromContext(r.Context())
+			if session == nil {
+				sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+				return
+			}
+			if !session.Can(permission) {
+				slog.InfoContext(r.Context(), "⛔ Permission denied", "permission", permission)
+				sendErrorResponse(w, "Permission denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withLaunchIDs adds the identifiers of the launch to the log records of ctx
+// and to the request's span
+func withLaunchIDs(ctx context.Context, session *models.LaunchSession) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("lti.platform", session.Issuer),
+		attribute.String("lti.deployment_id", session.DeploymentID),
+		attribute.String("lti.context_id", session.ContextID),
+		attribute.String("lti.resource_link_id", session.ResourceLinkID),
+		attribute.String("lti.user_id", session.UserID),
+	)
+	return logging.With(ctx,
+		"platform", session.Issuer,
+		"deployment_id", session.DeploymentID,
+		"context_id", session.ContextID,
+		"resource_link_id", session.ResourceLinkID,
+		"user_id", session.UserID,
+	)
+}
+
+// sessionFromContext returns the launch session of the request, if any
+func sessionFromContext(ctx context.Context) *models.LaunchSession {
+	session, _ := ctx.Value(sessionContextKey{}).(*models.LaunchSession)
+	return session
+}
+
+// SessionHandler returns the current launch session so the frontend can pick
+// which views and actions to show
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
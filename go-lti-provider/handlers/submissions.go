@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"go-lti-provider/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSubmissionsHandler returns the caller's own attempts on the launch's
+// resource link, newest first
+func ListSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+	if !submissionsAvailable(w) {
+		return
+	}
+
+	records := deps.Submissions.ListForUser(session.ResourceKey, session.UserID)
+	summaries := make([]models.SubmissionRecord, len(records))
+	for i, record := range records {
+		summaries[i] = record.LearnerView().Summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"submissions": summaries,
+		"attempts":    len(summaries),
+	})
+}
+
+// GetSubmissionHandler returns one attempt with its source and results.
+// Learners only see their own attempts; staff with view_submissions see any
+// attempt on their resource link, including hidden test output.
+func GetSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+	if !submissionsAvailable(w) {
+		return
+	}
+
+	record, ok := deps.Submissions.Get(chi.URLParam(r, "id"))
+	if !ok || record.ResourceKey != session.ResourceKey {
+		sendErrorResponse(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+
+	canView := session.Can(models.PermissionViewSubmissions)
+	if record.UserID != session.UserID && !canView {
+		sendErrorResponse(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if !canView {
+		*record = record.LearnerView()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// ReviewHandler returns the submissions of the learner a submission review
+// launch was opened for, newest first, with the grade changes made on them
+func ReviewHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+	if session.ForUserID == "" {
+		sendErrorResponse(w, "Not a submission review launch", http.StatusBadRequest)
+		return
+	}
+
+	// Learners may review their own work; staff need view_submissions
+	staff := session.Can(models.PermissionViewSubmissions)
+	if session.ForUserID != session.UserID && !staff {
+		sendErrorResponse(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+	if !submissionsAvailable(w) {
+		return
+	}
+
+	records := deps.Submissions.ListForUser(session.ResourceKey, session.ForUserID)
+	if !staff {
+		for i, record := range records {
+			records[i] = record.LearnerView()
+		}
+	}
+
+	entries := []models.AuditEntry{}
+	if staff && deps.Audit != nil {
+		if list := deps.Audit.List(session.ResourceKey, session.ForUserID); list != nil {
+			entries = list
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"for_user":    session.ForUserID,
+		"submissions": records,
+		"audit":       entries,
+	})
+}
+
+// AuditHandler lists the grade changes made on the resource link, optionally
+// filtered by ?user_id=
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+
+	entries := []models.AuditEntry{}
+	if deps.Audit != nil {
+		if list := deps.Audit.List(session.ResourceKey, r.URL.Query().Get("user_id")); list != nil {
+			entries = list
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// InstructorSubmissionsHandler lists all attempts on the resource link,
+// optionally filtered by ?user_id=
+func InstructorSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	session := sessionFromContext(r.Context())
+	if session == nil {
+		sendErrorResponse(w, "Launch session required", http.StatusUnauthorized)
+		return
+	}
+	if !submissionsAvailable(w) {
+		return
+	}
+
+	var records []models.SubmissionRecord
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		records = deps.Submissions.ListForUser(session.ResourceKey, userID)
+	} else {
+		records = deps.Submissions.ListForResource(session.ResourceKey)
+	}
+
+	summaries := make([]models.SubmissionRecord, len(records))
+	for i, record := range records {
+		summaries[i] = record.Summary()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"submissions": summaries,
+		"count":       len(summaries),
+	})
+}
+
+// submissionsAvailable answers 503 when no submission store is wired up
+func submissionsAvailable(w http.ResponseWriter) bool {
+	if deps.Submissions == nil {
+		slog.Error("❌ Submission store is not configured")
+		sendErrorResponse(w, "Submissions are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
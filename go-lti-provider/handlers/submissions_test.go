@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+func TestSubmissionHandlersWithoutStoreOrSession(t *testing.T) {
+	key := models.ResourceKey{Issuer: "https://lms.example.edu", ContextID: "course-1", ResourceLinkID: "link-1"}
+	staff := &models.LaunchSession{ResourceKey: key, UserID: "teacher", ForUserID: "learner",
+		Permissions: []models.Permission{models.PermissionViewSubmissions}}
+
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+		noStore int // status without a submission store
+	}{
+		{"list", ListSubmissionsHandler, http.StatusServiceUnavailable},
+		{"get", GetSubmissionHandler, http.StatusServiceUnavailable},
+		{"review", ReviewHandler, http.StatusServiceUnavailable},
+		{"audit", AuditHandler, http.StatusOK},
+		{"instructor list", InstructorSubmissionsHandler, http.StatusServiceUnavailable},
+	}
+
+	store, err := services.NewSubmissionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := deps
+	t.Cleanup(func() { deps = previous })
+
+	for _, h := range handlers {
+		t.Run(h.name, func(t *testing.T) {
+			call := func(session *models.LaunchSession) int {
+				req := httptest.NewRequest(http.MethodGet, "/api/submissions", nil)
+				if session != nil {
+					req = req.WithContext(context.WithValue(req.Context(), sessionContextKey{}, session))
+				}
+				rec := httptest.NewRecorder()
+				h.handler(rec, req)
+				return rec.Code
+			}
+
+			deps = Dependencies{Submissions: store}
+			if got := call(nil); got != http.StatusUnauthorized {
+				t.Errorf("without a session = %d, want 401", got)
+			}
+
+			deps = Dependencies{}
+			if got := call(staff); got != h.noStore {
+				t.Errorf("without a submission store = %d, want %d", got, h.noStore)
+			}
+		})
+	}
+}
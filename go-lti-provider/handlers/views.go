@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-lti-provider/models"
+)
+
+// Server-rendered pages. html/template escapes everything put into them, so
+// student code and Judge0 output are always shown as text.
+//
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pages = map[string]*template.Template{
+	"launch":                parsePage("launch.html"),
+	"registration_complete": parsePage("registration_complete.html"),
+	"platform_storage":      parsePage("platform_storage.html"),
+}
+
+// formPages are the pages that post a form back to the tool
+var formPages = map[string]bool{"platform_storage": true}
+
+func parsePage(name string) *template.Template {
+	return template.Must(template.New("layout.html").ParseFS(templateFS, "templates/layout.html", "templates/"+name))
+}
+
+// pageData is what the layout gets; Page is the data of the page itself
+type pageData struct {
+	Title string
+	Theme models.Theme
+	Nonce string
+	Page  interface{}
+}
+
+// launchPage is the data of the launch result page
+type launchPage struct {
+	User      string
+	Resource  string
+	Context   string
+	Platform  string
+	Code      string
+	Result    string
+	ReturnURL string
+}
+
+// renderPage renders a page with the platform's theme and a Content Security
+// Policy that only allows the page's own inline style and script
+func renderPage(w http.ResponseWriter, name, title string, platform *models.PlatformRegistration, page interface{}) {
+	nonce, err := newNonce()
+	if err != nil {
+		slog.Error("❌ Failed to render page", "page", name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	issuer := ""
+	if platform != nil {
+		issuer = platform.Issuer
+	}
+	data := pageData{Title: title, Theme: deps.Themes.For(issuer), Nonce: nonce, Page: page}
+
+	var buf bytes.Buffer
+	if err := pages[name].Execute(&buf, data); err != nil {
+		slog.Error("❌ Failed to render page", "page", name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(name, nonce, platform))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	w.Write(buf.Bytes())
+}
+
+// contentSecurityPolicy allows nothing but the nonced inline style and
+// script and theme logos, and forms only on formPages. Pages may only be
+// framed by the platform.
+func contentSecurityPolicy(name, nonce string, platform *models.PlatformRegistration) string {
+	formAction := "form-action 'none'"
+	if formPages[name] {
+		formAction = "form-action 'self'"
+	}
+	directives := []string{
+		"default-src 'none'",
+		fmt.Sprintf("style-src 'nonce-%s'", nonce),
+		fmt.Sprintf("script-src 'nonce-%s'", nonce),
+		"img-src 'self' https: data:",
+		"base-uri 'none'",
+		formAction,
+	}
+	if platform != nil {
+		if origins := platformOrigins(platform); len(origins) > 0 {
+			directives = append(directives, "frame-ancestors 'self' "+strings.Join(origins, " "))
+		}
+	}
+	return strings.Join(directives, "; ")
+}
+
+// platformOrigins returns the origins the platform serves pages from
+func platformOrigins(platform *models.PlatformRegistration) []string {
+	var origins []string
+	seen := make(map[string]bool)
+	for _, raw := range []string{platform.Issuer, platform.AuthLoginURL} {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			continue
+		}
+		origin := u.Scheme + "://" + u.Host
+		if !seen[origin] {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// safeReturnURL keeps a launch_presentation return_url only when it is an
+// http(s) URL on the platform's own host, so it cannot run script or send
+// the user to another site
+func safeReturnURL(raw string, platform *models.PlatformRegistration) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return ""
+	}
+	if platform == nil {
+		return u.String()
+	}
+
+	for _, origin := range platformOrigins(platform) {
+		if origin == u.Scheme+"://"+u.Host {
+			return u.String()
+		}
+	}
+	slog.Warn("⚠️ Ignoring return URL outside the platform", "host", u.Host)
+	return ""
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
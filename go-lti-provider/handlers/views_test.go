@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"html"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+func TestRenderSuccessPage(t *testing.T) {
+	platform := &models.PlatformRegistration{Issuer: "https://lms.example.edu", AuthLoginURL: "https://lms.example.edu/auth"}
+	script := "<script>alert(1)</script>"
+	stdout := script + "\n"
+	stderr := `"><img src=x onerror=alert(2)>`
+	result := formatJudge0Result(&models.Judge0Response{
+		Status: models.Status{ID: 3, Description: "Accepted"},
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+
+	tests := []struct {
+		name       string
+		returnURL  string
+		wantReturn string // "" = no return link
+	}{
+		{"javascript return URL", "javascript:alert(document.cookie)", ""},
+		{"javascript return URL with spaces and case", "  JavaScript:alert(1)", ""},
+		{"data return URL", "data:text/html,<script>alert(1)</script>", ""},
+		{"return URL on another site", "https://evil.example.com/phish", ""},
+		{"return URL on the platform", "https://lms.example.edu/course/view.php?id=2&x=1", "https://lms.example.edu/course/view.php?id=2&amp;x=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &lti.Claims{
+				Subject:            script,
+				ResourceLink:       &lti.ResourceLink{Title: script},
+				LaunchPresentation: &lti.LaunchPresentation{ReturnURL: tt.returnURL},
+			}
+			rec := httptest.NewRecorder()
+			renderSuccessPage(rec, claims, platform, "print('"+script+"')", result)
+			body := rec.Body.String()
+
+			// Student code, Judge0 output and claims are shown as text
+			if strings.Contains(body, script) || strings.Contains(body, "<img") {
+				t.Errorf("page contains unescaped markup:\n%s", body)
+			}
+			if !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+				t.Errorf("page does not show the escaped output:\n%s", body)
+			}
+
+			links := regexp.MustCompile(`<a href="([^"]*)"`).FindAllStringSubmatch(body, -1)
+			switch {
+			case tt.wantReturn == "" && len(links) > 0:
+				t.Errorf("return link %q rendered, want none", links[0][1])
+			case tt.wantReturn != "" && (len(links) != 1 || links[0][1] != tt.wantReturn):
+				t.Errorf("return links = %v, want %q", links, tt.wantReturn)
+			}
+			if strings.Contains(strings.ToLower(body), "javascript:") {
+				t.Errorf("page contains a javascript: URL:\n%s", body)
+			}
+
+			// The inline style is the only one allowed, through the nonce
+			csp := rec.Header().Get("Content-Security-Policy")
+			nonce := regexp.MustCompile(`<style nonce="([^"]+)">`).FindStringSubmatch(body)
+			if nonce == nil {
+				t.Fatalf("page has no nonced style:\n%s", body)
+			}
+			value := html.UnescapeString(nonce[1]) // the template writes + as &#43;
+			for _, directive := range []string{"default-src 'none'", "script-src 'nonce-" + value + "'", "style-src 'nonce-" + value + "'"} {
+				if !strings.Contains(csp, directive) {
+					t.Errorf("Content-Security-Policy = %q, want %q", csp, directive)
+				}
+			}
+			if !strings.Contains(csp, "frame-ancestors 'self' https://lms.example.edu") {
+				t.Errorf("Content-Security-Policy = %q, want framing by the platform only", csp)
+			}
+		})
+	}
+}
+
+func TestRenderPageNonceIsFresh(t *testing.T) {
+	nonces := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		renderSuccessPage(rec, &lti.Claims{Subject: "learner"}, nil, "", "")
+		csp := rec.Header().Get("Content-Security-Policy")
+		nonce := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(csp)
+		if nonce == nil || nonces[nonce[1]] {
+			t.Fatalf("Content-Security-Policy = %q, want a new nonce per page", csp)
+		}
+		nonces[nonce[1]] = true
+	}
+}
@@ -0,0 +1,170 @@
+// Package logging sets up structured logging with log/slog. Records logged
+// with a request context carry the request ID, the trace ID and the launch
+// identifiers (platform, context, resource link, user) put there with With. Tokens,
+// secrets and OIDC parameters are redacted from every record.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID to and from other systems
+const RequestIDHeader = "X-Request-ID"
+
+const redacted = "[REDACTED]"
+
+var level slog.LevelVar
+
+// Setup makes a slog logger writing to stderr the default logger, for slog
+// and for the log package. format is "text" or "json".
+func Setup(levelName, format string) error {
+	if err := SetLevel(levelName); err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(newHandler(os.Stderr, format)))
+	return nil
+}
+
+// SetLevel changes the minimum level logged, e.g. after a config reload
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: &level, ReplaceAttr: redact}
+	if format == "json" {
+		return contextHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, opts)}
+}
+
+type attrsKey struct{}
+type requestIDKey struct{}
+
+// With returns a context whose log records carry the given attributes, as
+// key-value pairs like slog.Info's
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append([]slog.Attr(nil), attrsFrom(ctx)...)
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+	record.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// WithRequestID returns a context carrying the request ID, for log records
+// and for requests sent on to Judge0 and the platform
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return With(ctx, "request_id", id)
+}
+
+// RequestID returns the request ID of the context, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the attributes of the record's context, and the trace
+// and span IDs when the context has a recorded span
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := attrsFrom(ctx)
+	if ctx != nil {
+		if span := trace.SpanContextFromContext(ctx); span.IsSampled() {
+			attrs = append(attrs[:len(attrs):len(attrs)],
+				slog.String("trace_id", span.TraceID().String()),
+				slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	if len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// sensitiveKeys are attributes never logged, whatever their value
+var sensitiveKeys = map[string]bool{
+	"id_token":           true,
+	"token":              true,
+	"access_token":       true,
+	"session":            true,
+	"session_token":      true,
+	"registration_token": true,
+	"authorization":      true,
+	"client_secret":      true,
+	"client_assertion":   true,
+	"secret":             true,
+	"password":           true,
+	"login_hint":         true,
+	"lti_message_hint":   true,
+	"state":              true,
+	"nonce":              true,
+}
+
+// sensitiveParams finds the same names as query or form parameters inside
+// logged text, e.g. a redirect URL or an error quoting one
+var sensitiveParams = regexp.MustCompile(`(?i)\b(id_token|access_token|session|session_token|registration_token|client_secret|client_assertion|login_hint|lti_message_hint|state|nonce|token)=[^&\s"']+`)
+
+// redact masks sensitive attributes and parameters
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); strings.Contains(s, "=") {
+			return slog.String(a.Key, Redact(s))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// Redact masks the values of sensitive parameters in s
+func Redact(s string) string {
+	return sensitiveParams.ReplaceAllStringFunc(s, func(param string) string {
+		name, _, _ := strings.Cut(param, "=")
+		return name + "=" + redacted
+	})
+}
@@ -0,0 +1,87 @@
+package lti
+
+// AGS scopes
+const (
+	ScopeLineItem         = "https://purl.imsglobal.org/spec/lti-ags/scope/lineitem"
+	ScopeLineItemReadOnly = "https://purl.imsglobal.org/spec/lti-ags/scope/lineitem.readonly"
+	ScopeResultReadOnly   = "https://purl.imsglobal.org/spec/lti-ags/scope/result.readonly"
+	ScopeScore            = "https://purl.imsglobal.org/spec/lti-ags/scope/score"
+)
+
+// AGS media types
+const (
+	MediaTypeLineItem          = "application/vnd.ims.lis.v2.lineitem+json"
+	MediaTypeLineItemContainer = "application/vnd.ims.lis.v2.lineitemcontainer+json"
+	MediaTypeResultContainer   = "application/vnd.ims.lis.v2.resultcontainer+json"
+	MediaTypeScore             = "application/vnd.ims.lis.v1.score+json"
+)
+
+// AGS activityProgress values: how far the learner is with the activity
+const (
+	ActivityInitialized = "Initialized"
+	ActivityStarted     = "Started"
+	ActivityInProgress  = "InProgress"
+	ActivitySubmitted   = "Submitted"
+	ActivityCompleted   = "Completed"
+)
+
+// AGS gradingProgress values: how far the tool is with grading
+const (
+	GradingFullyGraded   = "FullyGraded"
+	GradingPending       = "Pending"
+	GradingPendingManual = "PendingManual"
+	GradingFailed        = "Failed"
+	GradingNotReady      = "NotReady"
+)
+
+// LineItem is an AGS line item (a gradebook column) on the platform
+type LineItem struct {
+	ID               string            `json:"id,omitempty"`
+	ScoreMaximum     float64           `json:"scoreMaximum"`
+	Label            string            `json:"label"`
+	ResourceID       string            `json:"resourceId,omitempty"`
+	ResourceLinkID   string            `json:"resourceLinkId,omitempty"`
+	Tag              string            `json:"tag,omitempty"`
+	StartDateTime    string            `json:"startDateTime,omitempty"`
+	EndDateTime      string            `json:"endDateTime,omitempty"`
+	GradesReleased   *bool             `json:"gradesReleased,omitempty"`
+	SubmissionReview *SubmissionReview `json:"submissionReview,omitempty"`
+}
+
+// SubmissionReview declares that the platform may open the tool on a
+// learner's submission from the gradebook (LtiSubmissionReviewRequest)
+type SubmissionReview struct {
+	ReachableGradingProgress []string          `json:"reachableGradingProgress,omitempty"`
+	URL                      string            `json:"url,omitempty"`
+	Custom                   map[string]string `json:"custom,omitempty"`
+}
+
+// Score is a grade or progress update posted to a line item's scores
+// service. ScoreGiven and ScoreMaximum are omitted for progress updates.
+type Score struct {
+	UserID           string           `json:"userId"`
+	ScoreGiven       *float64         `json:"scoreGiven,omitempty"`
+	ScoreMaximum     *float64         `json:"scoreMaximum,omitempty"`
+	Comment          string           `json:"comment,omitempty"`
+	ActivityProgress string           `json:"activityProgress"`
+	GradingProgress  string           `json:"gradingProgress"`
+	Timestamp        string           `json:"timestamp"` // RFC 3339 with sub-second precision
+	Submission       *ScoreSubmission `json:"submission,omitempty"`
+}
+
+// ScoreSubmission carries when the learner started and submitted the attempt
+type ScoreSubmission struct {
+	StartedAt   string `json:"startedAt,omitempty"`
+	SubmittedAt string `json:"submittedAt,omitempty"`
+}
+
+// Result is a learner's current grade in a line item, as returned by the
+// AGS results service
+type Result struct {
+	ID            string   `json:"id"`
+	ScoreOf       string   `json:"scoreOf"`
+	UserID        string   `json:"userId"`
+	ResultScore   *float64 `json:"resultScore,omitempty"`
+	ResultMaximum *float64 `json:"resultMaximum,omitempty"` // 1 when omitted
+	Comment       string   `json:"comment,omitempty"`
+}
@@ -0,0 +1,287 @@
+package lti
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// LTI specification version sent in the version claim
+const Version = "1.3.0"
+
+// Message types
+const (
+	MessageResourceLink     = "LtiResourceLinkRequest"
+	MessageDeepLinking      = "LtiDeepLinkingRequest"
+	MessageDeepLinkingReply = "LtiDeepLinkingResponse"
+	MessageSubmissionReview = "LtiSubmissionReviewRequest"
+)
+
+// Claim names
+const (
+	ClaimMessageType        = "https://purl.imsglobal.org/spec/lti/claim/message_type"
+	ClaimVersion            = "https://purl.imsglobal.org/spec/lti/claim/version"
+	ClaimDeploymentID       = "https://purl.imsglobal.org/spec/lti/claim/deployment_id"
+	ClaimTargetLinkURI      = "https://purl.imsglobal.org/spec/lti/claim/target_link_uri"
+	ClaimResourceLink       = "https://purl.imsglobal.org/spec/lti/claim/resource_link"
+	ClaimRoles              = "https://purl.imsglobal.org/spec/lti/claim/roles"
+	ClaimRoleScopeMentor    = "https://purl.imsglobal.org/spec/lti/claim/role_scope_mentor"
+	ClaimContext            = "https://purl.imsglobal.org/spec/lti/claim/context"
+	ClaimToolPlatform       = "https://purl.imsglobal.org/spec/lti/claim/tool_platform"
+	ClaimLaunchPresentation = "https://purl.imsglobal.org/spec/lti/claim/launch_presentation"
+	ClaimCustom             = "https://purl.imsglobal.org/spec/lti/claim/custom"
+	ClaimLIS                = "https://purl.imsglobal.org/spec/lti/claim/lis"
+	ClaimForUser            = "https://purl.imsglobal.org/spec/lti/claim/for_user"
+	ClaimAGSEndpoint        = "https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"
+	ClaimNRPS               = "https://purl.imsglobal.org/spec/lti-nrps/claim/namesroleservice"
+	ClaimDeepLinking        = "https://purl.imsglobal.org/spec/lti-dl/claim/deep_linking_settings"
+	ClaimContentItems       = "https://purl.imsglobal.org/spec/lti-dl/claim/content_items"
+	ClaimDeepLinkingData    = "https://purl.imsglobal.org/spec/lti-dl/claim/data"
+	ClaimDeepLinkingMessage = "https://purl.imsglobal.org/spec/lti-dl/claim/msg"
+	ClaimDeepLinkingLog     = "https://purl.imsglobal.org/spec/lti-dl/claim/log"
+)
+
+// Claims is the claim set of an LTI 1.3 id_token
+type Claims struct {
+	// JWT and OpenID Connect
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub,omitempty"` // empty for anonymous launches
+	Audience        Audience `json:"aud"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+
+	// OpenID Connect profile
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"given_name,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+	MiddleName string `json:"middle_name,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Picture    string `json:"picture,omitempty"`
+	Locale     string `json:"locale,omitempty"`
+
+	// Core
+	MessageType        string              `json:"https://purl.imsglobal.org/spec/lti/claim/message_type"`
+	Version            string              `json:"https://purl.imsglobal.org/spec/lti/claim/version"`
+	DeploymentID       string              `json:"https://purl.imsglobal.org/spec/lti/claim/deployment_id"`
+	TargetLinkURI      string              `json:"https://purl.imsglobal.org/spec/lti/claim/target_link_uri,omitempty"`
+	ResourceLink       *ResourceLink       `json:"https://purl.imsglobal.org/spec/lti/claim/resource_link,omitempty"`
+	Roles              []string            `json:"https://purl.imsglobal.org/spec/lti/claim/roles"`
+	RoleScopeMentor    []string            `json:"https://purl.imsglobal.org/spec/lti/claim/role_scope_mentor,omitempty"`
+	Context            *Context            `json:"https://purl.imsglobal.org/spec/lti/claim/context,omitempty"`
+	ToolPlatform       *ToolPlatform       `json:"https://purl.imsglobal.org/spec/lti/claim/tool_platform,omitempty"`
+	LaunchPresentation *LaunchPresentation `json:"https://purl.imsglobal.org/spec/lti/claim/launch_presentation,omitempty"`
+	Custom             Custom              `json:"https://purl.imsglobal.org/spec/lti/claim/custom,omitempty"`
+	LIS                *LIS                `json:"https://purl.imsglobal.org/spec/lti/claim/lis,omitempty"`
+
+	// Submission review
+	ForUser *ForUser `json:"https://purl.imsglobal.org/spec/lti/claim/for_user,omitempty"`
+
+	// Services
+	AGSEndpoint *AGSEndpoint `json:"https://purl.imsglobal.org/spec/lti-ags/claim/endpoint,omitempty"`
+	NRPS        *NRPSClaim   `json:"https://purl.imsglobal.org/spec/lti-nrps/claim/namesroleservice,omitempty"`
+
+	// Deep linking
+	DeepLinking *DeepLinkingSettings `json:"https://purl.imsglobal.org/spec/lti-dl/claim/deep_linking_settings,omitempty"`
+}
+
+// ResourceLink is the placement of the tool that was launched
+type ResourceLink struct {
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Context is the course (or other group) the launch happens in
+type Context struct {
+	ID    string   `json:"id"`
+	Label string   `json:"label,omitempty"`
+	Title string   `json:"title,omitempty"`
+	Type  []string `json:"type,omitempty"`
+}
+
+// ToolPlatform describes the platform instance that sent the launch
+type ToolPlatform struct {
+	GUID              string `json:"guid,omitempty"`
+	Name              string `json:"name,omitempty"`
+	ContactEmail      string `json:"contact_email,omitempty"`
+	Description       string `json:"description,omitempty"`
+	URL               string `json:"url,omitempty"`
+	ProductFamilyCode string `json:"product_family_code,omitempty"`
+	Version           string `json:"version,omitempty"`
+}
+
+// LaunchPresentation says how the platform shows the tool
+type LaunchPresentation struct {
+	DocumentTarget string `json:"document_target,omitempty"` // iframe, window, embed
+	Height         int    `json:"height,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	ReturnURL      string `json:"return_url,omitempty"`
+	Locale         string `json:"locale,omitempty"`
+}
+
+// LIS carries the SIS identifiers of the user and course
+type LIS struct {
+	PersonSourcedID         string `json:"person_sourcedid,omitempty"`
+	CourseOfferingSourcedID string `json:"course_offering_sourcedid,omitempty"`
+	CourseSectionSourcedID  string `json:"course_section_sourcedid,omitempty"`
+}
+
+// ForUser is the learner whose submission is reviewed (submission review)
+type ForUser struct {
+	UserID          string   `json:"user_id"`
+	PersonSourcedID string   `json:"person_sourcedid,omitempty"`
+	GivenName       string   `json:"given_name,omitempty"`
+	FamilyName      string   `json:"family_name,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	Roles           []string `json:"roles,omitempty"`
+}
+
+// AGSEndpoint is the Assignment and Grade Services claim
+type AGSEndpoint struct {
+	Scope     []string `json:"scope,omitempty"`
+	LineItems string   `json:"lineitems,omitempty"` // line items container of the context
+	LineItem  string   `json:"lineitem,omitempty"`  // line item of the resource link, if any
+}
+
+// HasScope reports whether the platform granted an AGS scope for the launch
+func (e *AGSEndpoint) HasScope(scope string) bool {
+	if e == nil {
+		return false
+	}
+	for _, s := range e.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NRPSClaim is the Names and Role Provisioning Services claim
+type NRPSClaim struct {
+	ContextMembershipsURL string   `json:"context_memberships_url"`
+	ServiceVersions       []string `json:"service_versions,omitempty"`
+}
+
+// Audience is the aud claim, which may be a single string or a list
+type Audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("aud must be a string or a list of strings: %w", err)
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether clientID is one of the audiences
+func (a Audience) Contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// Custom holds the custom parameters of a launch. The specification sends
+// strings; other JSON values are kept in their JSON encoding.
+type Custom map[string]string
+
+// UnmarshalJSON accepts custom parameters with non-string values
+func (c *Custom) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("custom claim must be an object: %w", err)
+	}
+
+	custom := make(Custom, len(raw))
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			custom[name] = s
+			continue
+		}
+		custom[name] = string(value)
+	}
+	*c = custom
+	return nil
+}
+
+// Validate checks the claims every LTI 1.3 message must have, plus the
+// claims the message type requires
+func (c *Claims) Validate() error {
+	switch {
+	case c.Issuer == "":
+		return errors.New("missing iss claim")
+	case len(c.Audience) == 0:
+		return errors.New("missing aud claim")
+	case len(c.Audience) > 1 && c.AuthorizedParty == "":
+		return errors.New("azp claim required with multiple audiences")
+	case c.AuthorizedParty != "" && !c.Audience.Contains(c.AuthorizedParty):
+		return errors.New("azp claim is not one of the audiences")
+	case c.MessageType == "":
+		return errors.New("missing message_type claim")
+	case c.Version != Version:
+		return fmt.Errorf("unsupported LTI version %q", c.Version)
+	case c.DeploymentID == "":
+		return errors.New("missing deployment_id claim")
+	}
+
+	switch c.MessageType {
+	case MessageResourceLink:
+		if c.ResourceLink == nil || c.ResourceLink.ID == "" {
+			return errors.New("missing resource_link claim")
+		}
+	case MessageSubmissionReview:
+		if c.ForUser == nil || c.ForUser.UserID == "" {
+			return errors.New("missing for_user claim")
+		}
+	case MessageDeepLinking:
+		if c.DeepLinking == nil || c.DeepLinking.DeepLinkReturnURL == "" {
+			return errors.New("missing deep_linking_settings claim")
+		}
+	}
+	return nil
+}
+
+// ContextID returns the context ID, or "" when the launch has no context
+func (c *Claims) ContextID() string {
+	if c.Context == nil {
+		return ""
+	}
+	return c.Context.ID
+}
+
+// ResourceLinkID returns the resource link ID, or "" when there is none
+func (c *Claims) ResourceLinkID() string {
+	if c.ResourceLink == nil {
+		return ""
+	}
+	return c.ResourceLink.ID
+}
+
+// ForUserID returns the reviewed learner of a submission review, or ""
+func (c *Claims) ForUserID() string {
+	if c.ForUser == nil {
+		return ""
+	}
+	return c.ForUser.UserID
+}
+
+// ReturnURL returns the launch_presentation return_url, or ""
+func (c *Claims) ReturnURL() string {
+	if c.LaunchPresentation == nil {
+		return ""
+	}
+	return c.LaunchPresentation.ReturnURL
+}
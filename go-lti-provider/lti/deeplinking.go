@@ -0,0 +1,92 @@
+package lti
+
+// Deep linking content item types
+const (
+	ContentItemLink         = "link"
+	ContentItemResourceLink = "ltiResourceLink"
+	ContentItemFile         = "file"
+	ContentItemHTML         = "html"
+	ContentItemImage        = "image"
+)
+
+// DeepLinkingSettings is the deep_linking_settings claim of an
+// LtiDeepLinkingRequest: where to send the selection and what is accepted
+type DeepLinkingSettings struct {
+	DeepLinkReturnURL                 string   `json:"deep_link_return_url"`
+	AcceptTypes                       []string `json:"accept_types"`
+	AcceptPresentationDocumentTargets []string `json:"accept_presentation_document_targets"`
+	AcceptMediaTypes                  string   `json:"accept_media_types,omitempty"`
+	AcceptMultiple                    *bool    `json:"accept_multiple,omitempty"`
+	AcceptLineItem                    *bool    `json:"accept_lineitem,omitempty"`
+	AutoCreate                        *bool    `json:"auto_create,omitempty"`
+	Title                             string   `json:"title,omitempty"`
+	Text                              string   `json:"text,omitempty"`
+	Data                              string   `json:"data,omitempty"` // must be sent back unchanged
+}
+
+// Accepts reports whether the platform accepts a content item type
+func (s *DeepLinkingSettings) Accepts(itemType string) bool {
+	if s == nil {
+		return false
+	}
+	for _, t := range s.AcceptTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentItem is one item of an LtiDeepLinkingResponse content_items claim
+type ContentItem struct {
+	Type       string                `json:"type"`
+	Title      string                `json:"title,omitempty"`
+	Text       string                `json:"text,omitempty"`
+	URL        string                `json:"url,omitempty"`
+	Icon       *ContentItemIcon      `json:"icon,omitempty"`
+	Thumbnail  *ContentItemIcon      `json:"thumbnail,omitempty"`
+	Window     *ContentItemWindow    `json:"window,omitempty"`
+	Iframe     *ContentItemIframe    `json:"iframe,omitempty"`
+	Custom     map[string]string     `json:"custom,omitempty"`
+	LineItem   *ContentItemLineItem  `json:"lineItem,omitempty"`
+	Available  *ContentItemTimeRange `json:"available,omitempty"`
+	Submission *ContentItemTimeRange `json:"submission,omitempty"`
+}
+
+// ContentItemIcon is an icon or thumbnail of a content item
+type ContentItemIcon struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// ContentItemWindow asks the platform to open the item in a new window
+type ContentItemWindow struct {
+	TargetName     string `json:"targetName,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	WindowFeatures string `json:"windowFeatures,omitempty"`
+}
+
+// ContentItemIframe asks the platform to embed the item in an iframe
+type ContentItemIframe struct {
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+// ContentItemLineItem asks the platform to create a line item for the link
+type ContentItemLineItem struct {
+	Label            string            `json:"label,omitempty"`
+	ScoreMaximum     float64           `json:"scoreMaximum"`
+	ResourceID       string            `json:"resourceId,omitempty"`
+	Tag              string            `json:"tag,omitempty"`
+	GradesReleased   *bool             `json:"gradesReleased,omitempty"`
+	SubmissionReview *SubmissionReview `json:"submissionReview,omitempty"`
+}
+
+// ContentItemTimeRange is when a resource link is available or accepts
+// submissions (RFC 3339 times)
+type ContentItemTimeRange struct {
+	StartDateTime string `json:"startDateTime,omitempty"`
+	EndDateTime   string `json:"endDateTime,omitempty"`
+}
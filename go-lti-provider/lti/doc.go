@@ -0,0 +1,11 @@
+// Package lti has the LTI 1.3 / LTI Advantage message and service types:
+// launch claims (core, AGS, NRPS, deep linking, LIS), AGS line items, scores
+// and results, NRPS memberships and id_token verification.
+//
+// It does not depend on the rest of the tool and can be imported on its own:
+//
+//	claims, err := lti.ParseIDToken(idToken, platformKeys)
+//	if err != nil { ... }
+//	if err := claims.Validate(); err != nil { ... }
+//	log.Println(claims.Subject, claims.Context.ID, claims.Roles)
+package lti
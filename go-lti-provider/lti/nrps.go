@@ -0,0 +1,36 @@
+package lti
+
+// NRPS scope and media type
+const (
+	ScopeContextMembershipReadOnly = "https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly"
+	MediaTypeMembershipContainer   = "application/vnd.ims.lti-nrps.v2.membershipcontainer+json"
+)
+
+// Membership status values
+const (
+	MemberActive   = "Active"
+	MemberInactive = "Inactive"
+	MemberDeleted  = "Deleted"
+)
+
+// MembershipContainer is the response of the context memberships service
+type MembershipContainer struct {
+	ID      string   `json:"id"`
+	Context Context  `json:"context"`
+	Members []Member `json:"members"`
+}
+
+// Member is one user of a context and their roles in it
+type Member struct {
+	UserID             string   `json:"user_id"`
+	Status             string   `json:"status,omitempty"`
+	Name               string   `json:"name,omitempty"`
+	GivenName          string   `json:"given_name,omitempty"`
+	FamilyName         string   `json:"family_name,omitempty"`
+	MiddleName         string   `json:"middle_name,omitempty"`
+	Email              string   `json:"email,omitempty"`
+	Picture            string   `json:"picture,omitempty"`
+	LISPersonSourcedID string   `json:"lis_person_sourcedid,omitempty"`
+	LTI11LegacyUserID  string   `json:"lti11_legacy_user_id,omitempty"`
+	Roles              []string `json:"roles"`
+}
@@ -0,0 +1,72 @@
+package lti
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// ErrInvalidSignature is returned by ParseIDToken when no platform key
+// verifies the id_token's signature
+var ErrInvalidSignature = errors.New("failed to verify id_token")
+
+// TokenResponse is the OAuth2 client credentials response of the platform's
+// token endpoint, used to call AGS and NRPS
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// ParseIDToken verifies the signature of an id_token against the platform's
+// keys, checks its exp, iat and nbf and decodes the verified payload into
+// Claims. It does not call Validate or check the nonce.
+func ParseIDToken(idToken string, keys jwk.Set, opts ...jwt.ValidateOption) (*Claims, error) {
+	payload, err := jws.Verify([]byte(idToken), jws.WithKeySet(keys))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	// Time claims are checked on the verified payload
+	token, err := jwt.Parse(payload, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse id_token: %w", err)
+	}
+	if err := jwt.Validate(token, opts...); err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to decode id_token claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// PeekKeyID reads the kid header of a JWT without verifying it, to tell
+// whether the platform signed it with a key we have not fetched yet
+func PeekKeyID(idToken string) (string, error) {
+	msg, err := jws.Parse([]byte(idToken))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse JWT: %w", err)
+	}
+	if len(msg.Signatures()) == 0 {
+		return "", fmt.Errorf("JWT has no signature")
+	}
+	return msg.Signatures()[0].ProtectedHeaders().KeyID(), nil
+}
+
+// PeekIssuer reads the issuer and audience of a JWT without verifying it.
+// Only use the result to pick which platform's keys to verify the token with.
+func PeekIssuer(idToken string) (issuer string, audience []string, err error) {
+	token, err := jwt.ParseInsecure([]byte(idToken))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse JWT: %w", err)
+	}
+	return token.Issuer(), token.Audience(), nil
+}
@@ -1,96 +1,246 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
 	"go-lti-provider/config"
 	"go-lti-provider/handlers"
+	"go-lti-provider/logging"
+	"go-lti-provider/metrics"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+	"go-lti-provider/tracing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file; environment variables override it")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (secrets redacted) and exit")
+	flag.Parse()
+
 	// Load configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatal("Failed to load configuration: ", err)
+	}
+	if *printConfig {
+		fmt.Print(cfg.Redacted())
+		return
+	}
+	config.Use(cfg)
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatal("Failed to set up logging: ", err)
+	}
+	for _, warning := range cfg.Warnings() {
+		slog.Warn(warning)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		log.Fatal("Failed to set up tracing: ", err)
+	}
+	port := cfg.Port
+
+	// Platform registry: platform từ env và config file + platforms từ Dynamic Registration
+	platforms, err := services.NewPlatformRegistry(cfg.PlatformRegistryFile, services.PlatformsFromConfig(cfg)...)
+	if err != nil {
+		log.Fatal("Failed to load platform registry:", err)
+	}
+
+	// Launch sessions, problem store và lịch sử bài nộp
+	sessions, err := services.NewSessionService(cfg.SessionSecret, cfg.SessionTTL, cfg.ToolIssuer)
+	if err != nil {
+		log.Fatal("Failed to create session service:", err)
+	}
+	problems, err := services.NewProblemStore(cfg.ProblemsFile, cfg.ProblemDirs...)
+	if err != nil {
+		log.Fatal("Failed to load problems:", err)
+	}
+	submissions, err := services.NewSubmissionStore(cfg.SubmissionsDir)
+	if err != nil {
+		log.Fatal("Failed to load submissions:", err)
+	}
+	// Bài đang chấm dở khi process dừng không được tính vào số lần nộp
+	if failed, err := submissions.FailInterrupted(); err != nil {
+		log.Fatal("Failed to fail interrupted submissions:", err)
+	} else if failed > 0 {
+		slog.Warn("⚠️ Marked submissions interrupted by a restart as failed", "count", failed)
+	}
+
+	audit, err := services.NewAuditLog(cfg.AuditLogFile)
+	if err != nil {
+		log.Fatal("Failed to load audit log:", err)
+	}
+	themes, err := services.NewThemeStore(cfg.ThemesFile)
+	if err != nil {
+		log.Fatal("Failed to load themes:", err)
+	}
+	toolKey, err := services.LoadToolKey(cfg.ToolPrivateKeyFile)
+	if err != nil {
+		log.Fatal("Failed to load tool key:", err)
+	}
+	grading := services.NewGradingService(services.Judge0FromConfig(cfg), submissions)
+	resync := services.NewGradeResync(submissions, problems, audit)
+
+	lifecycle := services.NewLifecycle()
+	regrader := services.NewRegrader(grading, resync, cfg.RegradeConcurrency)
+
+	// Dependency health checks cho /healthz và /readyz; Judge0 và storage là critical
+	health := services.NewHealthChecker(cfg.HealthCheckTimeout)
+	health.Add(
+		services.Judge0HealthCheck(grading.Judge0),
+		services.StorageHealthCheck(cfg.SubmissionsDir, filepath.Dir(cfg.PlatformRegistryFile),
+			filepath.Dir(cfg.ProblemsFile), filepath.Dir(cfg.AuditLogFile)),
+		services.GradingBacklogHealthCheck(grading),
+	)
+	health.AddSource(services.PlatformHealthChecks(platforms))
+	metrics.RegisterGradingOutbox(grading.Backlog)
+	go health.Run(context.Background(), cfg.HealthCheckInterval)
+
+	handlers.Init(handlers.Dependencies{
+		Platforms:   platforms,
+		Sessions:    sessions,
+		Problems:    problems,
+		Submissions: submissions,
+		Grading:     grading,
+		Audit:       audit,
+		Regrader:    regrader,
+		Themes:      themes,
+		Lifecycle:   lifecycle,
+		Health:      health,
+		ToolKey:     toolKey,
+	})
 
-	// Set default port if not provided
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Khi shutdown: chờ chấm bài và gửi điểm xong, rồi các job chấm lại
+	lifecycle.OnDrain("grading", grading.Wait)
+	lifecycle.OnDrain("regrades", regrader.Wait)
+	lifecycle.OnDrain("traces", shutdownTracing) // span của các bước trên cũng được gửi
+
+	// Hot reload: platforms và Judge0 được thay khi config file đổi hoặc khi nhận SIGHUP
+	if *configFile != "" {
+		reloader := config.NewReloader(*configFile)
+		reloader.OnReload(func(cfg *config.Config) {
+			logging.SetLevel(cfg.LogLevel)
+			platforms.SetStatic(services.PlatformsFromConfig(cfg))
+			grading.Judge0.Reconfigure(cfg)
+		})
+		go reloader.Run(context.Background(), cfg.ConfigPollInterval)
 	}
 
-	// Create router
+	// Start server
+	slog.Info("🚀 LTI Provider", "url", "http://localhost:"+port)
+	slog.Info("⚡ Judge0", "url", cfg.GetJudge0SubmissionURL())
+	slog.Info("📝 Dynamic Registration", "url", cfg.GetToolRegistrationURL(), "platforms", len(platforms.List()))
+
+	srv := &http.Server{
+		Addr:         ":" + port,
+		Handler:      newRouter(cfg),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	err = lifecycle.Serve(srv, services.ShutdownOptions{
+		DrainDelay: cfg.DrainDelay,
+		Timeout:    cfg.ShutdownTimeout,
+	})
+	if err != nil {
+		log.Fatal("Server shutdown: ", err)
+	}
+	slog.Info("👋 Server stopped")
+}
+
+// newRouter builds the tool's routes on the handlers wired up by Init
+func newRouter(cfg *config.Config) http.Handler {
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(handlers.Tracing)
+	r.Use(handlers.RequestID)
+	r.Use(handlers.AccessLog)
 	r.Use(middleware.Recoverer)
-	r.Use(corsMiddleware(cfg.AllowedOrigins))
 
 	// LTI routes
 	r.Route("/lti", func(r chi.Router) {
-		r.Post("/login", handlers.LoginHandler)
-		r.Post("/launch", handlers.LTILaunchRedirectHandler)
-		r.Post("/grade", handlers.GradeHandler)
+		r.With(handlers.RejectWhileDraining).Post("/login", handlers.LoginHandler)
+		r.With(handlers.RejectWhileDraining).Post("/launch", handlers.LTILaunchRedirectHandler)
+		r.With(handlers.WithSession, handlers.RequirePermission(models.PermissionOverrideGrade)).
+			Post("/grade", handlers.GradeHandler)
+		r.Get("/register", handlers.RegistrationHandler)
 	})
 
-	// API routes
+	// Practice runs và graded submits có rate limit riêng; admission chung
+	// cho cả hai bảo vệ Judge0 (theo course, toàn tool, hàng đợi, circuit breaker)
+	runLimiter := services.NewRateLimiter(cfg.RunRateLimit, time.Minute)
+	submitLimiter := services.NewRateLimiter(cfg.SubmitRateLimit, time.Minute)
+	admission := services.NewAdmission(cfg.ContextRateLimit, cfg.GlobalRateLimit)
+
+	// API routes: CORS chỉ cho frontend (ALLOWED_ORIGINS); JWKS bên dưới công khai,
+	// login/launch là form post của platform nên không cần CORS
 	r.Route("/api", func(r chi.Router) {
-		r.Post("/execute", handlers.ExecuteHandler)
-	})
+		r.Use(handlers.CORS(handlers.APICORSPolicy))
+		r.Use(handlers.WithSession)
 
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+		r.With(handlers.RequirePermission(models.PermissionSubmit), handlers.RateLimit(runLimiter), handlers.AdmitExecution(admission)).
+			Post("/run", handlers.RunHandler)
+		r.With(handlers.RequirePermission(models.PermissionSubmit), handlers.RateLimit(submitLimiter), handlers.AdmitExecution(admission)).
+			Post("/submit", handlers.ExecuteHandler)
+		r.With(handlers.RequirePermission(models.PermissionSubmit), handlers.RateLimit(submitLimiter), handlers.AdmitExecution(admission)).
+			Post("/execute", handlers.ExecuteHandler) // deprecated, same as /submit
+		r.Get("/session", handlers.SessionHandler)
+		r.Get("/problem", handlers.ProblemHandler)
+		r.Get("/submissions", handlers.ListSubmissionsHandler)
+		r.Get("/submissions/{id}", handlers.GetSubmissionHandler)
+		r.Get("/executions/{id}/events", handlers.ExecutionEventsHandler) // SSE tiến độ chấm bài
+		r.Get("/executions/{id}/ws", handlers.ExecutionSocketHandler)     // same over WebSocket
+		r.Get("/review", handlers.ReviewHandler)
+
+		// Instructor console
+		r.Route("/instructor", func(r chi.Router) {
+			r.With(handlers.RequirePermission(models.PermissionConfigureProblem)).
+				Get("/problem", handlers.InstructorProblemHandler)
+			r.With(handlers.RequirePermission(models.PermissionConfigureProblem)).
+				Put("/problem", handlers.SaveProblemHandler)
+			r.With(handlers.RequirePermission(models.PermissionViewSubmissions)).
+				Get("/submissions", handlers.InstructorSubmissionsHandler)
+			r.With(handlers.RequirePermission(models.PermissionOverrideGrade)).
+				Post("/submissions/{id}/grade", handlers.GradeHandler)
+			r.With(handlers.RequirePermission(models.PermissionViewSubmissions)).
+				Get("/audit", handlers.AuditHandler)
+			r.With(handlers.RequirePermission(models.PermissionOverrideGrade)).
+				Post("/grades/resync", handlers.ResyncHandler)
+			r.With(handlers.RequirePermission(models.PermissionOverrideGrade), handlers.RejectWhileDraining).
+				Post("/regrade", handlers.RegradeHandler)
+			r.With(handlers.RequirePermission(models.PermissionViewSubmissions)).
+				Get("/regrade", handlers.RegradeStatusHandler)
+			r.With(handlers.RequirePermission(models.PermissionViewSubmissions)).
+				Get("/regrade/{id}", handlers.RegradeStatusHandler)
+		})
 	})
 
-	// JWKS endpoint
-	r.Get("/.well-known/jwks.json", handlers.JWKSHandler)
+	// Health checks: liveness và readiness riêng cho Kubernetes, kèm báo cáo từng dependency
+	r.Get("/health", handlers.LivenessHandler)
+	r.Get("/healthz", handlers.LivenessHandler)
+	r.Get("/readyz", handlers.ReadinessHandler)
 
-	// Start server
-	log.Printf("🚀 LTI Provider: http://localhost:%s", port)
-	log.Printf("⚡ Judge0: %s", cfg.GetJudge0SubmissionURL())
+	// Prometheus metrics
+	r.Method("GET", "/metrics", metrics.Handler())
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatal("Failed to start server:", err)
-	}
-}
+	// JWKS endpoint
+	r.Route("/.well-known", func(r chi.Router) {
+		r.Use(handlers.CORS(handlers.PublicCORSPolicy))
+		r.Get("/jwks.json", handlers.JWKSHandler)
+	})
 
-// corsMiddleware adds CORS headers
-func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-
-			// Check if origin is allowed
-			allowed := false
-			for _, allowedOrigin := range allowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
-				}
-			}
-
-			if allowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
+	return r
 }
 
 // // formatJSON formats map as JSON string (simple implementation)
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/handlers"
+	"go-lti-provider/lti"
+	"go-lti-provider/mockplatform"
+	"go-lti-provider/models"
+	"go-lti-provider/services"
+)
+
+// TestLaunchSubmitGrade runs a learner through the whole flow against the mock
+// platform and a fake Judge0: OIDC login, launch, graded submit, and the
+// score published to the platform's gradebook with a client assertion
+func TestLaunchSubmitGrade(t *testing.T) {
+	dir := t.TempDir()
+
+	// Fake Judge0: every run prints its output and is accepted
+	var judge0Runs atomic.Int32
+	judge0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/submissions" {
+			http.NotFound(w, r)
+			return
+		}
+		judge0Runs.Add(1)
+		stdout := "Hello, World!\n"
+		json.NewEncoder(w).Encode(models.Judge0Response{Token: "run-1", Status: models.Status{ID: 3, Description: "Accepted"}, Stdout: &stdout})
+	}))
+	defer judge0.Close()
+
+	// The tool over TLS: the state cookie is Secure
+	var router http.Handler
+	tool := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	defer tool.Close()
+
+	// The tool does not know the client secret: its token requests only
+	// succeed with a client assertion
+	platform, err := mockplatform.NewServer(mockplatform.Config{
+		ClientID:      "tool-client",
+		ClientSecret:  "platform-only-secret",
+		DeploymentID:  "1",
+		ToolLoginURL:  tool.URL + "/lti/login",
+		ToolLaunchURL: tool.URL + "/lti/launch",
+		TokenTTL:      time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer platform.Close()
+	lineItem := platform.AddLineItem("course-1", mockplatform.LineItem{ID: "7", Label: "Hello", ScoreMaximum: 10})
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ToolIssuer = tool.URL
+	cfg.Judge0URL = judge0.URL
+	cfg.SubmissionsDir = filepath.Join(dir, "submissions")
+	cfg.Platforms = []config.PlatformConfig{{
+		Issuer:       platform.Config().Issuer,
+		ClientID:     "tool-client",
+		AuthLoginURL: platform.AuthURL(),
+		TokenURL:     platform.TokenURL(),
+		JWKSURL:      platform.JWKSURL(),
+	}}
+	previous := config.LoadConfig()
+	config.Use(cfg)
+	t.Cleanup(func() { config.Use(previous) })
+
+	platforms, err := services.NewPlatformRegistry(filepath.Join(dir, "platforms.json"), services.PlatformsFromConfig(cfg)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := services.NewSessionService("", cfg.SessionTTL, cfg.ToolIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submissions, err := services.NewSubmissionStore(cfg.SubmissionsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolKey, err := services.LoadToolKey(filepath.Join(dir, "tool_key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grading := services.NewGradingService(services.Judge0FromConfig(cfg), submissions)
+	handlers.Init(handlers.Dependencies{
+		Platforms:   platforms,
+		Sessions:    sessions,
+		Submissions: submissions,
+		Grading:     grading,
+		Lifecycle:   services.NewLifecycle(),
+		ToolKey:     toolKey,
+	})
+	t.Cleanup(func() { handlers.Init(handlers.Dependencies{}) })
+	router = newRouter(cfg)
+
+	// The browser: keeps cookies, and the test follows each redirect itself
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := tool.Client()
+	browser.Jar = jar
+	browser.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// 1. The platform starts the OIDC login at the tool
+	login := platform.Initiate(mockplatform.Launch{
+		UserID:         "learner-1",
+		Roles:          []string{mockplatform.RoleLearner},
+		ContextID:      "course-1",
+		ResourceLinkID: "link-1",
+		LineItemID:     lineItem.ID,
+	})
+	resp, err := browser.PostForm(tool.URL+"/lti/login", login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login status = %d, want a redirect to the platform", resp.StatusCode)
+	}
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(authURL.String(), platform.AuthURL()) {
+		t.Fatalf("login redirects to %q, want the platform's auth URL", resp.Header.Get("Location"))
+	}
+
+	// 2. The platform authenticates the learner and posts the id_token back
+	auth, err := platform.Authorize(authURL.Query())
+	if err != nil {
+		t.Fatalf("platform rejected the authentication request: %v", err)
+	}
+	resp, err = browser.PostForm(auth.RedirectURI, auth.Form())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("launch status = %d, want a redirect to the frontend", resp.StatusCode)
+	}
+	frontend, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := frontend.Query().Get("session")
+	if session == "" || frontend.Query().Get("lineitem") != platform.LineItemURL("course-1", lineItem.ID) {
+		t.Fatalf("frontend URL = %s, want the session and line item", frontend)
+	}
+
+	// The same id_token and state cannot be launched again
+	resp, err = browser.PostForm(auth.RedirectURI, auth.Form())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("replayed launch status = %d, want 401", resp.StatusCode)
+	}
+
+	// 3. The learner submits; identity and line item come from the session
+	submit := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, tool.URL+"/api/submit",
+			strings.NewReader(`{"code":"print('Hello, World!')","language":"python","max_score":10}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := browser.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp = submit("")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("submit without a session = %d, want 401", resp.StatusCode)
+	}
+
+	resp = submit(session)
+	var result handlers.ExecuteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !result.Success || result.Status != models.SubmissionCompleted {
+		t.Fatalf("submit = %d %+v", resp.StatusCode, result)
+	}
+	if result.Score != 10 || result.Grade != 10 || judge0Runs.Load() != 1 {
+		t.Errorf("score, grade = %g, %g after %d runs; want 10, 10 after 1", result.Score, result.Grade, judge0Runs.Load())
+	}
+
+	// 4. The grade reaches the platform's gradebook
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := grading.Wait(ctx); err != nil {
+		t.Fatalf("grading did not finish: %v", err)
+	}
+
+	var graded *mockplatform.Score
+	for _, score := range platform.Scores(lineItem.ID) {
+		if score.UserID != "learner-1" {
+			t.Errorf("score published for %q, want learner-1", score.UserID)
+		}
+		if score.ActivityProgress == lti.ActivityCompleted {
+			graded = &score
+		}
+	}
+	if graded == nil {
+		t.Fatalf("no completed score published; scores = %+v", platform.Scores(lineItem.ID))
+	}
+	if graded.GradingProgress != lti.GradingFullyGraded || graded.ScoreGiven == nil || *graded.ScoreGiven != 10 ||
+		graded.ScoreMaximum == nil || *graded.ScoreMaximum != 10 {
+		t.Errorf("published score = %+v", graded)
+	}
+
+	stored := submissions.ListForUser(models.ResourceKey{Issuer: platform.Config().Issuer, ContextID: "course-1", ResourceLinkID: "link-1"}, "learner-1")
+	if len(stored) != 1 || stored[0].GradingProgress != lti.GradingFullyGraded {
+		t.Errorf("stored submissions = %+v", stored)
+	}
+}
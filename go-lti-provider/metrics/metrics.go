@@ -0,0 +1,143 @@
+// Package metrics holds the Prometheus metrics served on /metrics. Latency
+// of Judge0 runs and of calls to the platform (AGS, token endpoint) are
+// recorded separately, so slow grading can be traced to one or the other.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lti_provider"
+
+// Launch outcomes
+const (
+	LaunchSuccess       = "success"
+	LaunchBadRequest    = "bad_request"   // no form or no id_token
+	LaunchInvalidToken  = "invalid_token" // see JWTFailures for why
+	LaunchInvalidClaims = "invalid_claims"
+	LaunchInvalidState  = "invalid_state" // state, nonce or state cookie
+	LaunchForbidden     = "forbidden"
+	LaunchError         = "error"
+)
+
+// UnknownPlatform labels launches whose token did not name a registered
+// platform, so unverified issuers cannot grow the label set
+const UnknownPlatform = "unknown"
+
+var (
+	// Launches counts LTI launches by platform issuer and outcome
+	Launches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "launches_total",
+		Help:      "LTI launches by platform issuer and outcome.",
+	}, []string{"platform", "outcome"})
+
+	// JWTFailures counts id_tokens rejected, by reason
+	JWTFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jwt_validation_failures_total",
+		Help:      "Rejected launch id_tokens by reason.",
+	}, []string{"reason"})
+
+	// Judge0Duration is the time of one Judge0 run, from POST to result
+	Judge0Duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "judge0_request_duration_seconds",
+		Help:      "Duration of Judge0 runs (POST /submissions?wait=true) by language ID.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 8, 13, 21, 30, 60},
+	}, []string{"language"})
+
+	// Judge0Verdicts counts Judge0 runs by language and status ("error"
+	// when Judge0 could not be reached or answered with an error)
+	Judge0Verdicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "judge0_verdicts_total",
+		Help:      "Judge0 runs by language ID and verdict.",
+	}, []string{"language", "verdict"})
+
+	// ExecutionsInFlight is the number of runs waiting on Judge0
+	ExecutionsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "executions_in_flight",
+		Help:      "Judge0 runs in progress.",
+	})
+
+	// ExecutionsRejected counts runs and submits refused before reaching
+	// Judge0, by reason (see services.Admission)
+	ExecutionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_rejected_total",
+		Help:      "Runs and submits refused by admission control by reason.",
+	}, []string{"reason"})
+
+	// Judge0CircuitOpen is 1 while Judge0 is not called because of failures
+	Judge0CircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "judge0_circuit_open",
+		Help:      "1 while the Judge0 circuit breaker is open.",
+	})
+
+	// AGSRequests counts calls to the platform's AGS and token endpoints
+	AGSRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ags_requests_total",
+		Help:      "Calls to the platform's AGS and token endpoints by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	// AGSDuration is the time of one call to the platform
+	AGSDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ags_request_duration_seconds",
+		Help:      "Duration of calls to the platform's AGS and token endpoints by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// TokenCache counts lookups of cached AGS access tokens ("hit", "miss")
+	TokenCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_cache_requests_total",
+		Help:      "AGS access token cache lookups by result (hit, miss).",
+	}, []string{"result"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterGradingOutbox reports depth as the number of gradings and
+// gradebook updates not yet sent to the platform
+func RegisterGradingOutbox(depth func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "grading_outbox_depth",
+		Help:      "Gradings and gradebook updates waiting to be sent to the platform.",
+	}, func() float64 { return float64(depth()) })
+}
+
+// ObserveJudge0 records one Judge0 run that started at start. verdict is
+// Judge0's status description, or "" when the run failed.
+func ObserveJudge0(languageID int, start time.Time, verdict string) {
+	language := strconv.Itoa(languageID)
+	if verdict == "" {
+		verdict = "error"
+	}
+	Judge0Duration.WithLabelValues(language).Observe(time.Since(start).Seconds())
+	Judge0Verdicts.WithLabelValues(language, verdict).Inc()
+}
+
+// ObserveAGS records one call to the platform that started at start
+func ObserveAGS(operation string, start time.Time, ok bool) {
+	outcome := "success"
+	if !ok {
+		outcome = "failure"
+	}
+	AGSDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	AGSRequests.WithLabelValues(operation, outcome).Inc()
+}
@@ -0,0 +1,315 @@
+package mockplatform
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// LTI message types the mock platform can issue
+const (
+	ResourceLinkRequest     = "LtiResourceLinkRequest"
+	DeepLinkingRequest      = "LtiDeepLinkingRequest"
+	SubmissionReviewRequest = "LtiSubmissionReviewRequest"
+)
+
+// Common LIS role URIs
+const (
+	RoleLearner    = "http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"
+	RoleInstructor = "http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor"
+	RoleTA         = "http://purl.imsglobal.org/vocab/lis/v2/membership/Instructor#TeachingAssistant"
+	RoleAdmin      = "http://purl.imsglobal.org/vocab/lis/v2/system/person#Administrator"
+)
+
+const (
+	claimPrefix    = "https://purl.imsglobal.org/spec/lti/claim/"
+	agsClaim       = "https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"
+	nrpsClaim      = "https://purl.imsglobal.org/spec/lti-nrps/claim/namesroleservice"
+	deepLinkClaim  = "https://purl.imsglobal.org/spec/lti-dl/claim/deep_linking_settings"
+	agsScopePrefix = "https://purl.imsglobal.org/spec/lti-ags/scope/"
+	nrpsScope      = "https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly"
+)
+
+// Launch describes a message the platform will sign and deliver to the tool
+type Launch struct {
+	MessageType string // defaults to LtiResourceLinkRequest
+
+	UserID     string
+	Name       string
+	GivenName  string
+	FamilyName string
+	Email      string
+	Roles      []string
+
+	ContextID    string
+	ContextLabel string
+	ContextTitle string
+
+	ResourceLinkID    string
+	ResourceLinkTitle string
+
+	TargetLinkURI string
+	ReturnURL     string
+	Custom        map[string]string
+
+	// LineItemID links the launch to an AGS line item; when empty but
+	// WithAGS is set, only the line item container is advertised
+	LineItemID string
+	WithAGS    bool
+	WithNRPS   bool
+
+	// Deep linking
+	DeepLinkReturnURL string
+	AcceptTypes       []string
+
+	// Submission review: the learner whose work is being reviewed
+	ForUserID string
+
+	// Claims are merged last and override anything generated above
+	Claims map[string]interface{}
+
+	// ExpiresIn controls the token lifetime (default one minute, like Moodle)
+	ExpiresIn time.Duration
+}
+
+// Claims builds the full claim set for a launch
+func (p *Platform) Claims(l Launch, nonce string) map[string]interface{} {
+	cfg := p.Config()
+
+	messageType := l.MessageType
+	if messageType == "" {
+		messageType = ResourceLinkRequest
+	}
+	targetLinkURI := l.TargetLinkURI
+	if targetLinkURI == "" {
+		targetLinkURI = cfg.ToolLaunchURL
+	}
+	roles := l.Roles
+	if roles == nil {
+		roles = []string{RoleLearner}
+	}
+	expiresIn := l.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = time.Minute
+	}
+	now := time.Now()
+
+	claims := map[string]interface{}{
+		"iss":   cfg.Issuer,
+		"sub":   l.UserID,
+		"aud":   []string{cfg.ClientID},
+		"azp":   cfg.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(expiresIn).Unix(),
+		"nonce": nonce,
+
+		claimPrefix + "message_type":    messageType,
+		claimPrefix + "version":         "1.3.0",
+		claimPrefix + "deployment_id":   cfg.DeploymentID,
+		claimPrefix + "target_link_uri": targetLinkURI,
+		claimPrefix + "roles":           roles,
+		claimPrefix + "tool_platform": map[string]interface{}{
+			"name":                "Mock Moodle",
+			"product_family_code": "moodle",
+			"version":             "mock",
+			"guid":                cfg.Issuer,
+		},
+	}
+
+	if l.Name != "" {
+		claims["name"] = l.Name
+	}
+	if l.GivenName != "" {
+		claims["given_name"] = l.GivenName
+	}
+	if l.FamilyName != "" {
+		claims["family_name"] = l.FamilyName
+	}
+	if l.Email != "" {
+		claims["email"] = l.Email
+	}
+
+	if l.ContextID != "" {
+		claims[claimPrefix+"context"] = map[string]interface{}{
+			"id":    l.ContextID,
+			"label": l.ContextLabel,
+			"title": l.ContextTitle,
+			"type":  []string{"http://purl.imsglobal.org/vocab/lis/v2/course#CourseOffering"},
+		}
+	}
+	if l.ResourceLinkID != "" {
+		claims[claimPrefix+"resource_link"] = map[string]interface{}{
+			"id":    l.ResourceLinkID,
+			"title": l.ResourceLinkTitle,
+		}
+	}
+	if l.ReturnURL != "" {
+		claims[claimPrefix+"launch_presentation"] = map[string]interface{}{
+			"document_target": "iframe",
+			"return_url":      l.ReturnURL,
+		}
+	}
+	if len(l.Custom) > 0 {
+		claims[claimPrefix+"custom"] = l.Custom
+	}
+
+	if (l.WithAGS || l.LineItemID != "") && l.ContextID != "" {
+		endpoint := map[string]interface{}{
+			"scope": []string{
+				agsScopePrefix + "lineitem",
+				agsScopePrefix + "lineitem.readonly",
+				agsScopePrefix + "result.readonly",
+				agsScopePrefix + "score",
+			},
+			"lineitems": p.LineItemsURL(l.ContextID),
+		}
+		if l.LineItemID != "" {
+			endpoint["lineitem"] = p.LineItemURL(l.ContextID, l.LineItemID)
+		}
+		claims[agsClaim] = endpoint
+	}
+	if l.WithNRPS && l.ContextID != "" {
+		claims[nrpsClaim] = map[string]interface{}{
+			"context_memberships_url": p.MembershipsURL(l.ContextID),
+			"service_versions":        []string{"2.0"},
+		}
+	}
+
+	switch messageType {
+	case DeepLinkingRequest:
+		returnURL := l.DeepLinkReturnURL
+		if returnURL == "" {
+			returnURL = cfg.BaseURL + "/mod/lti/contentitem_return.php"
+		}
+		acceptTypes := l.AcceptTypes
+		if acceptTypes == nil {
+			acceptTypes = []string{"ltiResourceLink"}
+		}
+		claims[deepLinkClaim] = map[string]interface{}{
+			"deep_link_return_url":                 returnURL,
+			"accept_types":                         acceptTypes,
+			"accept_presentation_document_targets": []string{"iframe", "window"},
+			"accept_multiple":                      true,
+			"auto_create":                          true,
+		}
+		delete(claims, claimPrefix+"resource_link")
+	case SubmissionReviewRequest:
+		claims[claimPrefix+"for_user"] = map[string]interface{}{
+			"user_id": l.ForUserID,
+			"roles":   []string{RoleLearner},
+		}
+	}
+
+	for name, value := range l.Claims {
+		claims[name] = value
+	}
+	return claims
+}
+
+// SignLaunch returns a signed id_token for a launch
+func (p *Platform) SignLaunch(l Launch, nonce string) (string, error) {
+	return p.Sign(p.Claims(l, nonce))
+}
+
+// Initiate registers a pending launch and returns the OIDC third-party
+// initiated login parameters the platform would POST to the tool's login URL
+func (p *Platform) Initiate(l Launch) url.Values {
+	cfg := p.Config()
+	messageHint := randomID(12)
+
+	p.mu.Lock()
+	p.launches[messageHint] = l
+	p.mu.Unlock()
+
+	targetLinkURI := l.TargetLinkURI
+	if targetLinkURI == "" {
+		targetLinkURI = cfg.ToolLaunchURL
+	}
+
+	return url.Values{
+		"iss":               {cfg.Issuer},
+		"login_hint":        {l.UserID},
+		"target_link_uri":   {targetLinkURI},
+		"lti_message_hint":  {messageHint},
+		"client_id":         {cfg.ClientID},
+		"lti_deployment_id": {cfg.DeploymentID},
+	}
+}
+
+// AuthResponse is what auth.php form-posts back to the tool
+type AuthResponse struct {
+	RedirectURI string
+	IDToken     string
+	State       string
+}
+
+// Form returns the response as form values for the tool's launch endpoint
+func (r AuthResponse) Form() url.Values {
+	return url.Values{"id_token": {r.IDToken}, "state": {r.State}}
+}
+
+// Authorize validates an OIDC authentication request (the query auth.php
+// receives from the tool) and signs the pending launch it refers to
+func (p *Platform) Authorize(params url.Values) (*AuthResponse, error) {
+	cfg := p.Config()
+
+	if params.Get("scope") != "openid" {
+		return nil, errors.New("invalid scope")
+	}
+	if params.Get("response_type") != "id_token" {
+		return nil, errors.New("unsupported response_type")
+	}
+	if params.Get("client_id") != cfg.ClientID {
+		return nil, fmt.Errorf("unknown client_id %q", params.Get("client_id"))
+	}
+	if params.Get("nonce") == "" {
+		return nil, errors.New("missing nonce")
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if !p.redirectAllowed(redirectURI) {
+		return nil, fmt.Errorf("redirect_uri %q is not registered", redirectURI)
+	}
+
+	p.mu.Lock()
+	launch, ok := p.launches[params.Get("lti_message_hint")]
+	if ok {
+		delete(p.launches, params.Get("lti_message_hint"))
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, errors.New("unknown or already used lti_message_hint")
+	}
+	if params.Get("login_hint") != launch.UserID {
+		return nil, errors.New("login_hint does not match the launching user")
+	}
+
+	idToken, err := p.SignLaunch(launch, params.Get("nonce"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		RedirectURI: redirectURI,
+		IDToken:     idToken,
+		State:       params.Get("state"),
+	}, nil
+}
+
+func (p *Platform) redirectAllowed(redirectURI string) bool {
+	cfg := p.Config()
+	if redirectURI == "" {
+		return false
+	}
+	if redirectURI == cfg.ToolLaunchURL {
+		return true
+	}
+	for _, allowed := range cfg.RedirectURIs {
+		if redirectURI == allowed {
+			return true
+		}
+	}
+	return false
+}
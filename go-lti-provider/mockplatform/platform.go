@@ -0,0 +1,212 @@
+// Package mockplatform implements a fake LTI 1.3 platform that behaves like
+// Moodle's mod/lti endpoints. It lets the tool's login, launch, execution and
+// AGS flows run end-to-end without the Moodle docker-compose stack.
+package mockplatform
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// Moodle-style endpoint paths served by the mock platform
+const (
+	JWKSPath     = "/mod/lti/certs.php"
+	AuthPath     = "/mod/lti/auth.php"
+	TokenPath    = "/mod/lti/token.php"
+	ServicesPath = "/mod/lti/services.php"
+	LaunchPath   = "/mod/lti/launch.php"
+
+	OpenIDConfigurationPath = "/mod/lti/openid-configuration.php"
+	RegistrationPath        = "/mod/lti/openid-registration.php"
+)
+
+// Config describes the platform and the single tool registered with it
+type Config struct {
+	// Issuer is the platform issuer; defaults to the server base URL
+	Issuer string
+	// BaseURL is where the platform endpoints are reachable
+	BaseURL string
+
+	ClientID     string
+	ClientSecret string // optional; when empty any secret is accepted
+	DeploymentID string
+
+	// Tool endpoints registered with the platform
+	ToolLoginURL  string
+	ToolLaunchURL string
+	// RedirectURIs allowed in auth.php; ToolLaunchURL is always allowed
+	RedirectURIs []string
+
+	TokenTTL time.Duration
+
+	// RegistrationToken is required by the dynamic registration endpoint when set
+	RegistrationToken string
+}
+
+// Platform is a fake LTI 1.3 platform. All methods are safe for concurrent use.
+type Platform struct {
+	cfg Config
+
+	privateKey jwk.Key
+	publicSet  jwk.Set
+
+	mu        sync.Mutex
+	launches  map[string]Launch // lti_message_hint -> pending launch
+	tokens    map[string]accessToken
+	lineItems map[string]*LineItem // lineitem id -> item
+	itemOrder []string
+	scores    []Score
+	members   map[string][]Member // context id -> members
+	calls     []Call
+
+	registrations []map[string]interface{}
+}
+
+type accessToken struct {
+	scopes    []string
+	expiresAt time.Time
+}
+
+// New creates a platform with a freshly generated RSA signing key
+func New(cfg Config) (*Platform, error) {
+	if cfg.ClientID == "" {
+		cfg.ClientID = "mock-client-id"
+	}
+	if cfg.DeploymentID == "" {
+		cfg.DeploymentID = "1"
+	}
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = time.Hour
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Issuer == "" {
+		cfg.Issuer = cfg.BaseURL
+	}
+
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate platform key: %w", err)
+	}
+	privateKey, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap platform key: %w", err)
+	}
+	if err := jwk.AssignKeyID(privateKey); err != nil {
+		return nil, fmt.Errorf("failed to assign key id: %w", err)
+	}
+	privateKey.Set(jwk.AlgorithmKey, jwa.RS256)
+	privateKey.Set(jwk.KeyUsageKey, jwk.ForSignature)
+
+	publicKey, err := jwk.PublicKeyOf(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive public key: %w", err)
+	}
+	publicSet := jwk.NewSet()
+	publicSet.AddKey(publicKey)
+
+	return &Platform{
+		cfg:        cfg,
+		privateKey: privateKey,
+		publicSet:  publicSet,
+		launches:   make(map[string]Launch),
+		tokens:     make(map[string]accessToken),
+		lineItems:  make(map[string]*LineItem),
+		members:    make(map[string][]Member),
+	}, nil
+}
+
+// Config returns the effective platform configuration
+func (p *Platform) Config() Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg
+}
+
+// SetBaseURL updates the base URL (and the issuer when it was derived from it).
+// Used when the listening address is only known after the server started.
+func (p *Platform) SetBaseURL(baseURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	baseURL = strings.TrimRight(baseURL, "/")
+	if p.cfg.Issuer == "" || p.cfg.Issuer == p.cfg.BaseURL {
+		p.cfg.Issuer = baseURL
+	}
+	p.cfg.BaseURL = baseURL
+}
+
+// PublicKeySet returns the platform's public JWKS
+func (p *Platform) PublicKeySet() jwk.Set {
+	return p.publicSet
+}
+
+// JWKSURL returns the platform keyset endpoint (certs.php)
+func (p *Platform) JWKSURL() string { return p.Config().BaseURL + JWKSPath }
+
+// AuthURL returns the OIDC authorization endpoint (auth.php)
+func (p *Platform) AuthURL() string { return p.Config().BaseURL + AuthPath }
+
+// TokenURL returns the OAuth2 token endpoint (token.php)
+func (p *Platform) TokenURL() string { return p.Config().BaseURL + TokenPath }
+
+// OpenIDConfigurationURL returns the URL a tool is given to start dynamic registration
+func (p *Platform) OpenIDConfigurationURL() string {
+	return p.Config().BaseURL + OpenIDConfigurationPath
+}
+
+// LineItemsURL returns the AGS line item container URL for a context
+func (p *Platform) LineItemsURL(contextID string) string {
+	return fmt.Sprintf("%s%s/%s/lineitems", p.Config().BaseURL, ServicesPath, url.PathEscape(contextID))
+}
+
+// LineItemURL returns the AGS line item URL for a context and item id
+func (p *Platform) LineItemURL(contextID, itemID string) string {
+	return fmt.Sprintf("%s/%s/lineitem", p.LineItemsURL(contextID), url.PathEscape(itemID))
+}
+
+// MembershipsURL returns the NRPS context membership URL
+func (p *Platform) MembershipsURL(contextID string) string {
+	cfg := p.Config()
+	return fmt.Sprintf("%s%s/CourseSection/%s/bindings/%s/memberships",
+		cfg.BaseURL, ServicesPath, url.PathEscape(contextID), url.PathEscape(cfg.DeploymentID))
+}
+
+// Sign signs arbitrary claims with the platform key
+func (p *Platform) Sign(claims map[string]interface{}) (string, error) {
+	token := jwt.New()
+	for name, value := range claims {
+		if err := token.Set(name, value); err != nil {
+			return "", fmt.Errorf("failed to set claim %s: %w", name, err)
+		}
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, p.privateKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return string(signed), nil
+}
+
+// Handler returns the HTTP handler serving all platform endpoints
+func (p *Platform) Handler() http.Handler {
+	return p.routes()
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("mockplatform: crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
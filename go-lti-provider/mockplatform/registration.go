@@ -0,0 +1,100 @@
+package mockplatform
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const toolConfigurationClaim = "https://purl.imsglobal.org/spec/lti-tool-configuration"
+
+func (p *Platform) handleOpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	cfg := p.Config()
+
+	writeJSON(w, "application/json", http.StatusOK, map[string]interface{}{
+		"issuer":                                cfg.Issuer,
+		"authorization_endpoint":                p.AuthURL(),
+		"token_endpoint":                        p.TokenURL(),
+		"jwks_uri":                              p.JWKSURL(),
+		"registration_endpoint":                 cfg.BaseURL + RegistrationPath,
+		"token_endpoint_auth_methods_supported": []string{"private_key_jwt"},
+		"token_endpoint_auth_signing_alg_values_supported": []string{"RS256"},
+		"response_types_supported":                         []string{"id_token"},
+		"subject_types_supported":                          []string{"public", "pairwise"},
+		"id_token_signing_alg_values_supported":            []string{"RS256"},
+		"claims_supported":                                 []string{"sub", "iss", "name", "given_name", "family_name", "email"},
+		"scopes_supported": []string{
+			"openid",
+			agsScopePrefix + "lineitem",
+			agsScopePrefix + "lineitem.readonly",
+			agsScopePrefix + "result.readonly",
+			agsScopePrefix + "score",
+			nrpsScope,
+		},
+		"https://purl.imsglobal.org/spec/lti-platform-configuration": map[string]interface{}{
+			"product_family_code": "moodle",
+			"version":             "mock",
+			"messages_supported": []map[string]interface{}{
+				{"type": ResourceLinkRequest},
+				{"type": DeepLinkingRequest},
+				{"type": SubmissionReviewRequest},
+			},
+		},
+	})
+}
+
+// handleRegistration accepts a tool registration and points the platform at
+// the tool's login and launch URLs, the way Moodle creates a tool type
+func (p *Platform) handleRegistration(w http.ResponseWriter, r *http.Request) {
+	cfg := p.Config()
+
+	if cfg.RegistrationToken != "" &&
+		strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") != cfg.RegistrationToken {
+		http.Error(w, "invalid registration token", http.StatusUnauthorized)
+		return
+	}
+
+	var registration map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, "invalid registration", http.StatusBadRequest)
+		return
+	}
+
+	loginURL, _ := registration["initiate_login_uri"].(string)
+	redirectURIs, _ := registration["redirect_uris"].([]interface{})
+	if loginURL == "" || len(redirectURIs) == 0 {
+		http.Error(w, "initiate_login_uri and redirect_uris are required", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	p.registrations = append(p.registrations, registration)
+	p.cfg.ToolLoginURL = loginURL
+	for i, uri := range redirectURIs {
+		if s, ok := uri.(string); ok {
+			if i == 0 {
+				p.cfg.ToolLaunchURL = s
+			}
+			p.cfg.RedirectURIs = append(p.cfg.RedirectURIs, s)
+		}
+	}
+	p.mu.Unlock()
+
+	toolConfig, _ := registration[toolConfigurationClaim].(map[string]interface{})
+	if toolConfig == nil {
+		toolConfig = make(map[string]interface{})
+	}
+	toolConfig["deployment_id"] = cfg.DeploymentID
+
+	registration["client_id"] = cfg.ClientID
+	registration[toolConfigurationClaim] = toolConfig
+
+	writeJSON(w, "application/json", http.StatusOK, registration)
+}
+
+// Registrations returns the tool registration requests received, oldest first
+func (p *Platform) Registrations() []map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]map[string]interface{}(nil), p.registrations...)
+}
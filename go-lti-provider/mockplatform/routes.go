@@ -0,0 +1,502 @@
+package mockplatform
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var autoPostTemplate = template.Must(template.New("autopost").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body onload="document.forms[0].submit()">
+    <form method="POST" action="{{.Action}}">
+        {{range $name, $values := .Fields}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+        {{end}}{{end}}<noscript><button type="submit">Continue</button></noscript>
+    </form>
+</body>
+</html>`))
+
+func (p *Platform) routes() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get(JWKSPath, p.handleJWKS)
+	r.Get(AuthPath, p.handleAuth)
+	r.Post(AuthPath, p.handleAuth)
+	r.Post(TokenPath, p.handleToken)
+	r.Get(LaunchPath, p.handleLaunchPage)
+	r.Get(OpenIDConfigurationPath, p.handleOpenIDConfiguration)
+	r.Post(RegistrationPath, p.handleRegistration)
+
+	r.Route(ServicesPath, func(r chi.Router) {
+		r.Use(p.recordCalls)
+
+		r.Get("/{contextID}/lineitems", p.handleListLineItems)
+		r.Post("/{contextID}/lineitems", p.handleCreateLineItem)
+		r.Get("/{contextID}/lineitems/{itemID}/lineitem", p.handleGetLineItem)
+		r.Put("/{contextID}/lineitems/{itemID}/lineitem", p.handleUpdateLineItem)
+		r.Delete("/{contextID}/lineitems/{itemID}/lineitem", p.handleDeleteLineItem)
+		r.Post("/{contextID}/lineitems/{itemID}/lineitem/scores", p.handleScore)
+		r.Get("/{contextID}/lineitems/{itemID}/lineitem/results", p.handleResults)
+		r.Get("/CourseSection/{contextID}/bindings/{deploymentID}/memberships", p.handleMemberships)
+	})
+
+	return r
+}
+
+// recordCalls stores every service request so tests can assert on them
+func (p *Platform) recordCalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		p.mu.Lock()
+		p.calls = append(p.calls, Call{
+			Time:          time.Now(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Query:         r.URL.RawQuery,
+			ContentType:   r.Header.Get("Content-Type"),
+			Authorization: r.Header.Get("Authorization"),
+			Body:          body,
+		})
+		p.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Platform) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, "application/json", http.StatusOK, p.publicSet)
+}
+
+func (p *Platform) handleAuth(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := p.Authorize(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	renderAutoPost(w, "Launching tool", resp.RedirectURI, resp.Form())
+}
+
+func (p *Platform) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if r.FormValue("grant_type") != "client_credentials" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	cfg := p.Config()
+	clientID := r.FormValue("client_id")
+	if assertion := r.FormValue("client_assertion"); assertion != "" {
+		// The assertion is signed with the tool key; the mock only checks who it claims to be
+		token, err := jwt.ParseInsecure([]byte(assertion))
+		if err != nil {
+			tokenError(w, "invalid_client")
+			return
+		}
+		clientID = token.Subject()
+	} else if cfg.ClientSecret != "" && r.FormValue("client_secret") != cfg.ClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if clientID != cfg.ClientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	scopes := strings.Fields(r.FormValue("scope"))
+	value := randomID(16)
+
+	p.mu.Lock()
+	p.tokens[value] = accessToken{scopes: scopes, expiresAt: time.Now().Add(cfg.TokenTTL)}
+	p.mu.Unlock()
+
+	writeJSON(w, "application/json", http.StatusOK, map[string]interface{}{
+		"access_token": value,
+		"token_type":   "Bearer",
+		"expires_in":   int(cfg.TokenTTL.Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	})
+}
+
+// handleLaunchPage lets a browser start a launch without a real course page:
+// it registers a launch from the query and auto-posts the login initiation to the tool
+func (p *Platform) handleLaunchPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	launch := Launch{
+		MessageType:       q.Get("message_type"),
+		UserID:            valueOr(q.Get("user_id"), "2"),
+		Name:              q.Get("name"),
+		ContextID:         valueOr(q.Get("context_id"), "2"),
+		ContextTitle:      valueOr(q.Get("context_title"), "Mock Course"),
+		ResourceLinkID:    valueOr(q.Get("resource_link_id"), "1"),
+		ResourceLinkTitle: valueOr(q.Get("resource_link_title"), "Mock Activity"),
+		ReturnURL:         q.Get("return_url"),
+		ForUserID:         q.Get("for_user"),
+		WithAGS:           true,
+		WithNRPS:          true,
+		Custom:            make(map[string]string),
+	}
+
+	for _, role := range strings.Split(valueOr(q.Get("roles"), "learner"), ",") {
+		launch.Roles = append(launch.Roles, roleURI(strings.TrimSpace(role)))
+	}
+	for name := range q {
+		if strings.HasPrefix(name, "custom_") {
+			launch.Custom[strings.TrimPrefix(name, "custom_")] = q.Get(name)
+		}
+	}
+
+	if itemID := q.Get("lineitem"); itemID != "" {
+		p.mu.Lock()
+		if _, exists := p.lineItems[itemID]; !exists {
+			p.addLineItemLocked(launch.ContextID, LineItem{
+				ID:             itemID,
+				Label:          launch.ResourceLinkTitle,
+				ScoreMaximum:   100,
+				ResourceLinkID: launch.ResourceLinkID,
+			})
+		}
+		p.mu.Unlock()
+		launch.LineItemID = itemID
+	}
+
+	cfg := p.Config()
+	if cfg.ToolLoginURL == "" {
+		http.Error(w, "no tool login URL configured", http.StatusInternalServerError)
+		return
+	}
+
+	renderAutoPost(w, "Starting launch", cfg.ToolLoginURL, p.Initiate(launch))
+}
+
+func (p *Platform) handleListLineItems(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "lineitem", "lineitem.readonly") {
+		return
+	}
+
+	contextID := chi.URLParam(r, "contextID")
+	q := r.URL.Query()
+
+	var items []map[string]interface{}
+	for _, item := range p.LineItems() {
+		if item.contextID != contextID {
+			continue
+		}
+		if v := q.Get("resource_link_id"); v != "" && item.ResourceLinkID != v {
+			continue
+		}
+		if v := q.Get("resource_id"); v != "" && item.ResourceID != v {
+			continue
+		}
+		if v := q.Get("tag"); v != "" && item.Tag != v {
+			continue
+		}
+		items = append(items, p.lineItemView(item))
+	}
+	if items == nil {
+		items = []map[string]interface{}{}
+	}
+
+	writeJSON(w, "application/vnd.ims.lis.v2.lineitemcontainer+json", http.StatusOK, items)
+}
+
+func (p *Platform) handleCreateLineItem(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "lineitem") {
+		return
+	}
+
+	var item LineItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "invalid line item", http.StatusBadRequest)
+		return
+	}
+	if item.Label == "" || item.ScoreMaximum <= 0 {
+		http.Error(w, "label and scoreMaximum are required", http.StatusBadRequest)
+		return
+	}
+	item.ID = ""
+
+	created := p.AddLineItem(chi.URLParam(r, "contextID"), item)
+	writeJSON(w, "application/vnd.ims.lis.v2.lineitem+json", http.StatusCreated, p.lineItemView(created))
+}
+
+func (p *Platform) handleGetLineItem(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "lineitem", "lineitem.readonly") {
+		return
+	}
+
+	item, ok := p.findLineItem(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, "application/vnd.ims.lis.v2.lineitem+json", http.StatusOK, p.lineItemView(item))
+}
+
+func (p *Platform) handleUpdateLineItem(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "lineitem") {
+		return
+	}
+
+	existing, ok := p.findLineItem(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var item LineItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, "invalid line item", http.StatusBadRequest)
+		return
+	}
+	item.ID = existing.ID
+
+	updated := p.AddLineItem(existing.contextID, item)
+	writeJSON(w, "application/vnd.ims.lis.v2.lineitem+json", http.StatusOK, p.lineItemView(updated))
+}
+
+func (p *Platform) handleDeleteLineItem(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "lineitem") {
+		return
+	}
+
+	item, ok := p.findLineItem(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.mu.Lock()
+	delete(p.lineItems, item.ID)
+	p.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Platform) handleScore(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "score") {
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/vnd.ims.lis.v1.score+json") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	item, ok := p.findLineItem(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var score Score
+	if err := json.NewDecoder(r.Body).Decode(&score); err != nil {
+		http.Error(w, "invalid score", http.StatusBadRequest)
+		return
+	}
+	if score.UserID == "" || score.ActivityProgress == "" || score.GradingProgress == "" || score.Timestamp == "" {
+		http.Error(w, "userId, activityProgress, gradingProgress and timestamp are required", http.StatusBadRequest)
+		return
+	}
+	if score.ScoreGiven != nil && score.ScoreMaximum == nil {
+		http.Error(w, "scoreMaximum is required with scoreGiven", http.StatusBadRequest)
+		return
+	}
+	score.LineItemID = item.ID
+
+	p.mu.Lock()
+	p.scores = append(p.scores, score)
+	p.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Platform) handleResults(w http.ResponseWriter, r *http.Request) {
+	if !p.authorize(w, r, "result.readonly") {
+		return
+	}
+
+	item, ok := p.findLineItem(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	lineItemURL := p.LineItemURL(item.contextID, item.ID)
+
+	p.mu.Lock()
+	results := p.resultsLocked(item.ID, r.URL.Query().Get("user_id"))
+	p.mu.Unlock()
+
+	for i := range results {
+		results[i].ID = lineItemURL + "/results/" + url.PathEscape(results[i].UserID)
+		results[i].ScoreOf = lineItemURL
+	}
+
+	writeJSON(w, "application/vnd.ims.lis.v2.resultcontainer+json", http.StatusOK, results)
+}
+
+func (p *Platform) handleMemberships(w http.ResponseWriter, r *http.Request) {
+	if !p.authorizeScope(w, r, nrpsScope) {
+		return
+	}
+
+	contextID := chi.URLParam(r, "contextID")
+	role := r.URL.Query().Get("role")
+
+	p.mu.Lock()
+	var members []Member
+	for _, m := range p.members[contextID] {
+		if role == "" || hasRole(m.Roles, roleURI(role)) {
+			members = append(members, m)
+		}
+	}
+	p.mu.Unlock()
+	if members == nil {
+		members = []Member{}
+	}
+
+	writeJSON(w, "application/vnd.ims.lti-nrps.v2.membershipcontainer+json", http.StatusOK, map[string]interface{}{
+		"id":      p.MembershipsURL(contextID),
+		"context": map[string]interface{}{"id": contextID},
+		"members": members,
+	})
+}
+
+func (p *Platform) findLineItem(r *http.Request) (LineItem, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	item, ok := p.lineItems[chi.URLParam(r, "itemID")]
+	if !ok || item.contextID != chi.URLParam(r, "contextID") {
+		return LineItem{}, false
+	}
+	return *item, true
+}
+
+func (p *Platform) lineItemView(item LineItem) map[string]interface{} {
+	view := map[string]interface{}{
+		"id":           p.LineItemURL(item.contextID, item.ID),
+		"scoreMaximum": item.ScoreMaximum,
+		"label":        item.Label,
+	}
+	optional := map[string]string{
+		"resourceId":     item.ResourceID,
+		"resourceLinkId": item.ResourceLinkID,
+		"tag":            item.Tag,
+		"startDateTime":  item.StartDateTime,
+		"endDateTime":    item.EndDateTime,
+	}
+	for name, value := range optional {
+		if value != "" {
+			view[name] = value
+		}
+	}
+	if item.GradesReleased != nil {
+		view["gradesReleased"] = *item.GradesReleased
+	}
+	if item.SubmissionReview != nil {
+		view["submissionReview"] = item.SubmissionReview
+	}
+	return view
+}
+
+// authorize checks the bearer token carries one of the given AGS scopes
+func (p *Platform) authorize(w http.ResponseWriter, r *http.Request, scopes ...string) bool {
+	full := make([]string, len(scopes))
+	for i, s := range scopes {
+		full[i] = agsScopePrefix + s
+	}
+	return p.authorizeScope(w, r, full...)
+}
+
+func (p *Platform) authorizeScope(w http.ResponseWriter, r *http.Request, scopes ...string) bool {
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	p.mu.Lock()
+	token, ok := p.tokens[bearer]
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(token.expiresAt) {
+		http.Error(w, "invalid or expired access token", http.StatusUnauthorized)
+		return false
+	}
+	for _, want := range scopes {
+		for _, have := range token.scopes {
+			if want == have {
+				return true
+			}
+		}
+	}
+	http.Error(w, "insufficient scope", http.StatusForbidden)
+	return false
+}
+
+func renderAutoPost(w http.ResponseWriter, title, action string, fields url.Values) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	autoPostTemplate.Execute(w, map[string]interface{}{
+		"Title":  title,
+		"Action": action,
+		"Fields": fields,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, "application/json", http.StatusBadRequest, map[string]string{"error": code})
+}
+
+// roleURI expands short role names used by the launch page
+func roleURI(role string) string {
+	switch strings.ToLower(role) {
+	case "learner", "student":
+		return RoleLearner
+	case "instructor", "teacher":
+		return RoleInstructor
+	case "ta", "teachingassistant":
+		return RoleTA
+	case "admin", "administrator":
+		return RoleAdmin
+	}
+	return role
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
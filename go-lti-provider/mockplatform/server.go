@@ -0,0 +1,34 @@
+package mockplatform
+
+import (
+	"net/http/httptest"
+)
+
+// Server is a platform listening on a local test server
+type Server struct {
+	*Platform
+	URL string
+
+	srv *httptest.Server
+}
+
+// NewServer starts the platform on a random local port. The issuer and
+// endpoint URLs default to the server URL unless set in cfg.
+func NewServer(cfg Config) (*Server, error) {
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := httptest.NewServer(p.Handler())
+	if cfg.BaseURL == "" {
+		p.SetBaseURL(srv.URL)
+	}
+
+	return &Server{Platform: p, URL: srv.URL, srv: srv}, nil
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
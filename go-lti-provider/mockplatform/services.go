@@ -0,0 +1,181 @@
+package mockplatform
+
+import (
+	"sort"
+	"time"
+)
+
+// LineItem is an AGS line item as stored by the platform
+type LineItem struct {
+	ID               string                 `json:"id"`
+	ScoreMaximum     float64                `json:"scoreMaximum"`
+	Label            string                 `json:"label"`
+	ResourceID       string                 `json:"resourceId,omitempty"`
+	ResourceLinkID   string                 `json:"resourceLinkId,omitempty"`
+	Tag              string                 `json:"tag,omitempty"`
+	StartDateTime    string                 `json:"startDateTime,omitempty"`
+	EndDateTime      string                 `json:"endDateTime,omitempty"`
+	GradesReleased   *bool                  `json:"gradesReleased,omitempty"`
+	SubmissionReview map[string]interface{} `json:"submissionReview,omitempty"`
+
+	contextID string
+}
+
+// Score is an AGS score publish received by the platform
+type Score struct {
+	UserID           string   `json:"userId"`
+	ScoreGiven       *float64 `json:"scoreGiven,omitempty"`
+	ScoreMaximum     *float64 `json:"scoreMaximum,omitempty"`
+	Comment          string   `json:"comment,omitempty"`
+	ActivityProgress string   `json:"activityProgress"`
+	GradingProgress  string   `json:"gradingProgress"`
+	Timestamp        string   `json:"timestamp"`
+
+	// LineItemID is filled in by the platform
+	LineItemID string `json:"-"`
+}
+
+// Result is an AGS result as returned by the results endpoint
+type Result struct {
+	ID            string   `json:"id"`
+	ScoreOf       string   `json:"scoreOf"`
+	UserID        string   `json:"userId"`
+	ResultScore   *float64 `json:"resultScore,omitempty"`
+	ResultMaximum *float64 `json:"resultMaximum,omitempty"`
+	Comment       string   `json:"comment,omitempty"`
+}
+
+// Member is an NRPS context member
+type Member struct {
+	UserID     string   `json:"user_id"`
+	Status     string   `json:"status,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	GivenName  string   `json:"given_name,omitempty"`
+	FamilyName string   `json:"family_name,omitempty"`
+	Email      string   `json:"email,omitempty"`
+	Roles      []string `json:"roles"`
+}
+
+// Call is a recorded request to one of the platform service endpoints
+type Call struct {
+	Time          time.Time
+	Method        string
+	Path          string
+	Query         string
+	ContentType   string
+	Authorization string
+	Body          []byte
+}
+
+// AddLineItem creates a line item in a context and returns it
+func (p *Platform) AddLineItem(contextID string, item LineItem) LineItem {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return *p.addLineItemLocked(contextID, item)
+}
+
+func (p *Platform) addLineItemLocked(contextID string, item LineItem) *LineItem {
+	if item.ID == "" {
+		item.ID = randomID(4)
+	}
+	item.contextID = contextID
+	stored := item
+	if _, exists := p.lineItems[item.ID]; !exists {
+		p.itemOrder = append(p.itemOrder, item.ID)
+	}
+	p.lineItems[item.ID] = &stored
+	return &stored
+}
+
+// LineItems returns all line items, in creation order
+func (p *Platform) LineItems() []LineItem {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items := make([]LineItem, 0, len(p.itemOrder))
+	for _, id := range p.itemOrder {
+		if item, ok := p.lineItems[id]; ok {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// Scores returns every score received for a line item ("" for all), oldest first
+func (p *Platform) Scores(lineItemID string) []Score {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var scores []Score
+	for _, s := range p.scores {
+		if lineItemID == "" || s.LineItemID == lineItemID {
+			scores = append(scores, s)
+		}
+	}
+	return scores
+}
+
+// Results returns the latest score per user for a line item, like the AGS results service
+func (p *Platform) Results(lineItemID string) []Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resultsLocked(lineItemID, "")
+}
+
+func (p *Platform) resultsLocked(lineItemID, userID string) []Result {
+	latest := make(map[string]Score)
+	for _, s := range p.scores {
+		if s.LineItemID == lineItemID && (userID == "" || s.UserID == userID) {
+			latest[s.UserID] = s
+		}
+	}
+
+	users := make([]string, 0, len(latest))
+	for u := range latest {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	results := make([]Result, 0, len(users))
+	for _, u := range users {
+		s := latest[u]
+		results = append(results, Result{
+			ID:            lineItemID + "/results/" + u,
+			UserID:        u,
+			ResultScore:   s.ScoreGiven,
+			ResultMaximum: s.ScoreMaximum,
+			Comment:       s.Comment,
+		})
+	}
+	return results
+}
+
+// AddMember registers an NRPS member of a context
+func (p *Platform) AddMember(contextID string, m Member) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if m.Status == "" {
+		m.Status = "Active"
+	}
+	p.members[contextID] = append(p.members[contextID], m)
+}
+
+// Calls returns every recorded service call, oldest first
+func (p *Platform) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.calls...)
+}
+
+// Reset forgets recorded calls, scores, pending launches and issued tokens.
+// Line items and members are kept.
+func (p *Platform) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = nil
+	p.scores = nil
+	p.launches = make(map[string]Launch)
+	p.tokens = make(map[string]accessToken)
+}
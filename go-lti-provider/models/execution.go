@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Execution event types, in the order a graded submission goes through them
+const (
+	EventQueued    = "queued"     // waiting for Judge0
+	EventCompiling = "compiling"  // source sent to Judge0; compiled languages compile with the first run
+	EventRunning   = "running"    // test Test of Total started
+	EventTest      = "test"       // test Test of Total finished, with its verdict
+	EventScore     = "score"      // all tests done, score known
+	EventGradeSync = "grade_sync" // gradebook updated (or not)
+	EventFailed    = "failed"     // the run failed; no score
+	EventDone      = "done"       // last event of an execution
+)
+
+// Grade sync outcomes of an EventGradeSync
+const (
+	GradeSynced  = "synced"
+	GradeFailed  = "failed"
+	GradeSkipped = "skipped" // no line item to send the grade to
+)
+
+// ExecutionEvent is one step of a submission's execution, streamed to the
+// learner while it is graded. Events carry verdicts only; the output of the
+// runs is read from the submission once it is done.
+type ExecutionEvent struct {
+	Seq  int       `json:"seq"` // 1, 2, ... per execution (SSE event ID)
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	Test    int          `json:"test,omitempty"` // 1-based
+	Total   int          `json:"total,omitempty"`
+	Verdict *TestVerdict `json:"verdict,omitempty"`
+
+	Score       *float64         `json:"score,omitempty"`
+	MaxScore    float64          `json:"max_score,omitempty"`
+	LatePenalty float64          `json:"late_penalty,omitempty"`
+	Grade       *float64         `json:"grade,omitempty"` // sent to the gradebook
+	Sync        string           `json:"sync,omitempty"`  // grade sync outcome
+	Status      SubmissionStatus `json:"status,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// TestVerdict is the outcome of one test case without its output
+type TestVerdict struct {
+	TestCaseID string  `json:"test_case_id"`
+	Name       string  `json:"name,omitempty"` // not for hidden test cases
+	Hidden     bool    `json:"hidden,omitempty"`
+	Passed     bool    `json:"passed"`
+	Status     Status  `json:"status"`
+	Time       *string `json:"time,omitempty"`
+	Memory     *int    `json:"memory,omitempty"`
+}
+
+// Verdict returns the verdict of a test result
+func (t TestResult) Verdict() *TestVerdict {
+	v := &TestVerdict{
+		TestCaseID: t.TestCaseID,
+		Hidden:     t.Hidden,
+		Passed:     t.Passed,
+		Status:     t.Status,
+		Time:       t.Time,
+		Memory:     t.Memory,
+	}
+	if !t.Hidden {
+		v.Name = t.Name
+	}
+	return v
+}
+
+// Final reports whether no event follows this one
+func (e ExecutionEvent) Final() bool {
+	return e.Type == EventDone
+}
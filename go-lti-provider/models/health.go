@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// HealthStatus is the outcome of a health check, or of all of them
+type HealthStatus string
+
+const (
+	HealthPass HealthStatus = "pass"
+	HealthWarn HealthStatus = "warn" // works, but degraded (long queue, big backlog)
+	HealthFail HealthStatus = "fail"
+)
+
+// HealthCheckResult is the last result of one dependency check
+type HealthCheckResult struct {
+	Name      string                 `json:"name"`
+	Critical  bool                   `json:"critical"` // a failure takes the instance out of rotation
+	Status    HealthStatus           `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Duration  string                 `json:"duration"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// HealthReport is the state of every dependency. Status is fail when a
+// critical check failed and warn when anything else is not passing.
+type HealthReport struct {
+	Status    HealthStatus        `json:"status"`
+	Ready     bool                `json:"ready"`
+	Draining  bool                `json:"draining,omitempty"`
+	CheckedAt time.Time           `json:"checked_at"`
+	Checks    []HealthCheckResult `json:"checks"`
+}
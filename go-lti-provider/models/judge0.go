@@ -2,9 +2,10 @@ package models
 
 // Submission represents a code submission to Judge0
 type Submission struct {
-	SourceCode string `json:"source_code"`
-	LanguageID int    `json:"language_id"`
-	Stdin      string `json:"stdin,omitempty"`
+	SourceCode     string `json:"source_code"`
+	LanguageID     int    `json:"language_id"`
+	Stdin          string `json:"stdin,omitempty"`
+	ExpectedOutput string `json:"expected_output,omitempty"`
 }
 
 // Judge0Response represents the response from Judge0 API
@@ -27,20 +28,13 @@ type Status struct {
 	Description string `json:"description"`
 }
 
-// ExecuteRequest represents the request body for code execution
-type ExecuteRequest struct {
-	Code     string  `json:"code"`
-	Language string  `json:"language"`
-	UserID   string  `json:"user_id"`
-	LineItem string  `json:"lineitem"`
-	IDToken  string  `json:"id_token"`
-	MaxScore float64 `json:"max_score"`
-}
-
-// ExecuteResponse represents the response from code execution
-type ExecuteResponse struct {
-	Success bool            `json:"success"`
-	Result  *Judge0Response `json:"result,omitempty"`
-	Score   float64         `json:"score,omitempty"`
-	Error   string          `json:"error,omitempty"`
+// Judge0Worker is one queue of Judge0 workers (GET /workers)
+type Judge0Worker struct {
+	Queue     string `json:"queue"`
+	Size      int    `json:"size"` // submissions waiting in the queue
+	Available int    `json:"available"`
+	Idle      int    `json:"idle"`
+	Working   int    `json:"working"`
+	Paused    int    `json:"paused"`
+	Failed    int    `json:"failed"`
 }
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// RubricCriterion is a manually graded aspect of a problem, such as code
+// style. Points awarded for it adjust the autograde by at most MaxPoints
+// either way.
+type RubricCriterion struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	MaxPoints   float64 `json:"max_points"`
+}
+
+// RubricScore is the points an instructor gave for one rubric criterion
+type RubricScore struct {
+	CriterionID string  `json:"criterion_id"`
+	Points      float64 `json:"points"`
+	Comment     string  `json:"comment,omitempty"`
+}
+
+// GradeOverride is an instructor's manual grade for a submission
+type GradeOverride struct {
+	Score         float64       `json:"score"`
+	PreviousScore float64       `json:"previous_score"`
+	Comment       string        `json:"comment,omitempty"`
+	Rubric        []RubricScore `json:"rubric,omitempty"`
+	GradedBy      string        `json:"graded_by"`
+	GradedAt      time.Time     `json:"graded_at"`
+}
+
+// AuditEntry records one grade change: who changed which learner's grade,
+// from what to what and why
+type AuditEntry struct {
+	ResourceKey
+	At           time.Time     `json:"at"`
+	Action       string        `json:"action"`
+	By           string        `json:"by"`
+	UserID       string        `json:"user_id"`
+	SubmissionID string        `json:"submission_id,omitempty"`
+	LineItem     string        `json:"lineitem,omitempty"`
+	OldScore     *float64      `json:"old_score,omitempty"`
+	NewScore     float64       `json:"new_score"`
+	MaxScore     float64       `json:"max_score"`
+	Comment      string        `json:"comment,omitempty"`
+	Rubric       []RubricScore `json:"rubric,omitempty"`
+	Synced       bool          `json:"synced"` // posted to the platform
+}
+
+// Audit actions
+const (
+	AuditGradeOverride = "grade_override"
+	AuditManualGrade   = "manual_grade"
+	AuditGradeResync   = "grade_resync"
+	AuditGradeSynced   = "grade_synced" // an audited grade reached the platform
+)
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PlatformRegistration holds everything the tool needs to trust an LTI platform
+type PlatformRegistration struct {
+	Issuer        string    `json:"issuer"`
+	ClientID      string    `json:"client_id"`
+	DeploymentIDs []string  `json:"deployment_ids,omitempty"`
+	AuthLoginURL  string    `json:"auth_login_url"`
+	TokenURL      string    `json:"token_url"`
+	JWKSURL       string    `json:"jwks_url"`
+	ProductFamily string    `json:"product_family,omitempty"`
+	RegisteredAt  time.Time `json:"registered_at,omitempty"`
+}
+
+// HasDeployment reports whether the deployment ID is known for this platform.
+// A registration without deployments accepts any deployment.
+func (p *PlatformRegistration) HasDeployment(deploymentID string) bool {
+	if len(p.DeploymentIDs) == 0 {
+		return true
+	}
+	for _, id := range p.DeploymentIDs {
+		if id == deploymentID {
+			return true
+		}
+	}
+	return false
+}
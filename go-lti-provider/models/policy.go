@@ -0,0 +1,128 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// GradeAggregation decides which score of a learner's attempts goes to the gradebook
+type GradeAggregation string
+
+const (
+	GradeBest    GradeAggregation = "best"
+	GradeLast    GradeAggregation = "last"
+	GradeAverage GradeAggregation = "average"
+)
+
+// Valid reports whether a is a known aggregation; empty means best
+func (a GradeAggregation) Valid() bool {
+	switch a {
+	case "", GradeBest, GradeLast, GradeAverage:
+		return true
+	}
+	return false
+}
+
+// PenaltyUnit is the step of a linear late penalty
+type PenaltyUnit string
+
+const (
+	PenaltyPerHour PenaltyUnit = "hour"
+	PenaltyPerDay  PenaltyUnit = "day"
+)
+
+// Valid reports whether u is a known unit; empty means hour
+func (u PenaltyUnit) Valid() bool {
+	switch u {
+	case "", PenaltyPerHour, PenaltyPerDay:
+		return true
+	}
+	return false
+}
+
+// LatePenalty deducts Percent of the score for every started hour or day
+// after the due date, up to MaxPercent
+type LatePenalty struct {
+	Percent    float64     `json:"percent,omitempty"`
+	Per        PenaltyUnit `json:"per,omitempty"`
+	MaxPercent float64     `json:"max_percent,omitempty"` // 0 = up to 100%
+}
+
+// AssignmentPolicy are the rules for graded attempts on a resource link
+type AssignmentPolicy struct {
+	MaxAttempts     int              `json:"max_attempts,omitempty"`     // 0 = unlimited
+	CooldownSeconds int              `json:"cooldown_seconds,omitempty"` // minimum time between attempts
+	DueAt           *time.Time       `json:"due_at,omitempty"`
+	CutoffAt        *time.Time       `json:"cutoff_at,omitempty"` // no attempts accepted after this
+	LatePenalty     LatePenalty      `json:"late_penalty"`
+	Aggregation     GradeAggregation `json:"aggregation,omitempty"` // default best
+}
+
+// Cooldown returns the minimum time between two attempts
+func (p AssignmentPolicy) Cooldown() time.Duration {
+	return time.Duration(p.CooldownSeconds) * time.Second
+}
+
+// Validate rejects an unknown late penalty unit or grade aggregation, which
+// would otherwise silently fall back to per hour and best
+func (p AssignmentPolicy) Validate() error {
+	if !p.LatePenalty.Per.Valid() {
+		return fmt.Errorf("unknown late penalty unit %q (want %q or %q)", p.LatePenalty.Per, PenaltyPerHour, PenaltyPerDay)
+	}
+	if !p.Aggregation.Valid() {
+		return fmt.Errorf("unknown grade aggregation %q (want %q, %q or %q)", p.Aggregation, GradeBest, GradeLast, GradeAverage)
+	}
+	return nil
+}
+
+// PenaltyAt returns the late penalty, in percent, for an attempt made at t
+func (p AssignmentPolicy) PenaltyAt(t time.Time) float64 {
+	if p.DueAt == nil || !t.After(*p.DueAt) || p.LatePenalty.Percent <= 0 {
+		return 0
+	}
+
+	unit := time.Hour
+	if p.LatePenalty.Per == PenaltyPerDay {
+		unit = 24 * time.Hour
+	}
+	units := math.Ceil(float64(t.Sub(*p.DueAt)) / float64(unit))
+
+	maxPercent := p.LatePenalty.MaxPercent
+	if maxPercent <= 0 || maxPercent > 100 {
+		maxPercent = 100
+	}
+	return math.Min(units*p.LatePenalty.Percent, maxPercent)
+}
+
+// AggregateScore returns the score to send to the gradebook for a learner's
+// attempts (newest first). Only completed attempts count; ok is false when
+// there are none.
+func (p AssignmentPolicy) AggregateScore(attempts []SubmissionRecord) (score float64, ok bool) {
+	var total float64
+	count := 0
+	for _, a := range attempts {
+		if a.Status != SubmissionCompleted {
+			continue
+		}
+		switch p.Aggregation {
+		case GradeLast:
+			return a.Score, true
+		case GradeAverage:
+			total += a.Score
+		default:
+			if count == 0 || a.Score > score {
+				score = a.Score
+			}
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+	if p.Aggregation == GradeAverage {
+		return total / float64(count), true
+	}
+	return score, true
+}
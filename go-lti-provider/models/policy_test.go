@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestAssignmentPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  AssignmentPolicy
+		wantErr bool
+	}{
+		{"defaults", AssignmentPolicy{}, false},
+		{"per day, last", AssignmentPolicy{LatePenalty: LatePenalty{Percent: 10, Per: PenaltyPerDay}, Aggregation: GradeLast}, false},
+		{"per hour, average", AssignmentPolicy{LatePenalty: LatePenalty{Per: PenaltyPerHour}, Aggregation: GradeAverage}, false},
+		{"best", AssignmentPolicy{Aggregation: GradeBest}, false},
+		{"unknown unit", AssignmentPolicy{LatePenalty: LatePenalty{Percent: 10, Per: "week"}}, true},
+		{"unit in another case", AssignmentPolicy{LatePenalty: LatePenalty{Per: "Day"}}, true},
+		{"unknown aggregation", AssignmentPolicy{Aggregation: "worst"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Problem is the programming exercise configured for a resource link
+type Problem struct {
+	Title       string     `json:"title"`
+	Statement   string     `json:"statement,omitempty"`
+	Languages   []string   `json:"languages,omitempty"`
+	StarterCode string     `json:"starter_code,omitempty"`
+	MaxScore    float64    `json:"max_score"`
+	TestCases   []TestCase `json:"test_cases,omitempty"`
+
+	// Policy for graded attempts; launch custom parameters and the line
+	// item's endDateTime take precedence
+	Policy AssignmentPolicy `json:"policy"`
+
+	// ManualReview marks problems whose auto-grade an instructor must
+	// confirm; scores are reported as PendingManual until then
+	ManualReview bool `json:"manual_review,omitempty"`
+
+	// Rubric criteria instructors grade by hand on top of the autograde
+	Rubric []RubricCriterion `json:"rubric,omitempty"`
+
+	// LineItem is the gradebook column the tool created for the resource
+	// link, used when launches do not carry one
+	LineItem string `json:"lineitem,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+// ProblemFile is a problem shipped in a problem directory, with the
+// resource link it belongs to
+type ProblemFile struct {
+	ResourceKey
+	Problem Problem `json:"problem"`
+}
+
+// TestCase is one input/expected output pair a submission is checked against
+type TestCase struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name,omitempty"`
+	Input          string  `json:"input,omitempty"`
+	ExpectedOutput string  `json:"expected_output"`
+	Hidden         bool    `json:"hidden,omitempty"`
+	Weight         float64 `json:"weight,omitempty"`
+}
+
+// PublicView returns the problem as learners may see it: hidden test cases
+// are removed
+func (p Problem) PublicView() Problem {
+	public := p
+	public.TestCases = nil
+	for _, tc := range p.TestCases {
+		if !tc.Hidden {
+			public.TestCases = append(public.TestCases, tc)
+		}
+	}
+	return public
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Regrade is the result of running an attempt again after the problem's test
+// cases changed. The attempt's previous results are kept in the entry when
+// the new ones replace them.
+type Regrade struct {
+	JobID       string           `json:"job_id"`
+	At          time.Time        `json:"at"`
+	Status      SubmissionStatus `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Tests       []TestResult     `json:"tests,omitempty"`
+	Score       float64          `json:"score"`
+	RawScore    float64          `json:"raw_score,omitempty"`
+	LatePenalty float64          `json:"late_penalty,omitempty"`
+
+	// Applied is set when the new results became the attempt's results;
+	// attempts with an instructor override keep their grade
+	Applied       bool         `json:"applied"`
+	PreviousScore float64      `json:"previous_score"`
+	PreviousTests []TestResult `json:"previous_tests,omitempty"`
+}
+
+// RegradeStatus is where a regrade job is
+type RegradeStatus string
+
+const (
+	RegradeRunning   RegradeStatus = "running"
+	RegradeCompleted RegradeStatus = "completed"
+	RegradeFailed    RegradeStatus = "failed"
+)
+
+// RegradeJob reports the progress of regrading a resource link
+type RegradeJob struct {
+	ID string `json:"id"`
+	ResourceKey
+	Status  RegradeStatus `json:"status"`
+	Publish bool          `json:"publish"` // send the new grades to the platform when done
+	By      string        `json:"by"`
+
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Changed int `json:"changed"` // attempts whose score changed
+	Failed  int `json:"failed"`  // attempts Judge0 could not run
+
+	Resync     *ResyncReport `json:"resync,omitempty"` // grades published after the regrade
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+}
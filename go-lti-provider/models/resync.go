@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ResyncAction is what a grade resync did for one learner
+type ResyncAction string
+
+const (
+	ResyncUnchanged ResyncAction = "unchanged" // the platform already has the grade
+	ResyncChanged   ResyncAction = "changed"   // differs from the platform, not sent (dry run)
+	ResyncUpdated   ResyncAction = "updated"
+	ResyncFailed    ResyncAction = "failed"
+	ResyncSkipped   ResyncAction = "skipped" // no graded attempt or no line item
+)
+
+// ResyncItem compares one learner's recomputed grade with the platform's
+type ResyncItem struct {
+	UserID          string       `json:"user_id"`
+	LineItem        string       `json:"lineitem,omitempty"`
+	Grade           float64      `json:"grade"`
+	MaxScore        float64      `json:"max_score"`
+	PlatformScore   *float64     `json:"platform_score,omitempty"`
+	PlatformMaximum *float64     `json:"platform_maximum,omitempty"`
+	Rescored        int          `json:"rescored,omitempty"` // attempts whose late penalty changed
+	Action          ResyncAction `json:"action"`
+	Error           string       `json:"error,omitempty"`
+}
+
+// ResyncReport is the outcome of a grade resync of one resource link
+type ResyncReport struct {
+	ResourceKey
+	DryRun     bool                 `json:"dry_run"`
+	Counts     map[ResyncAction]int `json:"counts"`
+	Items      []ResyncItem         `json:"items"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+}
+
+// Add records the outcome for one learner
+func (r *ResyncReport) Add(item ResyncItem) {
+	if r.Counts == nil {
+		r.Counts = make(map[ResyncAction]int)
+	}
+	r.Items = append(r.Items, item)
+	r.Counts[item.Action]++
+}
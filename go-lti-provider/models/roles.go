@@ -0,0 +1,214 @@
+package models
+
+import "strings"
+
+// RoleScope is the LIS vocabulary a role belongs to
+type RoleScope string
+
+const (
+	RoleScopeContext     RoleScope = "context"
+	RoleScopeInstitution RoleScope = "institution"
+	RoleScopeSystem      RoleScope = "system"
+)
+
+// LIS v2 role vocabularies used in LTI 1.3
+const (
+	lisContextPrefix     = "http://purl.imsglobal.org/vocab/lis/v2/membership#"
+	lisContextSubPrefix  = "http://purl.imsglobal.org/vocab/lis/v2/membership/"
+	lisInstitutionPrefix = "http://purl.imsglobal.org/vocab/lis/v2/institution/person#"
+	lisSystemPrefix      = "http://purl.imsglobal.org/vocab/lis/v2/system/person#"
+
+	// LTI 1.1 URN forms, still sent by some platforms
+	urnContextPrefix     = "urn:lti:role:ims/lis/"
+	urnInstitutionPrefix = "urn:lti:instrole:ims/lis/"
+	urnSystemPrefix      = "urn:lti:sysrole:ims/lis/"
+)
+
+// Principal role names shared by the context, institution and system vocabularies
+const (
+	RoleAdministrator     = "Administrator"
+	RoleContentDeveloper  = "ContentDeveloper"
+	RoleInstructor        = "Instructor"
+	RoleLearner           = "Learner"
+	RoleMentor            = "Mentor"
+	RoleManager           = "Manager"
+	RoleMember            = "Member"
+	RoleOfficer           = "Officer"
+	RoleFaculty           = "Faculty"
+	RoleStudent           = "Student"
+	RoleStaff             = "Staff"
+	RoleSysAdmin          = "SysAdmin"
+	RoleSysSupport        = "SysSupport"
+	RoleTeachingAssistant = "TeachingAssistant"
+)
+
+// Role is a parsed LIS role such as membership/Instructor#TeachingAssistant
+type Role struct {
+	Scope   RoleScope `json:"scope"`
+	Name    string    `json:"name"`
+	SubRole string    `json:"sub_role,omitempty"`
+}
+
+// ParseRole parses a role URI, an LTI 1.1 URN or a short context role name.
+// ok is false for values outside the LIS vocabularies.
+func ParseRole(value string) (role Role, ok bool) {
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.HasPrefix(value, lisContextPrefix):
+		return Role{Scope: RoleScopeContext, Name: strings.TrimPrefix(value, lisContextPrefix)}, true
+	case strings.HasPrefix(value, lisContextSubPrefix):
+		// membership/Instructor#TeachingAssistant
+		principal, sub, found := strings.Cut(strings.TrimPrefix(value, lisContextSubPrefix), "#")
+		if !found {
+			return Role{}, false
+		}
+		return Role{Scope: RoleScopeContext, Name: principal, SubRole: sub}, true
+	case strings.HasPrefix(value, lisInstitutionPrefix):
+		return Role{Scope: RoleScopeInstitution, Name: strings.TrimPrefix(value, lisInstitutionPrefix)}, true
+	case strings.HasPrefix(value, lisSystemPrefix):
+		return Role{Scope: RoleScopeSystem, Name: strings.TrimPrefix(value, lisSystemPrefix)}, true
+	case strings.HasPrefix(value, urnContextPrefix):
+		return parseURNRole(RoleScopeContext, strings.TrimPrefix(value, urnContextPrefix)), true
+	case strings.HasPrefix(value, urnInstitutionPrefix):
+		return parseURNRole(RoleScopeInstitution, strings.TrimPrefix(value, urnInstitutionPrefix)), true
+	case strings.HasPrefix(value, urnSystemPrefix):
+		return parseURNRole(RoleScopeSystem, strings.TrimPrefix(value, urnSystemPrefix)), true
+	case value != "" && !strings.ContainsAny(value, ":/#"):
+		// Short form, only defined for context roles
+		return Role{Scope: RoleScopeContext, Name: value}, true
+	}
+
+	return Role{}, false
+}
+
+// parseURNRole parses "Instructor" or "Instructor/TeachingAssistant"
+func parseURNRole(scope RoleScope, value string) Role {
+	principal, sub, _ := strings.Cut(value, "/")
+	return Role{Scope: scope, Name: principal, SubRole: sub}
+}
+
+// Roles is the set of roles a user has in a launch
+type Roles []Role
+
+// ParseRoles parses the roles claim, skipping values it does not understand
+func ParseRoles(values []string) Roles {
+	roles := make(Roles, 0, len(values))
+	for _, v := range values {
+		if role, ok := ParseRole(v); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Has reports whether the user has the principal role in the given scope
+func (rs Roles) Has(scope RoleScope, name string) bool {
+	for _, r := range rs {
+		if r.Scope == scope && r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSubRole reports whether the user has the context sub-role, e.g. Instructor#TeachingAssistant
+func (rs Roles) HasSubRole(name, subRole string) bool {
+	for _, r := range rs {
+		if r.Scope == RoleScopeContext && r.Name == name && r.SubRole == subRole {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports a system or institution administrator
+func (rs Roles) IsAdmin() bool {
+	return rs.Has(RoleScopeSystem, RoleAdministrator) ||
+		rs.Has(RoleScopeSystem, RoleSysAdmin) ||
+		rs.Has(RoleScopeInstitution, RoleAdministrator)
+}
+
+// IsTeachingAssistant reports a context teaching assistant
+func (rs Roles) IsTeachingAssistant() bool {
+	return rs.HasSubRole(RoleInstructor, RoleTeachingAssistant) ||
+		rs.Has(RoleScopeContext, RoleTeachingAssistant)
+}
+
+// IsInstructor reports someone who teaches the context. Teaching assistants
+// are reported separately and are not instructors.
+func (rs Roles) IsInstructor() bool {
+	for _, r := range rs {
+		if r.Scope != RoleScopeContext {
+			continue
+		}
+		switch r.Name {
+		case RoleInstructor:
+			if r.SubRole != RoleTeachingAssistant {
+				return true
+			}
+		case RoleAdministrator, RoleContentDeveloper:
+			return true
+		}
+	}
+	return false
+}
+
+// IsLearner reports a learner in the context (or a student of the institution)
+func (rs Roles) IsLearner() bool {
+	return rs.Has(RoleScopeContext, RoleLearner) || rs.Has(RoleScopeInstitution, RoleStudent) ||
+		rs.Has(RoleScopeInstitution, RoleLearner)
+}
+
+// Experience picks what the tool shows for the roles. Instructors win over
+// teaching assistants, who win over learners; anyone else gets the learner view.
+func (rs Roles) Experience() Experience {
+	switch {
+	case rs.IsInstructor() || rs.IsAdmin():
+		return ExperienceInstructor
+	case rs.IsTeachingAssistant():
+		return ExperienceTeachingAssistant
+	default:
+		return ExperienceLearner
+	}
+}
+
+// Experience is the part of the tool a launch is routed to
+type Experience string
+
+const (
+	ExperienceInstructor        Experience = "instructor"
+	ExperienceTeachingAssistant Experience = "teaching_assistant"
+	ExperienceLearner           Experience = "learner"
+)
+
+// Permission is an action a launch session may perform
+type Permission string
+
+const (
+	PermissionSubmit           Permission = "submit"
+	PermissionConfigureProblem Permission = "configure_problem"
+	PermissionViewSubmissions  Permission = "view_submissions"
+	PermissionOverrideGrade    Permission = "override_grade"
+)
+
+// AllPermissions lists every permission, in the order instructors see them
+var AllPermissions = []Permission{
+	PermissionSubmit,
+	PermissionConfigureProblem,
+	PermissionViewSubmissions,
+	PermissionOverrideGrade,
+}
+
+// PermissionsFor returns the permissions granted to an experience. Teaching
+// assistants get the configured taPermissions.
+func PermissionsFor(experience Experience, taPermissions []Permission) []Permission {
+	switch experience {
+	case ExperienceInstructor:
+		return append([]Permission(nil), AllPermissions...)
+	case ExperienceTeachingAssistant:
+		return append([]Permission(nil), taPermissions...)
+	default:
+		return []Permission{PermissionSubmit}
+	}
+}
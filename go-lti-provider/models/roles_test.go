@@ -0,0 +1,121 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Role
+		ok    bool
+	}{
+		{"http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor", Role{Scope: RoleScopeContext, Name: RoleInstructor}, true},
+		{"http://purl.imsglobal.org/vocab/lis/v2/membership/Instructor#TeachingAssistant",
+			Role{Scope: RoleScopeContext, Name: RoleInstructor, SubRole: RoleTeachingAssistant}, true},
+		{"http://purl.imsglobal.org/vocab/lis/v2/membership/Instructor", Role{}, false},
+		{"http://purl.imsglobal.org/vocab/lis/v2/institution/person#Student", Role{Scope: RoleScopeInstitution, Name: RoleStudent}, true},
+		{"http://purl.imsglobal.org/vocab/lis/v2/system/person#Administrator", Role{Scope: RoleScopeSystem, Name: RoleAdministrator}, true},
+		{"urn:lti:role:ims/lis/Instructor/TeachingAssistant",
+			Role{Scope: RoleScopeContext, Name: RoleInstructor, SubRole: RoleTeachingAssistant}, true},
+		{"urn:lti:instrole:ims/lis/Administrator", Role{Scope: RoleScopeInstitution, Name: RoleAdministrator}, true},
+		{"urn:lti:sysrole:ims/lis/SysAdmin", Role{Scope: RoleScopeSystem, Name: RoleSysAdmin}, true},
+		{" Learner ", Role{Scope: RoleScopeContext, Name: RoleLearner}, true},
+		{"", Role{}, false},
+		{"http://example.com/roles#Instructor", Role{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseRole(tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseRole(%q) = %+v, %v; want %+v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRolesExperience(t *testing.T) {
+	const (
+		instructor = "http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor"
+		ta         = "http://purl.imsglobal.org/vocab/lis/v2/membership/Instructor#TeachingAssistant"
+		learner    = "http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"
+		sysAdmin   = "http://purl.imsglobal.org/vocab/lis/v2/system/person#Administrator"
+		instAdmin  = "http://purl.imsglobal.org/vocab/lis/v2/institution/person#Administrator"
+		student    = "http://purl.imsglobal.org/vocab/lis/v2/institution/person#Student"
+	)
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  Experience
+	}{
+		{"instructor", []string{instructor}, ExperienceInstructor},
+		{"short instructor", []string{"Instructor"}, ExperienceInstructor},
+		{"content developer", []string{"ContentDeveloper"}, ExperienceInstructor},
+		{"system admin", []string{sysAdmin}, ExperienceInstructor},
+		{"institution admin", []string{instAdmin}, ExperienceInstructor},
+		{"teaching assistant sub-role", []string{ta}, ExperienceTeachingAssistant},
+		{"teaching assistant context role", []string{"TeachingAssistant"}, ExperienceTeachingAssistant},
+		{"instructor wins over TA", []string{ta, instructor}, ExperienceInstructor},
+		{"TA wins over learner", []string{learner, ta}, ExperienceTeachingAssistant},
+		{"learner", []string{learner}, ExperienceLearner},
+		{"institution student", []string{student}, ExperienceLearner},
+		{"institution instructor is not a context instructor", []string{"http://purl.imsglobal.org/vocab/lis/v2/institution/person#Instructor"}, ExperienceLearner},
+		{"mentor", []string{"Mentor"}, ExperienceLearner},
+		{"no roles", nil, ExperienceLearner},
+		{"unknown roles", []string{"http://example.com/roles#Instructor"}, ExperienceLearner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRoles(tt.roles).Experience(); got != tt.want {
+				t.Errorf("Experience() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermissionsFor(t *testing.T) {
+	taPermissions := []Permission{PermissionViewSubmissions}
+
+	tests := []struct {
+		experience Experience
+		want       []Permission
+	}{
+		{ExperienceInstructor, AllPermissions},
+		{ExperienceTeachingAssistant, taPermissions},
+		{ExperienceLearner, []Permission{PermissionSubmit}},
+		{Experience("unknown"), []Permission{PermissionSubmit}},
+	}
+
+	for _, tt := range tests {
+		got := PermissionsFor(tt.experience, taPermissions)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PermissionsFor(%q) = %v, want %v", tt.experience, got, tt.want)
+		}
+	}
+
+	// The result is a copy: granting more to one session changes nobody else
+	got := PermissionsFor(ExperienceInstructor, nil)
+	got[0] = "changed"
+	if AllPermissions[0] == "changed" {
+		t.Error("PermissionsFor returned AllPermissions itself")
+	}
+}
+
+func TestLaunchSessionCan(t *testing.T) {
+	learner := &LaunchSession{Permissions: PermissionsFor(ExperienceLearner, nil)}
+	if !learner.Can(PermissionSubmit) {
+		t.Error("learner cannot submit")
+	}
+	for _, p := range []Permission{PermissionConfigureProblem, PermissionViewSubmissions, PermissionOverrideGrade} {
+		if learner.Can(p) {
+			t.Errorf("learner can %s", p)
+		}
+	}
+
+	ta := &LaunchSession{Permissions: PermissionsFor(ExperienceTeachingAssistant, []Permission{PermissionViewSubmissions})}
+	if ta.Can(PermissionOverrideGrade) || !ta.Can(PermissionViewSubmissions) {
+		t.Errorf("TA permissions = %v", ta.Permissions)
+	}
+}
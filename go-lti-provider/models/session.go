@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ResourceKey identifies one placement of the tool: a resource link in a
+// context on a platform
+type ResourceKey struct {
+	Issuer         string `json:"issuer"`
+	ContextID      string `json:"context_id"`
+	ResourceLinkID string `json:"resource_link_id"`
+}
+
+// String returns the key in a form usable as a map key
+func (k ResourceKey) String() string {
+	return k.Issuer + "|" + k.ContextID + "|" + k.ResourceLinkID
+}
+
+// LaunchSession is what the tool remembers about a validated launch. It is
+// handed to the frontend as a signed token and sent back on API calls.
+type LaunchSession struct {
+	ResourceKey
+
+	MessageType       string            `json:"message_type,omitempty"`
+	ClientID          string            `json:"client_id"`
+	DeploymentID      string            `json:"deployment_id"`
+	UserID            string            `json:"user_id"`
+	ForUserID         string            `json:"for_user_id,omitempty"` // learner under review (submission review launches)
+	Name              string            `json:"name,omitempty"`
+	ContextTitle      string            `json:"context_title,omitempty"`
+	ResourceLinkTitle string            `json:"resource_link_title,omitempty"`
+	LineItem          string            `json:"lineitem,omitempty"`
+	LineItems         string            `json:"lineitems,omitempty"`
+	ReturnURL         string            `json:"return_url,omitempty"`
+	Custom            map[string]string `json:"custom,omitempty"`
+	Roles             []string          `json:"roles"`
+	Experience        Experience        `json:"experience"`
+	Permissions       []Permission      `json:"permissions"`
+	ExpiresAt         time.Time         `json:"expires_at"`
+}
+
+// Can reports whether the session has a permission
+func (s *LaunchSession) Can(p Permission) bool {
+	for _, granted := range s.Permissions {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
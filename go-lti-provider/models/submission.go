@@ -0,0 +1,112 @@
+package models
+
+import "time"
+
+// SubmissionStatus is where a stored submission is in its lifecycle
+type SubmissionStatus string
+
+const (
+	SubmissionPending   SubmissionStatus = "pending" // waiting for Judge0
+	SubmissionCompleted SubmissionStatus = "completed"
+	SubmissionFailed    SubmissionStatus = "failed"
+)
+
+// SubmissionRecord is one stored attempt of a learner on a resource link
+type SubmissionRecord struct {
+	ID string `json:"id"`
+	ResourceKey
+	UserID string `json:"user_id"`
+
+	Source     string `json:"source,omitempty"`
+	Language   string `json:"language"`
+	LanguageID int    `json:"language_id"`
+
+	Status SubmissionStatus `json:"status"`
+	Error  string           `json:"error,omitempty"`
+
+	// GradingProgress is the AGS grading progress last reported for the attempt
+	GradingProgress string `json:"grading_progress,omitempty"`
+	LineItem        string `json:"lineitem,omitempty"` // where the grade was sent
+
+	// Result is the raw Judge0 result of runs without test cases
+	Result   *Judge0Response `json:"result,omitempty"`
+	Tests    []TestResult    `json:"tests,omitempty"`
+	Score    float64         `json:"score"`
+	MaxScore float64         `json:"max_score"`
+
+	// RawScore is the score before the late penalty (in percent) was applied
+	RawScore    float64 `json:"raw_score,omitempty"`
+	LatePenalty float64 `json:"late_penalty,omitempty"`
+
+	// Override is the instructor's manual grade, replacing Score's autograde
+	Override  *GradeOverride `json:"override,omitempty"`
+	AutoScore *float64       `json:"auto_score,omitempty"` // autograde before the first override
+
+	// Regrades are the re-runs after test case changes, oldest first
+	Regrades []Regrade `json:"regrades,omitempty"`
+
+	Judge0Tokens []string  `json:"judge0_tokens,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	CompletedAt  time.Time `json:"completed_at,omitempty"`
+}
+
+// TestResult is the outcome of running a submission against one test case
+type TestResult struct {
+	TestCaseID    string  `json:"test_case_id"`
+	Name          string  `json:"name,omitempty"`
+	Hidden        bool    `json:"hidden,omitempty"`
+	Weight        float64 `json:"weight,omitempty"`
+	Passed        bool    `json:"passed"`
+	Status        Status  `json:"status"`
+	Stdout        *string `json:"stdout,omitempty"`
+	Stderr        *string `json:"stderr,omitempty"`
+	CompileOutput *string `json:"compile_output,omitempty"`
+	Time          *string `json:"time,omitempty"`
+	Memory        *int    `json:"memory,omitempty"`
+	Token         string  `json:"token,omitempty"`
+}
+
+// LearnerView returns the record as its learner may see it: output of hidden
+// test cases is removed, only the verdict is kept
+func (s SubmissionRecord) LearnerView() SubmissionRecord {
+	view := s
+	view.Tests = withoutOutput(s.Tests, true)
+	view.Regrades = make([]Regrade, len(s.Regrades))
+	for i, rg := range s.Regrades {
+		rg.Tests = withoutOutput(rg.Tests, true)
+		rg.PreviousTests = withoutOutput(rg.PreviousTests, true)
+		view.Regrades[i] = rg
+	}
+	return view
+}
+
+// Summary returns the record without source code and program output, for listings
+func (s SubmissionRecord) Summary() SubmissionRecord {
+	summary := s
+	summary.Source = ""
+	summary.Result = nil
+	summary.Tests = withoutOutput(s.Tests, false)
+	summary.Regrades = make([]Regrade, len(s.Regrades))
+	for i, rg := range s.Regrades {
+		rg.Tests = withoutOutput(rg.Tests, false)
+		rg.PreviousTests = withoutOutput(rg.PreviousTests, false)
+		summary.Regrades[i] = rg
+	}
+	return summary
+}
+
+// withoutOutput copies test results without program output, of hidden test
+// cases only or of all of them
+func withoutOutput(tests []TestResult, hiddenOnly bool) []TestResult {
+	if tests == nil {
+		return nil
+	}
+	copied := make([]TestResult, len(tests))
+	for i, t := range tests {
+		if t.Hidden || !hiddenOnly {
+			t.Stdout, t.Stderr, t.CompileOutput = nil, nil, nil
+		}
+		copied[i] = t
+	}
+	return copied
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Theme customizes the pages the tool renders itself (launch result,
+// registration) for a platform. Empty fields use the default theme.
+type Theme struct {
+	Name            string `json:"name,omitempty"`
+	PrimaryColor    string `json:"primary_color,omitempty"` // links and buttons
+	HeaderColor     string `json:"header_color,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+	LogoURL         string `json:"logo_url,omitempty"`     // https or a path on the tool
+	ReturnLabel     string `json:"return_label,omitempty"` // text of the link back to the platform
+}
+
+// DefaultTheme is used for platforms without a theme
+var DefaultTheme = Theme{
+	Name:            "default",
+	PrimaryColor:    "#007bff",
+	HeaderColor:     "#2e7d32",
+	BackgroundColor: "#f5f5f5",
+	ReturnLabel:     "← Return to Moodle",
+}
+
+// themeColor accepts #rgb and #rrggbb colors only, so a theme cannot inject CSS
+var themeColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Merge returns t with its empty or invalid fields taken from base
+func (t Theme) Merge(base Theme) Theme {
+	merged := base
+	if t.Name != "" {
+		merged.Name = t.Name
+	}
+	if themeColor.MatchString(t.PrimaryColor) {
+		merged.PrimaryColor = t.PrimaryColor
+	}
+	if themeColor.MatchString(t.HeaderColor) {
+		merged.HeaderColor = t.HeaderColor
+	}
+	if themeColor.MatchString(t.BackgroundColor) {
+		merged.BackgroundColor = t.BackgroundColor
+	}
+	if strings.HasPrefix(t.LogoURL, "https://") || strings.HasPrefix(t.LogoURL, "/") {
+		merged.LogoURL = t.LogoURL
+	}
+	if t.ReturnLabel != "" {
+		merged.ReturnLabel = t.ReturnLabel
+	}
+	return merged
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/metrics"
+)
+
+// judge0BusyRetryAfter is the Retry-After sent while Judge0's queue is too long
+const judge0BusyRetryAfter = 10 * time.Second
+
+// userConcurrencyRetryAfter is the Retry-After sent to a user whose runs are
+// all still in progress
+const userConcurrencyRetryAfter = 2 * time.Second
+
+// AdmissionError is returned when a run or submit is not admitted now
+type AdmissionError struct {
+	Code       string // judge0_unavailable, judge0_busy, user_concurrency, context_rate, global_rate
+	Reason     string // shown to the learner
+	RetryAfter time.Duration
+}
+
+func (e *AdmissionError) Error() string {
+	return e.Reason
+}
+
+// Admission decides whether a run or submit may go to Judge0 now, so one
+// user or one course cannot take Judge0 away from everyone else. It checks,
+// in order: the Judge0 circuit breaker, Judge0's queue, the user's runs in
+// progress, and the per-course and global token buckets.
+type Admission struct {
+	Context *RateLimiter // per course (issuer|context)
+	Global  *RateLimiter
+
+	mu      sync.Mutex
+	running map[string]int // user -> runs in progress
+}
+
+// NewAdmission creates an Admission allowing contextLimit runs per minute
+// per course and globalLimit in total (0 = unlimited)
+func NewAdmission(contextLimit, globalLimit int) *Admission {
+	return &Admission{
+		Context: NewRateLimiter(contextLimit, time.Minute),
+		Global:  NewRateLimiter(globalLimit, time.Minute),
+		running: make(map[string]int),
+	}
+}
+
+// Admit admits one run for user in course (empty without a launch). The
+// returned release must be called once the run is done; it may be called
+// more than once.
+func (a *Admission) Admit(user, course string) (release func(), err error) {
+	cfg := config.LoadConfig()
+
+	if ok, wait := Judge0Ready(); !ok {
+		return nil, reject("judge0_unavailable", "Code execution is temporarily unavailable, please try again shortly", wait)
+	}
+	if max := cfg.Judge0AdmissionQueue; max > 0 {
+		if queued, ok := Judge0QueueSize(); ok && queued >= max {
+			return nil, reject("judge0_busy", "The code runner is busy, please try again shortly", judge0BusyRetryAfter)
+		}
+	}
+
+	a.mu.Lock()
+	if max := cfg.UserConcurrency; max > 0 && a.running[user] >= max {
+		a.mu.Unlock()
+		return nil, reject("user_concurrency",
+			fmt.Sprintf("You already have %d runs in progress, please wait for them to finish", max), userConcurrencyRetryAfter)
+	}
+	a.running[user]++
+	a.mu.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.running[user]--; a.running[user] <= 0 {
+				delete(a.running, user)
+			}
+		})
+	}
+
+	if course != "" {
+		if ok, wait := a.Context.Allow(course); !ok {
+			release()
+			return nil, reject("context_rate", "Too many runs in this course right now, please try again shortly", wait)
+		}
+	}
+	if ok, wait := a.Global.Allow(""); !ok {
+		release()
+		return nil, reject("global_rate", "Too many runs right now, please try again shortly", wait)
+	}
+	return release, nil
+}
+
+func reject(code, reason string, retryAfter time.Duration) *AdmissionError {
+	metrics.ExecutionsRejected.WithLabelValues(code).Inc()
+	return &AdmissionError{Code: code, Reason: reason, RetryAfter: retryAfter}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-lti-provider/config"
+)
+
+// useAdmissionConfig sets the admission limits for a test, with a closed
+// Judge0 breaker and no queue length seen
+func useAdmissionConfig(t *testing.T, userConcurrency, admissionQueue int) {
+	t.Helper()
+	previous := config.LoadConfig()
+	cfg := *previous
+	cfg.UserConcurrency, cfg.Judge0AdmissionQueue = userConcurrency, admissionQueue
+	config.Use(&cfg)
+
+	breaker, queue := judge0Breaker, judge0Queue.Load()
+	judge0Breaker = NewCircuitBreaker(1, time.Hour)
+	judge0Queue.Store(nil)
+	t.Cleanup(func() {
+		config.Use(previous)
+		judge0Breaker = breaker
+		judge0Queue.Store(queue)
+	})
+}
+
+func admissionCode(err error) string {
+	var rejected *AdmissionError
+	if errors.As(err, &rejected) {
+		return rejected.Code
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestAdmissionAdmit(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(a *Admission)
+		user  string
+		want  string // rejection code, "" = admitted
+	}{
+		{"admitted", func(a *Admission) {}, "u1", ""},
+		{"breaker open", func(a *Admission) { judge0Breaker.Record(false) }, "u1", "judge0_unavailable"},
+		{"queue full", func(a *Admission) { judge0Queue.Store(&queueSample{size: 5, at: time.Now()}) }, "u1", "judge0_busy"},
+		{"queue below the limit", func(a *Admission) { judge0Queue.Store(&queueSample{size: 4, at: time.Now()}) }, "u1", ""},
+		{"queue length too old to trust", func(a *Admission) {
+			judge0Queue.Store(&queueSample{size: 50, at: time.Now().Add(-2 * judge0QueueMaxAge)})
+		}, "u1", ""},
+		{"user runs in progress", func(a *Admission) {
+			a.Admit("u1", "")
+			a.Admit("u1", "")
+		}, "u1", "user_concurrency"},
+		{"other user's runs", func(a *Admission) {
+			a.Admit("u2", "")
+			a.Admit("u2", "")
+		}, "u1", ""},
+		{"course limit", func(a *Admission) { a.Admit("u2", "lms|course-1") }, "u1", "context_rate"},
+		{"global limit", func(a *Admission) {
+			a.Admit("u2", "lms|course-2")
+			a.Admit("u3", "lms|course-3")
+			a.Admit("u4", "lms|course-4")
+		}, "u1", "global_rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useAdmissionConfig(t, 2, 5)
+			a := NewAdmission(1, 3)
+			tt.setup(a)
+
+			release, err := a.Admit(tt.user, "lms|course-1")
+			if got := admissionCode(err); got != tt.want {
+				t.Fatalf("Admit() = %q, want %q", got, tt.want)
+			}
+			if err != nil {
+				if release != nil {
+					t.Error("Admit() returned a release with its error")
+				}
+				var rejected *AdmissionError
+				if errors.As(err, &rejected) && (rejected.RetryAfter <= 0 || rejected.Reason == "") {
+					t.Errorf("AdmissionError = %+v, want a reason and a Retry-After", rejected)
+				}
+				return
+			}
+			release()
+		})
+	}
+}
+
+func TestAdmissionRelease(t *testing.T) {
+	useAdmissionConfig(t, 1, 0)
+	a := NewAdmission(0, 0)
+
+	release, err := a.Admit("u1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Admit("u1", ""); admissionCode(err) != "user_concurrency" {
+		t.Fatalf("second Admit() = %v, want user_concurrency", err)
+	}
+
+	// Releasing twice frees one slot only
+	release()
+	release()
+	second, err := a.Admit("u1", "")
+	if err != nil {
+		t.Fatalf("Admit() after release = %v", err)
+	}
+	if _, err := a.Admit("u1", ""); admissionCode(err) != "user_concurrency" {
+		t.Errorf("Admit() after a double release = %v, want user_concurrency", err)
+	}
+	second()
+	if len(a.running) != 0 {
+		t.Errorf("running = %v, want empty once every run is released", a.running)
+	}
+}
+
+func TestAdmissionRateRejectionFreesTheSlot(t *testing.T) {
+	useAdmissionConfig(t, 1, 0)
+	a := NewAdmission(1, 0)
+
+	release, err := a.Admit("u1", "lms|course-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, err := a.Admit("u1", "lms|course-1"); admissionCode(err) != "context_rate" {
+		t.Fatalf("Admit() = %v, want context_rate", err)
+	}
+	// The rejected run does not hold the user's only slot
+	if _, err := a.Admit("u1", "lms|course-2"); err != nil {
+		t.Errorf("Admit() in another course = %v", err)
+	}
+}
@@ -2,51 +2,76 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"go-lti-provider/logging"
+	"go-lti-provider/lti"
+	"go-lti-provider/metrics"
 	"go-lti-provider/models"
+	"go-lti-provider/tracing"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // AGSService handles interaction with Moodle's Assignment and Grade Services
 type AGSService struct {
 	TokenURL     string
 	ClientID     string
-	ClientSecret string
+	ClientSecret string   // only used without Key
+	Key          *ToolKey // signs client assertions (private_key_jwt)
 }
 
-// NewAGSService creates a new AGSService instance
-func NewAGSService(tokenURL, clientID, clientSecret string) *AGSService {
+// NewAGSService creates a new AGSService instance. With a tool key the
+// token requests are authenticated with a signed client assertion, as LTI
+// 1.3 requires; the client secret is for platforms set up without one.
+func NewAGSService(tokenURL, clientID, clientSecret string, key *ToolKey) *AGSService {
 	return &AGSService{
 		TokenURL:     tokenURL,
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
+		Key:          key,
 	}
 }
 
 // SubmitGrade submits a grade to Moodle AGS
-func (s *AGSService) SubmitGrade(req models.AGSGradeRequest) error {
+func (s *AGSService) SubmitGrade(ctx context.Context, req models.AGSGradeRequest) error {
 	// Get access token if not provided
 	accessToken := req.AccessToken
 	if accessToken == "" {
-		token, err := s.getAccessToken()
+		token, err := s.AccessToken(ctx, lti.ScopeScore)
 		if err != nil {
 			return fmt.Errorf("failed to get access token: %w", err)
 		}
 		accessToken = token
 	}
 
-	// Create grade payload
-	grade := models.Grade{
-		ScoreGiven:       req.Score,
-		ScoreMaximum:     req.MaxScore,
+	// Create grade payload; progress updates without a score omit it
+	grade := lti.Score{
 		Comment:          req.Comment,
-		ActivityProgress: "Completed",
-		GradingProgress:  "FullyGraded",
-		Timestamp:        time.Now().Format(time.RFC3339),
+		ActivityProgress: req.ActivityProgress,
+		GradingProgress:  req.GradingProgress,
+		Timestamp:        time.Now().Format(time.RFC3339Nano),
 		UserID:           req.UserID,
 	}
+	if req.Score != nil {
+		maxScore := req.MaxScore
+		grade.ScoreGiven = req.Score
+		grade.ScoreMaximum = &maxScore
+	}
+	if grade.ActivityProgress == "" {
+		grade.ActivityProgress = lti.ActivityCompleted
+	}
+	if grade.GradingProgress == "" {
+		grade.GradingProgress = lti.GradingFullyGraded
+	}
 
 	jsonData, err := json.Marshal(grade)
 	if err != nil {
@@ -54,17 +79,16 @@ func (s *AGSService) SubmitGrade(req models.AGSGradeRequest) error {
 	}
 
 	// Submit grade to AGS endpoint
-	scoreURL := req.LineItemURL + "/scores"
-	httpReq, err := http.NewRequest("POST", scoreURL, bytes.NewBuffer(jsonData))
+	scoreURL := lineItemServiceURL(req.LineItemURL, "scores")
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", scoreURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create grade request: %w", err)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/vnd.ims.lis.v1.score+json")
+	httpReq.Header.Set("Content-Type", lti.MediaTypeScore)
 	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
+	resp, err := doPlatformRequest("score", httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to submit grade: %w", err)
 	}
@@ -77,41 +101,255 @@ func (s *AGSService) SubmitGrade(req models.AGSGradeRequest) error {
 	return nil
 }
 
-// getAccessToken gets an OAuth2 access token from Moodle
-func (s *AGSService) getAccessToken() (string, error) {
-	data := fmt.Sprintf("grant_type=client_credentials&client_id=%s&client_secret=%s&scope=%s",
-		s.ClientID,
-		s.ClientSecret,
-		"https://purl.imsglobal.org/spec/lti-ags/scope/score")
+// GetLineItem fetches a line item, e.g. to read its endDateTime
+func (s *AGSService) GetLineItem(ctx context.Context, lineItemURL string) (*lti.LineItem, error) {
+	accessToken, err := s.AccessToken(ctx, lti.ScopeLineItemReadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", lineItemURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create line item request: %w", err)
+	}
+	httpReq.Header.Set("Accept", lti.MediaTypeLineItem)
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := doPlatformRequest("lineitem", httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch line item: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("line item request failed with status %d", resp.StatusCode)
+	}
+
+	var lineItem lti.LineItem
+	if err := json.NewDecoder(resp.Body).Decode(&lineItem); err != nil {
+		return nil, fmt.Errorf("failed to decode line item: %w", err)
+	}
+	return &lineItem, nil
+}
 
-	req, err := http.NewRequest("POST", s.TokenURL, bytes.NewBufferString(data))
+// GetResults fetches the current grade of every learner in a line item,
+// following the platform's paging links
+func (s *AGSService) GetResults(ctx context.Context, lineItemURL string) ([]lti.Result, error) {
+	accessToken, err := s.AccessToken(ctx, lti.ScopeResultReadOnly)
 	if err != nil {
-		return "", fmt.Errorf("failed to create token request: %w", err)
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var results []lti.Result
+	for next := lineItemServiceURL(lineItemURL, "results"); next != ""; {
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create results request: %w", err)
+		}
+		httpReq.Header.Set("Accept", lti.MediaTypeResultContainer)
+		httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+		resp, err := doPlatformRequest("results", httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch results: %w", err)
+		}
+
+		var page []lti.Result
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("results request failed with status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode results: %w", err)
+		}
+
+		results = append(results, page...)
+		next = nextPageURL(resp.Header.Values("Link"))
+	}
+	return results, nil
+}
+
+// CreateLineItem adds a line item to the context's line items container and
+// returns it as created by the platform
+func (s *AGSService) CreateLineItem(ctx context.Context, lineItemsURL string, item lti.LineItem) (*lti.LineItem, error) {
+	accessToken, err := s.AccessToken(ctx, lti.ScopeLineItem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	jsonData, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal line item: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", lineItemsURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create line item request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", lti.MediaTypeLineItem)
+	httpReq.Header.Set("Accept", lti.MediaTypeLineItem)
+	httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := doPlatformRequest("create_lineitem", httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create line item: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("line item creation failed with status %d", resp.StatusCode)
+	}
+
+	var created lti.LineItem
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("failed to decode line item: %w", err)
+	}
+	return &created, nil
+}
+
+// lineItemServiceURL returns the URL of a line item sub-service (scores,
+// results). Moodle line item URLs carry a query string, e.g.
+// .../lineitems/5/lineitem?type_id=2, so the service goes into the path.
+func lineItemServiceURL(lineItemURL, service string) string {
+	u, err := url.Parse(lineItemURL)
+	if err != nil {
+		return lineItemURL + "/" + service
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + service
+	return u.String()
+}
+
+// nextPageURL returns the rel="next" target of Link headers, or ""
+func nextPageURL(links []string) string {
+	for _, header := range links {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+				continue
+			}
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// tokenExpiryMargin is how long before expiry a cached token is replaced
+const tokenExpiryMargin = 30 * time.Second
+
+// accessTokens caches access tokens per token endpoint, client and scope.
+// AGSService values are created per request, so the cache is shared.
+var accessTokens = struct {
+	sync.Mutex
+	tokens map[string]cachedToken
+}{tokens: make(map[string]cachedToken)}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+// AccessToken gets an OAuth2 access token from Moodle for scope (one AGS
+// scope, or several separated by spaces), reusing a cached one until
+// shortly before it expires
+func (s *AGSService) AccessToken(ctx context.Context, scope string) (string, error) {
+	key := s.TokenURL + "|" + s.ClientID + "|" + scope
+
+	accessTokens.Lock()
+	cached, ok := accessTokens.tokens[key]
+	accessTokens.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		metrics.TokenCache.WithLabelValues("hit").Inc()
+		return cached.token, nil
+	}
+	metrics.TokenCache.WithLabelValues("miss").Inc()
+
+	tokenResp, err := s.requestAccessToken(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+
+	if lifetime := time.Duration(tokenResp.ExpiresIn)*time.Second - tokenExpiryMargin; lifetime > 0 {
+		accessTokens.Lock()
+		accessTokens.tokens[key] = cachedToken{token: tokenResp.AccessToken, expires: time.Now().Add(lifetime)}
+		accessTokens.Unlock()
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// requestAccessToken asks the token endpoint for a new access token
+func (s *AGSService) requestAccessToken(ctx context.Context, scope string) (*lti.TokenResponse, error) {
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {scope},
+	}
+	if s.Key != nil {
+		assertion, err := s.Key.ClientAssertion(s.ClientID, s.TokenURL)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	} else {
+		form.Set("client_id", s.ClientID)
+		form.Set("client_secret", s.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := doPlatformRequest("token", req)
 	if err != nil {
-		return "", fmt.Errorf("failed to request token: %w", err)
+		return nil, fmt.Errorf("failed to request token: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+		return nil, fmt.Errorf("token request failed with status %d", resp.StatusCode)
 	}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int    `json:"expires_in"`
-		Scope       string `json:"scope"`
-	}
+	var tokenResp lti.TokenResponse
 
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
 	}
 
-	return tokenResp.AccessToken, nil
+	return &tokenResp, nil
+}
+
+// doPlatformRequest sends a request to the platform and records its latency
+// and outcome under operation
+func doPlatformRequest(operation string, req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Start(req.Context(), "ags."+operation)
+	req = req.WithContext(ctx)
+	setRequestID(req)
+	start := time.Now()
+	resp, err := tracing.HTTPClient(30 * time.Second).Do(req)
+	metrics.ObserveAGS(operation, start, err == nil && resp.StatusCode < 300)
+
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 300 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+	tracing.End(span, err)
+	slog.DebugContext(req.Context(), "Platform request", "operation", operation, "method", req.Method,
+		"url", req.URL.Redacted(), "status", status, "duration", time.Since(start), "error", err)
+	return resp, err
+}
+
+// setRequestID passes the request ID of the request's context on, so the
+// other system's logs can be matched with ours
+func setRequestID(req *http.Request) {
+	if id := logging.RequestID(req.Context()); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
 }
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-lti-provider/models"
+)
+
+// AuditLog is an append-only record of grade changes, stored as one JSON
+// object per line so entries are never rewritten
+type AuditLog struct {
+	mu      sync.RWMutex
+	path    string
+	entries []models.AuditEntry
+}
+
+// NewAuditLog loads the entries already written to path (if any)
+func NewAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{path: path}
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode audit log: %w", err)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return l, nil
+}
+
+// Append adds an entry and writes it to the log file
+func (l *AuditLog) Append(entry models.AuditEntry) error {
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if l.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", l.path, err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// List returns the entries for a resource link, optionally for one learner,
+// newest first
+func (l *AuditLog) List(key models.ResourceKey, userID string) []models.AuditEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+		if e.ResourceKey == key && (userID == "" || e.UserID == userID) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open" // one trial call is let through
+)
+
+// CircuitBreaker stops calls to a dependency after Failures failures in a
+// row, for Cooldown. Then one trial call is let through: it closes the
+// breaker when it succeeds and opens it again when it fails.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	failures int // threshold, 0 = never open
+	cooldown time.Duration
+
+	state     string
+	failed    int // failures in a row
+	openUntil time.Time
+	trial     bool // a half-open trial call is in progress
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failures: failures, cooldown: cooldown, state: BreakerClosed}
+}
+
+// Configure changes the threshold and cooldown, e.g. after a config reload
+func (b *CircuitBreaker) Configure(failures int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures, b.cooldown = failures, cooldown
+}
+
+// Allow reports whether a call may be made now. When not, it returns how
+// long until the breaker lets a trial call through.
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := time.Until(b.openUntil); wait > 0 {
+			return false, wait
+		}
+		b.state, b.trial = BreakerHalfOpen, true
+		return true, 0
+	case BreakerHalfOpen:
+		if b.trial {
+			return false, time.Second
+		}
+		b.trial = true
+	}
+	return true, 0
+}
+
+// Ready reports, without taking the trial call, whether Allow would let a
+// call through
+func (b *CircuitBreaker) Ready() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.state == BreakerOpen && time.Now().Before(b.openUntil):
+		return false, time.Until(b.openUntil)
+	case b.state == BreakerHalfOpen && b.trial:
+		return false, time.Second
+	}
+	return true, 0
+}
+
+// Record reports the outcome of a call let through by Allow
+func (b *CircuitBreaker) Record(ok bool) (state string, changed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.state
+	b.trial = false
+	switch {
+	case ok:
+		b.state, b.failed = BreakerClosed, 0
+	case b.state == BreakerHalfOpen:
+		b.state, b.openUntil = BreakerOpen, time.Now().Add(b.cooldown)
+	default:
+		b.failed++
+		if b.failures > 0 && b.failed >= b.failures {
+			b.state, b.openUntil = BreakerOpen, time.Now().Add(b.cooldown)
+		}
+	}
+	return b.state, b.state != previous
+}
+
+// Skip ends a call that says nothing about the dependency, e.g. one the
+// caller cancelled
+func (b *CircuitBreaker) Skip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// State returns the breaker's state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && !time.Now().Before(b.openUntil) {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// step is one call: allow is what Allow must answer; when the call is let
+	// through, ok is its outcome and state the state Record must report
+	type step struct {
+		allow bool
+		ok    bool
+		state string
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		cooldown time.Duration
+		steps    []step
+	}{
+		{"stays closed below the threshold", 3, time.Hour, []step{
+			{true, false, BreakerClosed},
+			{true, false, BreakerClosed},
+			{true, true, BreakerClosed},
+			{true, false, BreakerClosed},
+			{true, false, BreakerClosed},
+		}},
+		{"opens after failures in a row", 2, time.Hour, []step{
+			{true, false, BreakerClosed},
+			{true, false, BreakerOpen},
+			{allow: false},
+			{allow: false},
+		}},
+		{"never opens without a threshold", 0, time.Hour, []step{
+			{true, false, BreakerClosed},
+			{true, false, BreakerClosed},
+			{true, false, BreakerClosed},
+		}},
+		{"trial call closes it", 1, 0, []step{
+			{true, false, BreakerOpen},
+			{true, true, BreakerClosed},
+			{true, false, BreakerOpen},
+		}},
+		{"failed trial call opens it again", 2, 0, []step{
+			{true, false, BreakerClosed},
+			{true, false, BreakerOpen},
+			{true, false, BreakerOpen},
+			{true, true, BreakerClosed},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(tt.failures, tt.cooldown)
+			for i, s := range tt.steps {
+				allowed, wait := b.Allow()
+				if allowed != s.allow {
+					t.Fatalf("step %d: Allow() = %v, want %v", i, allowed, s.allow)
+				}
+				if !allowed {
+					if wait <= 0 || wait > tt.cooldown {
+						t.Errorf("step %d: wait = %s, want within the cooldown %s", i, wait, tt.cooldown)
+					}
+					continue
+				}
+				if state, _ := b.Record(s.ok); state != s.state {
+					t.Fatalf("step %d: Record(%v) = %s, want %s", i, s.ok, state, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Millisecond)
+	if state, changed := b.Record(false); state != BreakerOpen || !changed {
+		t.Fatalf("Record(false) = %s, %v; want open, changed", state, changed)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if got := b.State(); got != BreakerHalfOpen {
+		t.Errorf("State() after the cooldown = %s, want half_open", got)
+	}
+	if ok, _ := b.Ready(); !ok {
+		t.Error("Ready() after the cooldown = false")
+	}
+
+	// Only one trial call at a time
+	if ok, _ := b.Allow(); !ok {
+		t.Fatal("Allow() of the trial call = false")
+	}
+	if ok, _ := b.Allow(); ok {
+		t.Error("Allow() let a second call through during the trial")
+	}
+	if ok, _ := b.Ready(); ok {
+		t.Error("Ready() = true during the trial")
+	}
+
+	// A skipped trial lets the next call try again
+	b.Skip()
+	if ok, _ := b.Allow(); !ok {
+		t.Error("Allow() after a skipped trial = false")
+	}
+	if state, changed := b.Record(true); state != BreakerClosed || !changed {
+		t.Errorf("Record(true) = %s, %v; want closed, changed", state, changed)
+	}
+}
+
+func TestCircuitBreakerConfigure(t *testing.T) {
+	b := NewCircuitBreaker(0, time.Hour)
+	b.Record(false)
+	b.Configure(2, time.Hour)
+	if state, _ := b.Record(false); state != BreakerOpen {
+		t.Errorf("state = %s, want open once the threshold is set", state)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"go-lti-provider/models"
+)
+
+// executionRetention is how long the events of a finished execution can
+// still be replayed, for clients that connect late or reconnect
+const executionRetention = 5 * time.Minute
+
+// ExecutionEvents keeps the events of the executions in progress and
+// streams them to subscribers. Each execution's events are kept, so a
+// subscriber that connects after the submit response (or reconnects with
+// Last-Event-ID) gets the events it missed first.
+type ExecutionEvents struct {
+	mu      sync.Mutex
+	streams map[string]*executionStream // submission ID -> events
+}
+
+type executionStream struct {
+	events     []models.ExecutionEvent
+	subs       map[chan models.ExecutionEvent]struct{}
+	finishedAt time.Time // zero while running
+}
+
+// NewExecutionEvents creates a new ExecutionEvents instance
+func NewExecutionEvents() *ExecutionEvents {
+	return &ExecutionEvents{streams: make(map[string]*executionStream)}
+}
+
+// Publish adds an event to the execution id, numbering it, and sends it to
+// the subscribers. Events after the final one are dropped.
+func (e *ExecutionEvents) Publish(id string, event models.ExecutionEvent) {
+	if e == nil || id == "" {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expireLocked()
+
+	stream, ok := e.streams[id]
+	if !ok {
+		stream = &executionStream{subs: make(map[chan models.ExecutionEvent]struct{})}
+		e.streams[id] = stream
+	}
+	if !stream.finishedAt.IsZero() {
+		return
+	}
+
+	event.Seq = len(stream.events) + 1
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	stream.events = append(stream.events, event)
+
+	for ch := range stream.subs {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber quá chậm: đóng, client kết nối lại với Last-Event-ID
+			delete(stream.subs, ch)
+			close(ch)
+		}
+	}
+	if event.Final() {
+		stream.finishedAt = time.Now()
+		for ch := range stream.subs {
+			delete(stream.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the events of execution id after seq and a channel of
+// the events that follow, closed after the final one (or when the
+// subscriber falls behind). ok is false when the execution is not known:
+// not started from this instance, or finished too long ago. cancel must be
+// called when the subscriber is done.
+func (e *ExecutionEvents) Subscribe(id string, after int) (past []models.ExecutionEvent, live <-chan models.ExecutionEvent, cancel func(), ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expireLocked()
+
+	stream, ok := e.streams[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	if after < len(stream.events) {
+		past = append(past, stream.events[max(after, 0):]...)
+	}
+
+	ch := make(chan models.ExecutionEvent, 64)
+	if stream.finishedAt.IsZero() {
+		stream.subs[ch] = struct{}{}
+	} else {
+		close(ch)
+	}
+	cancel = func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if _, subscribed := stream.subs[ch]; subscribed {
+			delete(stream.subs, ch)
+			close(ch)
+		}
+	}
+	return past, ch, cancel, true
+}
+
+// expireLocked drops the executions finished more than executionRetention ago
+func (e *ExecutionEvents) expireLocked() {
+	for id, stream := range e.streams {
+		if !stream.finishedAt.IsZero() && time.Since(stream.finishedAt) > executionRetention {
+			delete(e.streams, id)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"go-lti-provider/models"
+)
+
+func eventSeqs(events []models.ExecutionEvent) []int {
+	var seqs []int
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs
+}
+
+func TestExecutionEventsReplay(t *testing.T) {
+	e := NewExecutionEvents()
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventQueued})
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventRunning})
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventTest})
+
+	tests := []struct {
+		name  string
+		after int
+		want  []int
+	}{
+		{"from the start", 0, []int{1, 2, 3}},
+		{"negative Last-Event-ID", -5, []int{1, 2, 3}},
+		{"after a reconnect", 2, []int{3}},
+		{"up to date", 3, nil},
+		{"ahead of the stream", 9, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			past, _, cancel, ok := e.Subscribe("s1", tt.after)
+			if !ok {
+				t.Fatal("Subscribe() = false")
+			}
+			defer cancel()
+			if got := eventSeqs(past); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("past = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, _, ok := e.Subscribe("unknown", 0); ok {
+		t.Error("Subscribe() of an unknown execution = true")
+	}
+}
+
+func TestExecutionEventsLive(t *testing.T) {
+	e := NewExecutionEvents()
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventQueued})
+
+	_, live, cancel, _ := e.Subscribe("s1", 1)
+	defer cancel()
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventScore})
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventDone})
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventGradeSync}) // after done: dropped
+
+	var got []models.ExecutionEvent
+	for event := range live {
+		got = append(got, event)
+	}
+	if seqs := eventSeqs(got); !reflect.DeepEqual(seqs, []int{2, 3}) {
+		t.Errorf("live = %v, want [2 3] and the channel closed after done", seqs)
+	}
+
+	// A late subscriber gets everything and a closed channel
+	past, live, cancel, ok := e.Subscribe("s1", 0)
+	if !ok {
+		t.Fatal("Subscribe() after done = false")
+	}
+	defer cancel()
+	if seqs := eventSeqs(past); !reflect.DeepEqual(seqs, []int{1, 2, 3}) {
+		t.Errorf("past = %v, want [1 2 3]", seqs)
+	}
+	if _, open := <-live; open {
+		t.Error("live channel of a finished execution is open")
+	}
+}
+
+func TestExecutionEventsSlowSubscriber(t *testing.T) {
+	e := NewExecutionEvents()
+	e.Publish("s1", models.ExecutionEvent{Type: models.EventQueued})
+	_, live, cancel, _ := e.Subscribe("s1", 1)
+	defer cancel()
+
+	// More events than the subscriber buffers: it is dropped, not waited for
+	for i := 0; i < cap(live)+1; i++ {
+		e.Publish("s1", models.ExecutionEvent{Type: models.EventTest})
+	}
+	n := 0
+	for range live {
+		n++
+	}
+	if n != cap(live) {
+		t.Errorf("received %d events, want the %d buffered before the channel closed", n, cap(live))
+	}
+
+	cancel() // after the drop: no double close
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+	"go-lti-provider/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// GradingJob is one graded attempt: the record to grade, what to grade it
+// against and where to report the result
+type GradingJob struct {
+	Record  *models.SubmissionRecord
+	Problem *models.Problem // nil = single run scored by CalculateScore
+	Policy  models.AssignmentPolicy
+
+	// Gradebook; AGS nil or an empty LineItem means nothing is reported
+	AGS      *AGSService
+	LineItem string
+	UserID   string
+
+	// Release is called once Judge0 has run the attempt (admission slot)
+	Release func()
+}
+
+// GradingOutcome is the result of a grading job once the score is known
+type GradingOutcome struct {
+	Grade float64 // score sent to the gradebook (per the policy's aggregation)
+	Err   error
+}
+
+// GradingService runs graded attempts and reports their lifecycle to the
+// platform: Submitted/Pending while Judge0 runs, then Completed/FullyGraded
+// (or PendingManual), or Submitted/Failed when the run fails. Progress
+// updates carry the grade of the earlier attempts, as a score without
+// scoreGiven clears the learner's grade on the platform.
+type GradingService struct {
+	Judge0      *Judge0Service
+	Submissions *SubmissionStore
+	Events      *ExecutionEvents // live progress of the attempts being graded
+
+	mu       sync.Mutex
+	progress map[string]string // lineitem|user -> last activityProgress reported
+
+	tasks   sync.WaitGroup // background grading and gradebook updates
+	backlog atomic.Int64   // of those, how many have not finished
+}
+
+// NewGradingService creates a new GradingService instance
+func NewGradingService(judge0 *Judge0Service, submissions *SubmissionStore) *GradingService {
+	return &GradingService{
+		Judge0:      judge0,
+		Submissions: submissions,
+		Events:      NewExecutionEvents(),
+		progress:    make(map[string]string),
+	}
+}
+
+// Submit stores the attempt as pending and grades it in the background. The
+// channel receives the outcome as soon as the score is known; the gradebook
+// is updated by the same background job, in order. The job keeps the values
+// of ctx (request ID, launch) but not its cancellation. Its progress is
+// published to Events under the record's ID.
+func (s *GradingService) Submit(ctx context.Context, job GradingJob) <-chan GradingOutcome {
+	job.Record.Status = models.SubmissionPending
+	job.Record.GradingProgress = lti.GradingPending
+	s.save(ctx, job.Record)
+
+	emit := s.emitter(job.Record.ID)
+	emit(models.ExecutionEvent{Type: models.EventQueued})
+
+	done := make(chan GradingOutcome, 1)
+	s.background(ctx, func(ctx context.Context) {
+		// Span con của request, có thể kết thúc sau khi response đã gửi
+		ctx, span := tracing.Start(ctx, "grading.job", attribute.String("submission.id", job.Record.ID))
+		defer span.End()
+
+		s.publish(ctx, job, s.currentGrade(job), lti.ActivitySubmitted, lti.GradingPending)
+
+		outcome := s.grade(ctx, job, emit)
+		if job.Release != nil {
+			job.Release()
+		}
+		if outcome.Err != nil {
+			span.RecordError(outcome.Err)
+			span.SetStatus(codes.Error, outcome.Err.Error())
+		}
+		done <- outcome
+
+		record := job.Record
+		if outcome.Err != nil {
+			emit(models.ExecutionEvent{Type: models.EventFailed, Error: outcome.Err.Error()})
+			if grade := s.currentGrade(job); grade != nil {
+				// Lần nộp lỗi không xoá điểm của các lần trước
+				s.publish(ctx, job, grade, lti.ActivityCompleted, lti.GradingFullyGraded)
+			} else {
+				s.publish(ctx, job, nil, lti.ActivitySubmitted, lti.GradingFailed)
+			}
+			emit(models.ExecutionEvent{Type: models.EventDone, Status: record.Status})
+			return
+		}
+
+		score := record.Score
+		emit(models.ExecutionEvent{Type: models.EventScore, Score: &score, MaxScore: record.MaxScore, LatePenalty: record.LatePenalty})
+		sync := models.GradeSkipped
+		if job.AGS != nil && job.LineItem != "" && job.UserID != "" {
+			sync = models.GradeSynced
+			if err := s.publish(ctx, job, &outcome.Grade, lti.ActivityCompleted, record.GradingProgress); err != nil {
+				sync = models.GradeFailed
+			}
+		}
+		emit(models.ExecutionEvent{Type: models.EventGradeSync, Grade: &outcome.Grade, Sync: sync})
+		emit(models.ExecutionEvent{Type: models.EventDone, Status: record.Status})
+	})
+	return done
+}
+
+// emitter returns the function publishing the progress of execution id
+func (s *GradingService) emitter(id string) func(models.ExecutionEvent) {
+	return func(event models.ExecutionEvent) {
+		s.Events.Publish(id, event)
+	}
+}
+
+// ReportProgress tells the platform, in the background, where a learner is
+// with the activity (Initialized, InProgress) before they have a grade.
+// Repeated reports of the same progress are skipped.
+func (s *GradingService) ReportProgress(ctx context.Context, ags *AGSService, lineItem, userID, activity string) {
+	key := lineItem + "|" + userID
+
+	s.mu.Lock()
+	if s.progress[key] == activity {
+		s.mu.Unlock()
+		return
+	}
+	s.progress[key] = activity
+	s.mu.Unlock()
+
+	s.background(ctx, func(ctx context.Context) {
+		s.publish(ctx, GradingJob{AGS: ags, LineItem: lineItem, UserID: userID}, nil, activity, lti.GradingNotReady)
+	})
+}
+
+// Backlog returns how many gradings and gradebook updates are still running
+// or waiting for the platform
+func (s *GradingService) Backlog() int {
+	return int(s.backlog.Load())
+}
+
+// background runs fn in its own goroutine, counted by Wait and Backlog. fn
+// gets ctx without its cancellation, as the request is over by then.
+func (s *GradingService) background(ctx context.Context, fn func(context.Context)) {
+	ctx = context.WithoutCancel(ctx)
+	s.tasks.Add(1)
+	s.backlog.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		defer s.backlog.Add(-1)
+		fn(ctx)
+	}()
+}
+
+// Wait blocks until background grading and gradebook updates are done, or
+// until ctx is done
+func (s *GradingService) Wait(ctx context.Context) error {
+	return waitGroup(ctx, &s.tasks)
+}
+
+// Execute runs a record's source on Judge0 and fills in its results, score
+// and status. With a problem that has test cases every test case is run and
+// the score is the weighted share of passed tests.
+func (s *GradingService) Execute(ctx context.Context, record *models.SubmissionRecord, problem *models.Problem) error {
+	return s.execute(ctx, record, problem, func(models.ExecutionEvent) {})
+}
+
+// execute is Execute telling progress as the runs start and finish
+func (s *GradingService) execute(ctx context.Context, record *models.SubmissionRecord, problem *models.Problem, progress func(models.ExecutionEvent)) error {
+	defer func() { record.CompletedAt = time.Now() }()
+
+	if problem != nil && len(problem.TestCases) > 0 {
+		record.MaxScore = problem.MaxScore
+
+		tests, err := s.Judge0.runTests(ctx, record.Source, record.LanguageID, problem.TestCases, progress)
+		record.Tests = tests
+		for _, t := range tests {
+			record.Judge0Tokens = append(record.Judge0Tokens, t.Token)
+		}
+		if err != nil {
+			record.Status = models.SubmissionFailed
+			record.Error = err.Error()
+			return err
+		}
+
+		record.Score = s.Judge0.CalculateTestScore(tests, record.MaxScore)
+		record.Status = models.SubmissionCompleted
+		return nil
+	}
+
+	progress(models.ExecutionEvent{Type: models.EventCompiling})
+	progress(models.ExecutionEvent{Type: models.EventRunning, Test: 1, Total: 1})
+	result, err := s.Judge0.SubmitCode(ctx, record.Source, record.LanguageID)
+	if err != nil {
+		record.Status = models.SubmissionFailed
+		record.Error = err.Error()
+		return err
+	}
+
+	record.Result = result
+	if result.Token != "" {
+		record.Judge0Tokens = append(record.Judge0Tokens, result.Token)
+	}
+	record.Score = s.Judge0.CalculateScore(result, record.MaxScore)
+	record.Status = models.SubmissionCompleted
+	return nil
+}
+
+func (s *GradingService) grade(ctx context.Context, job GradingJob, progress func(models.ExecutionEvent)) GradingOutcome {
+	record := job.Record
+
+	if err := s.execute(ctx, record, job.Problem, progress); err != nil {
+		record.GradingProgress = lti.GradingFailed
+		s.save(ctx, record)
+		return GradingOutcome{Err: err}
+	}
+
+	ApplyLatePenalty(job.Policy, record)
+	record.GradingProgress = lti.GradingFullyGraded
+	if job.Problem != nil && job.Problem.ManualReview {
+		record.GradingProgress = lti.GradingPendingManual
+	}
+	s.save(ctx, record)
+
+	// The gradebook gets the best, last or average attempt, per the policy
+	grade := record.Score
+	if s.Submissions != nil && record.ID != "" {
+		attempts := s.Submissions.ListForUser(record.ResourceKey, record.UserID)
+		if aggregated, ok := job.Policy.AggregateScore(attempts); ok {
+			grade = aggregated
+		}
+	}
+	return GradingOutcome{Grade: grade}
+}
+
+// currentGrade returns the grade the learner's other completed attempts give
+// per the policy's aggregation, or nil when there are none
+func (s *GradingService) currentGrade(job GradingJob) *float64 {
+	record := job.Record
+	if s.Submissions == nil || record == nil || record.ResourceLinkID == "" {
+		return nil
+	}
+
+	var attempts []models.SubmissionRecord
+	for _, attempt := range s.Submissions.ListForUser(record.ResourceKey, record.UserID) {
+		if attempt.ID != record.ID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	if grade, ok := job.Policy.AggregateScore(attempts); ok {
+		return &grade
+	}
+	return nil
+}
+
+// save stores an attempt made from a launch session. Legacy calls without a
+// session have no resource link to file the attempt under.
+func (s *GradingService) save(ctx context.Context, record *models.SubmissionRecord) {
+	if s.Submissions == nil || record.UserID == "" || record.ResourceLinkID == "" {
+		return
+	}
+	if err := s.Submissions.Save(record); err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to save submission", "submission_id", record.ID, "error", err)
+	}
+}
+
+func (s *GradingService) publish(ctx context.Context, job GradingJob, score *float64, activity, grading string) error {
+	if job.AGS == nil || job.LineItem == "" || job.UserID == "" {
+		return nil
+	}
+
+	maxScore := 0.0
+	comment := ""
+	if job.Record != nil {
+		maxScore = job.Record.MaxScore
+		if score != nil {
+			comment = fmt.Sprintf("Auto-graded at %s", time.Now().Format(time.RFC3339))
+		}
+	}
+
+	err := job.AGS.SubmitGrade(ctx, models.AGSGradeRequest{
+		LineItemURL:      job.LineItem,
+		UserID:           job.UserID,
+		Score:            score,
+		MaxScore:         maxScore,
+		Comment:          comment,
+		ActivityProgress: activity,
+		GradingProgress:  grading,
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to report progress", "learner_id", job.UserID,
+			"activity_progress", activity, "grading_progress", grading, "error", err)
+		return err
+	}
+
+	if score != nil {
+		slog.InfoContext(ctx, "✅ Grade submitted", "learner_id", job.UserID,
+			"score", *score, "max_score", maxScore, "grading_progress", grading)
+	} else {
+		slog.InfoContext(ctx, "📤 Progress reported", "learner_id", job.UserID,
+			"activity_progress", activity, "grading_progress", grading)
+	}
+	return nil
+}
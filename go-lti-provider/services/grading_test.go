@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/mockplatform"
+	"go-lti-provider/models"
+)
+
+// TestGradingKeepsGradeOnFailedAttempt grades a passing attempt, then one
+// Judge0 fails to run: the platform must still hold the first grade
+func TestGradingKeepsGradeOnFailedAttempt(t *testing.T) {
+	var runs atomic.Int32
+	judge0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if runs.Add(1) > 1 {
+			http.Error(w, "worker crashed", http.StatusInternalServerError)
+			return
+		}
+		stdout := "42\n"
+		json.NewEncoder(w).Encode(models.Judge0Response{Status: models.Status{ID: 3, Description: "Accepted"}, Stdout: &stdout})
+	}))
+	defer judge0.Close()
+
+	platform, err := mockplatform.NewServer(mockplatform.Config{ClientID: "tool-client", TokenTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer platform.Close()
+	item := platform.AddLineItem("course-1", mockplatform.LineItem{ID: "1", Label: "Answer", ScoreMaximum: 10})
+
+	store, err := NewSubmissionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	grading := NewGradingService(NewJudge0Service(judge0.URL+"/submissions"), store)
+	ags := NewAGSService(platform.TokenURL(), "tool-client", "secret", nil)
+	key := models.ResourceKey{Issuer: platform.Config().Issuer, ContextID: "course-1", ResourceLinkID: "link-1"}
+
+	submit := func() GradingOutcome {
+		record := &models.SubmissionRecord{ResourceKey: key, UserID: "learner-1", Source: "print(42)", LanguageID: 71, MaxScore: 10, CreatedAt: time.Now()}
+		job := GradingJob{Record: record, AGS: ags, LineItem: platform.LineItemURL("course-1", item.ID), UserID: "learner-1"}
+		outcome := <-grading.Submit(context.Background(), job)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := grading.Wait(ctx); err != nil {
+			t.Fatalf("grading did not finish: %v", err)
+		}
+		return outcome
+	}
+
+	if outcome := submit(); outcome.Err != nil || outcome.Grade != 10 {
+		t.Fatalf("first attempt = %+v, want grade 10", outcome)
+	}
+	graded := len(platform.Scores(item.ID))
+	if outcome := submit(); outcome.Err == nil {
+		t.Fatal("second attempt succeeded, want the Judge0 error")
+	}
+
+	scores := platform.Scores(item.ID)
+	if len(scores) <= graded {
+		t.Fatalf("no scores published for the second attempt; scores = %+v", scores)
+	}
+	for _, score := range scores[graded:] {
+		if score.ScoreGiven == nil || *score.ScoreGiven != 10 {
+			t.Errorf("second attempt published %s/%s without the first grade", score.ActivityProgress, score.GradingProgress)
+		}
+	}
+	if last := scores[len(scores)-1]; last.ActivityProgress != lti.ActivityCompleted || last.GradingProgress != lti.GradingFullyGraded {
+		t.Errorf("last score = %s/%s, want the first grade Completed/FullyGraded", last.ActivityProgress, last.GradingProgress)
+	}
+}
+
+func TestGradingReportsFailureWithoutEarlierGrade(t *testing.T) {
+	judge0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "worker crashed", http.StatusInternalServerError)
+	}))
+	defer judge0.Close()
+
+	platform, err := mockplatform.NewServer(mockplatform.Config{ClientID: "tool-client", TokenTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer platform.Close()
+	item := platform.AddLineItem("course-1", mockplatform.LineItem{ID: "1", Label: "Answer", ScoreMaximum: 10})
+
+	store, err := NewSubmissionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	grading := NewGradingService(NewJudge0Service(judge0.URL+"/submissions"), store)
+	record := &models.SubmissionRecord{
+		ResourceKey: models.ResourceKey{Issuer: platform.Config().Issuer, ContextID: "course-1", ResourceLinkID: "link-1"},
+		UserID:      "learner-1", Source: "print(42)", LanguageID: 71, MaxScore: 10, CreatedAt: time.Now(),
+	}
+	job := GradingJob{Record: record, AGS: NewAGSService(platform.TokenURL(), "tool-client", "secret", nil),
+		LineItem: platform.LineItemURL("course-1", item.ID), UserID: "learner-1"}
+	<-grading.Submit(context.Background(), job)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := grading.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	scores := platform.Scores(item.ID)
+	if len(scores) == 0 {
+		t.Fatal("no scores published")
+	}
+	if last := scores[len(scores)-1]; last.ScoreGiven != nil || last.GradingProgress != lti.GradingFailed {
+		t.Errorf("last score = %+v, want Failed without a score", last)
+	}
+}
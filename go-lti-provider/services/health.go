@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/models"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// ErrDegraded marks a check error that is a warning: the dependency works
+// but not well (long queue, big backlog)
+var ErrDegraded = errors.New("degraded")
+
+// HealthCheck checks one dependency. Details are reported even when it fails.
+type HealthCheck struct {
+	Name     string
+	Critical bool // a failure makes the instance not ready
+	Check    func(ctx context.Context) (details map[string]interface{}, err error)
+}
+
+// HealthChecker runs the dependency checks in the background, so probes
+// only read the last report and never wait on a slow dependency
+type HealthChecker struct {
+	Timeout time.Duration // per check
+
+	mu      sync.RWMutex
+	checks  []HealthCheck
+	sources []func() []HealthCheck
+	report  *models.HealthReport
+}
+
+// NewHealthChecker creates a new HealthChecker instance
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{Timeout: timeout}
+}
+
+// Add registers checks that run every time
+func (h *HealthChecker) Add(checks ...HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, checks...)
+}
+
+// AddSource registers checks that are listed again before every run, e.g.
+// one per platform while platforms are registered
+func (h *HealthChecker) AddSource(source func() []HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sources = append(h.sources, source)
+}
+
+// Report returns the last report, or nil before the first run finished
+func (h *HealthChecker) Report() *models.HealthReport {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.report
+}
+
+// Run checks now and then every interval until ctx is done
+func (h *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.CheckNow(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckNow runs every check at the same time and stores the report
+func (h *HealthChecker) CheckNow(ctx context.Context) *models.HealthReport {
+	h.mu.RLock()
+	checks := append([]HealthCheck(nil), h.checks...)
+	for _, source := range h.sources {
+		checks = append(checks, source()...)
+	}
+	h.mu.RUnlock()
+
+	report := &models.HealthReport{
+		Status:    models.HealthPass,
+		CheckedAt: time.Now(),
+		Checks:    make([]models.HealthCheckResult, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			report.Checks[i] = h.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == models.HealthPass:
+		case result.Critical && result.Status == models.HealthFail:
+			report.Status = models.HealthFail
+		case report.Status == models.HealthPass:
+			report.Status = models.HealthWarn
+		}
+	}
+
+	h.mu.Lock()
+	previous := h.report
+	h.report = report
+	h.mu.Unlock()
+	logHealthChanges(previous, report)
+	return report
+}
+
+func (h *HealthChecker) run(ctx context.Context, check HealthCheck) models.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Check(ctx)
+	result := models.HealthCheckResult{
+		Name:      check.Name,
+		Critical:  check.Critical,
+		Status:    models.HealthPass,
+		Details:   details,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		CheckedAt: start,
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrDegraded):
+		result.Status, result.Error = models.HealthWarn, err.Error()
+	default:
+		result.Status, result.Error = models.HealthFail, err.Error()
+	}
+	return result
+}
+
+// logHealthChanges logs the checks whose status changed since the last run
+func logHealthChanges(previous, report *models.HealthReport) {
+	before := make(map[string]models.HealthStatus)
+	if previous != nil {
+		for _, result := range previous.Checks {
+			before[result.Name] = result.Status
+		}
+	}
+	for _, result := range report.Checks {
+		status, seen := before[result.Name]
+		switch {
+		case seen && status == result.Status:
+		case result.Status == models.HealthPass:
+			if seen {
+				slog.Info("💚 Health check recovered", "check", result.Name)
+			}
+		case result.Critical && result.Status == models.HealthFail:
+			slog.Error("❌ Critical health check failed", "check", result.Name, "error", result.Error)
+		default:
+			slog.Warn("⚠️ Health check not passing", "check", result.Name, "status", result.Status, "error", result.Error)
+		}
+	}
+}
+
+// Judge0HealthCheck checks that Judge0 answers and has workers, and warns
+// when its queue is longer than JUDGE0_MAX_QUEUE. Critical: without Judge0
+// nothing can be run or graded.
+func Judge0HealthCheck(judge0 *Judge0Service) HealthCheck {
+	return HealthCheck{
+		Name:     "judge0",
+		Critical: true,
+		Check: func(ctx context.Context) (map[string]interface{}, error) {
+			info, err := judge0.SystemInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			workers, err := judge0.Workers(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			queued, available, failed := 0, 0, 0
+			for _, w := range workers {
+				queued += w.Size
+				available += w.Available
+				failed += w.Failed
+			}
+			details := map[string]interface{}{
+				"queue_size": queued,
+				"workers":    available,
+				"failed":     failed,
+				"circuit":    judge0Breaker.State(),
+			}
+			if cpus, ok := info["CPU(s)"]; ok {
+				details["cpus"] = cpus
+			}
+
+			if len(workers) > 0 && available == 0 {
+				return details, fmt.Errorf("no Judge0 worker available")
+			}
+			if max := config.LoadConfig().Judge0MaxQueue; max > 0 && queued > max {
+				return details, fmt.Errorf("%w: %d submissions queued on Judge0 (max %d)", ErrDegraded, queued, max)
+			}
+			return details, nil
+		},
+	}
+}
+
+// StorageHealthCheck checks that the data directories can be written,
+// creating them like the stores do on their first write. Critical: attempts
+// and registrations could not be saved.
+func StorageHealthCheck(dirs ...string) HealthCheck {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, dir := range dirs {
+		if dir = filepath.Clean(dir); !seen[dir] {
+			seen[dir] = true
+			unique = append(unique, dir)
+		}
+	}
+	sort.Strings(unique)
+
+	return HealthCheck{
+		Name:     "storage",
+		Critical: true,
+		Check: func(ctx context.Context) (map[string]interface{}, error) {
+			var errs []error
+			for _, dir := range unique {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					errs = append(errs, fmt.Errorf("%s cannot be created: %w", dir, err))
+					continue
+				}
+				f, err := os.CreateTemp(dir, ".healthcheck-*")
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s is not writable: %w", dir, err))
+					continue
+				}
+				f.Close()
+				os.Remove(f.Name())
+			}
+			return map[string]interface{}{"dirs": unique}, errors.Join(errs...)
+		},
+	}
+}
+
+// GradingBacklogHealthCheck warns when more gradings and gradebook updates
+// are waiting than GRADING_MAX_BACKLOG, e.g. while a platform is slow
+func GradingBacklogHealthCheck(grading *GradingService) HealthCheck {
+	return HealthCheck{
+		Name: "grading_backlog",
+		Check: func(ctx context.Context) (map[string]interface{}, error) {
+			backlog := grading.Backlog()
+			details := map[string]interface{}{"pending": backlog}
+			if max := config.LoadConfig().GradingMaxBacklog; max > 0 && backlog > max {
+				return details, fmt.Errorf("%w: %d gradings waiting (max %d)", ErrDegraded, backlog, max)
+			}
+			return details, nil
+		},
+	}
+}
+
+// PlatformHealthChecks checks, for every registered platform, that its JWKS
+// can be fetched (launches are verified with it) and that its token endpoint
+// answers (grades are posted with its tokens). Not critical: one platform
+// being down must not take the tool away from the others.
+func PlatformHealthChecks(registry *PlatformRegistry) func() []HealthCheck {
+	return func() []HealthCheck {
+		var checks []HealthCheck
+		seen := make(map[string]bool)
+		for _, platform := range registry.List() {
+			if platform.JWKSURL != "" && !seen[platform.JWKSURL] {
+				seen[platform.JWKSURL] = true
+				checks = append(checks, jwksHealthCheck(platform.Issuer, platform.JWKSURL))
+			}
+			if platform.TokenURL != "" && !seen[platform.TokenURL] {
+				seen[platform.TokenURL] = true
+				checks = append(checks, tokenEndpointHealthCheck(platform.Issuer, platform.TokenURL))
+			}
+		}
+		return checks
+	}
+}
+
+func jwksHealthCheck(issuer, jwksURL string) HealthCheck {
+	return HealthCheck{
+		Name: "platform_jwks:" + issuer,
+		Check: func(ctx context.Context) (map[string]interface{}, error) {
+			details := map[string]interface{}{"url": jwksURL}
+			set, err := jwk.Fetch(ctx, jwksURL)
+			if err != nil {
+				return details, fmt.Errorf("failed to fetch JWKS: %w", err)
+			}
+			details["keys"] = set.Len()
+			if set.Len() == 0 {
+				return details, fmt.Errorf("JWKS has no keys")
+			}
+			return details, nil
+		},
+	}
+}
+
+// tokenEndpointHealthCheck only checks that the endpoint answers: asking for
+// a real token would need a client assertion and show up in the platform's logs
+func tokenEndpointHealthCheck(issuer, tokenURL string) HealthCheck {
+	return HealthCheck{
+		Name: "platform_token:" + issuer,
+		Check: func(ctx context.Context) (map[string]interface{}, error) {
+			details := map[string]interface{}{"url": tokenURL}
+			req, err := http.NewRequestWithContext(ctx, "HEAD", tokenURL, nil)
+			if err != nil {
+				return details, err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return details, fmt.Errorf("token endpoint unreachable: %w", err)
+			}
+			resp.Body.Close()
+
+			// 4xx is expected for a HEAD without credentials
+			details["status"] = resp.StatusCode
+			if resp.StatusCode >= 500 {
+				return details, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+			}
+			return details, nil
+		},
+	}
+}
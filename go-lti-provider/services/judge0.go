@@ -2,19 +2,73 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-lti-provider/config"
+	"go-lti-provider/metrics"
 	"go-lti-provider/models"
+	"go-lti-provider/tracing"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	judge0URL = "http://localhost:2358/submissions"
+
+	// Judge0 status ID for a run whose output matched the expected output
+	statusAccepted = 3
+)
+
+// ErrJudge0Unavailable is returned without calling Judge0 while its circuit
+// breaker is open
+var ErrJudge0Unavailable = errors.New("Judge0 is unavailable, try again later")
+
+// Judge0Service values are created per request, so what is known about
+// Judge0's health is shared: the circuit breaker and the last queue length
+var (
+	judge0Breaker = NewCircuitBreaker(0, 0)
+	judge0Queue   atomic.Pointer[queueSample]
 )
 
+type queueSample struct {
+	size int
+	at   time.Time
+}
+
+// judge0QueueMaxAge is how old a queue length may be to be trusted; the
+// health check samples it every HEALTH_CHECK_INTERVAL
+const judge0QueueMaxAge = time.Minute
+
+// Judge0QueueSize returns the length of Judge0's queue as last seen, and
+// false when it was not seen recently
+func Judge0QueueSize() (int, bool) {
+	sample := judge0Queue.Load()
+	if sample == nil || time.Since(sample.at) > judge0QueueMaxAge {
+		return 0, false
+	}
+	return sample.size, true
+}
+
+// Judge0Ready reports whether Judge0's circuit breaker lets runs through,
+// and when not, how long until it tries again
+func Judge0Ready() (bool, time.Duration) {
+	return judge0Breaker.Ready()
+}
+
 // Judge0Service handles interaction with Judge0 API
 type Judge0Service struct {
-	BaseURL string
+	mu        sync.RWMutex
+	BaseURL   string
+	AuthToken string        // sent as X-Auth-Token when Judge0 has authentication
+	Timeout   time.Duration // per run, 0 = no timeout
 }
 
 // NewJudge0Service creates a new Judge0Service instance
@@ -25,27 +79,151 @@ func NewJudge0Service(baseURL string) *Judge0Service {
 	return &Judge0Service{BaseURL: baseURL}
 }
 
+// Judge0FromConfig creates the Judge0Service described by the configuration
+func Judge0FromConfig(cfg *config.Config) *Judge0Service {
+	s := NewJudge0Service("")
+	s.Reconfigure(cfg)
+	return s
+}
+
+// Reconfigure points the service at the configuration's Judge0, e.g. after a
+// config reload. Runs already sent finish against the old endpoint.
+func (s *Judge0Service) Reconfigure(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BaseURL = cfg.GetJudge0SubmissionURL()
+	s.AuthToken = cfg.Judge0AuthToken
+	s.Timeout = cfg.Judge0Timeout
+	judge0Breaker.Configure(cfg.Judge0BreakerFailures, cfg.Judge0BreakerCooldown)
+}
+
 // SubmitCode submits code to Judge0 for execution
-func (s *Judge0Service) SubmitCode(code string, languageID int) (*models.Judge0Response, error) {
-	submission := models.Submission{
+func (s *Judge0Service) SubmitCode(ctx context.Context, code string, languageID int) (*models.Judge0Response, error) {
+	return s.submit(ctx, models.Submission{
+		SourceCode: code,
+		LanguageID: languageID,
+	})
+}
+
+// RunWithInput runs code once with the given stdin, without checking the output
+func (s *Judge0Service) RunWithInput(ctx context.Context, code string, languageID int, stdin string) (*models.Judge0Response, error) {
+	return s.submit(ctx, models.Submission{
 		SourceCode: code,
 		LanguageID: languageID,
+		Stdin:      stdin,
+	})
+}
+
+// RunTests runs code against each test case, letting Judge0 compare the
+// output with the expected output
+func (s *Judge0Service) RunTests(ctx context.Context, code string, languageID int, tests []models.TestCase) ([]models.TestResult, error) {
+	return s.runTests(ctx, code, languageID, tests, nil)
+}
+
+// runTests is RunTests telling progress (when not nil) as each test case
+// starts and finishes
+func (s *Judge0Service) runTests(ctx context.Context, code string, languageID int, tests []models.TestCase, progress func(models.ExecutionEvent)) (results []models.TestResult, err error) {
+	if progress == nil {
+		progress = func(models.ExecutionEvent) {}
+	}
+	ctx, span := tracing.Start(ctx, "judge0.run_tests",
+		attribute.Int("judge0.language_id", languageID),
+		attribute.Int("judge0.tests", len(tests)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	results = make([]models.TestResult, 0, len(tests))
+
+	for i, tc := range tests {
+		if i == 0 {
+			progress(models.ExecutionEvent{Type: models.EventCompiling})
+		}
+		progress(models.ExecutionEvent{Type: models.EventRunning, Test: i + 1, Total: len(tests)})
+
+		result, err := s.submit(ctx, models.Submission{
+			SourceCode:     code,
+			LanguageID:     languageID,
+			Stdin:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+		})
+		if err != nil {
+			return results, fmt.Errorf("test case %s: %w", tc.ID, err)
+		}
+
+		results = append(results, models.TestResult{
+			TestCaseID:    tc.ID,
+			Name:          tc.Name,
+			Hidden:        tc.Hidden,
+			Weight:        tc.Weight,
+			Passed:        result.Status.ID == statusAccepted,
+			Status:        result.Status,
+			Stdout:        result.Stdout,
+			Stderr:        result.Stderr,
+			CompileOutput: result.CompileOutput,
+			Time:          result.Time,
+			Memory:        result.Memory,
+			Token:         result.Token,
+		})
+		progress(models.ExecutionEvent{Type: models.EventTest, Test: i + 1, Total: len(tests), Verdict: results[i].Verdict()})
 	}
 
+	return results, nil
+}
+
+// submit runs one submission and records its latency and verdict. Runs use
+// wait=true, so the span covers both the queueing and the run on Judge0.
+func (s *Judge0Service) submit(ctx context.Context, submission models.Submission) (*models.Judge0Response, error) {
+	if ok, _ := judge0Breaker.Allow(); !ok {
+		return nil, ErrJudge0Unavailable
+	}
+	metrics.ExecutionsInFlight.Inc()
+	defer metrics.ExecutionsInFlight.Dec()
+
+	ctx, span := tracing.Start(ctx, "judge0.run", attribute.Int("judge0.language_id", submission.LanguageID))
+	start := time.Now()
+	result, err := s.post(ctx, submission)
+	verdict := ""
+	if err == nil {
+		verdict = result.Status.Description
+		span.SetAttributes(attribute.String("judge0.verdict", verdict))
+	}
+	tracing.End(span, err)
+	recordJudge0Outcome(ctx, err)
+	metrics.ObserveJudge0(submission.LanguageID, start, verdict)
+	slog.DebugContext(ctx, "Judge0 run", "language_id", submission.LanguageID,
+		"verdict", verdict, "duration", time.Since(start), "error", err)
+	return result, err
+}
+
+func (s *Judge0Service) post(ctx context.Context, submission models.Submission) (*models.Judge0Response, error) {
 	jsonData, err := json.Marshal(submission)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal submission: %w", err)
 	}
 
+	s.mu.RLock()
+	baseURL, authToken, timeout := s.BaseURL, s.AuthToken, s.Timeout
+	s.mu.RUnlock()
+
 	// Submit with wait=true to get result immediately
-	resp, err := http.Post(s.BaseURL+"?wait=true", "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"?wait=true", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Judge0 request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setRequestID(req)
+	if authToken != "" {
+		req.Header.Set("X-Auth-Token", authToken)
+	}
+
+	resp, err := tracing.HTTPClient(timeout).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to POST to Judge0: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Judge0 returned status %d", resp.StatusCode)
+		return nil, judge0StatusError(resp.StatusCode)
 	}
 
 	var result models.Judge0Response
@@ -56,6 +234,61 @@ func (s *Judge0Service) SubmitCode(code string, languageID int) (*models.Judge0R
 	return &result, nil
 }
 
+// Workers returns Judge0's worker queues with their size (GET /workers)
+func (s *Judge0Service) Workers(ctx context.Context) ([]models.Judge0Worker, error) {
+	var workers []models.Judge0Worker
+	if err := s.get(ctx, "/workers", &workers); err != nil {
+		return nil, err
+	}
+
+	queued := 0
+	for _, w := range workers {
+		queued += w.Size
+	}
+	judge0Queue.Store(&queueSample{size: queued, at: time.Now()})
+	return workers, nil
+}
+
+// SystemInfo returns what Judge0 reports about its host (GET /system_info)
+func (s *Judge0Service) SystemInfo(ctx context.Context) (map[string]interface{}, error) {
+	var info map[string]interface{}
+	if err := s.get(ctx, "/system_info", &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// get reads a JSON endpoint of the Judge0 API next to /submissions
+func (s *Judge0Service) get(ctx context.Context, path string, out interface{}) error {
+	s.mu.RLock()
+	baseURL, authToken, timeout := s.BaseURL, s.AuthToken, s.Timeout
+	s.mu.RUnlock()
+
+	apiURL := strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/submissions") + path
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create Judge0 request: %w", err)
+	}
+	if authToken != "" {
+		req.Header.Set("X-Auth-Token", authToken)
+	}
+	setRequestID(req)
+
+	resp, err := tracing.HTTPClient(timeout).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to GET %s from Judge0: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Judge0 %s returned status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Judge0 %s: %w", path, err)
+	}
+	return nil
+}
+
 // GetLanguageID returns the Judge0 language ID for a given language name
 func (s *Judge0Service) GetLanguageID(language string) int {
 	// Map of supported languages to Judge0 language IDs
@@ -89,3 +322,57 @@ func (s *Judge0Service) CalculateScore(result *models.Judge0Response, maxScore f
 
 	return 0
 }
+
+// CalculateTestScore calculates the score as the weighted share of passed
+// test cases. Test cases without a weight count as 1.
+func (s *Judge0Service) CalculateTestScore(results []models.TestResult, maxScore float64) float64 {
+	var total, passed float64
+	for _, r := range results {
+		weight := r.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		if r.Passed {
+			passed += weight
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return maxScore * passed / total
+}
+
+// judge0StatusError is an error status from Judge0
+type judge0StatusError int
+
+func (e judge0StatusError) Error() string {
+	return fmt.Sprintf("Judge0 returned status %d", int(e))
+}
+
+// recordJudge0Outcome tells the circuit breaker whether Judge0 handled a
+// run. Rejected submissions (4xx) and runs the caller cancelled do not count.
+func recordJudge0Outcome(ctx context.Context, err error) {
+	var status judge0StatusError
+	switch {
+	case errors.Is(err, context.Canceled):
+		judge0Breaker.Skip()
+		return
+	case errors.As(err, &status) && status < 500 && status != http.StatusTooManyRequests:
+		err = nil
+	}
+
+	state, changed := judge0Breaker.Record(err == nil)
+	if !changed {
+		return
+	}
+	switch state {
+	case BreakerOpen:
+		metrics.Judge0CircuitOpen.Set(1)
+		slog.ErrorContext(ctx, "🔌 Judge0 circuit breaker opened, runs are refused", "error", err)
+	case BreakerClosed:
+		metrics.Judge0CircuitOpen.Set(0)
+		slog.InfoContext(ctx, "💚 Judge0 circuit breaker closed")
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ShutdownOptions control how the server drains
+type ShutdownOptions struct {
+	// DrainDelay is how long the server keeps serving after SIGTERM while
+	// readiness fails, so load balancers stop sending new traffic first
+	DrainDelay time.Duration
+	// Timeout bounds the whole drain: in-flight requests, then drain steps
+	Timeout time.Duration
+}
+
+// Lifecycle runs the HTTP server and shuts it down gracefully on SIGTERM or
+// SIGINT: readiness fails, new launches are refused, in-flight requests
+// finish, then the background work registered with OnDrain is waited for.
+type Lifecycle struct {
+	ready    atomic.Bool
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	drains []drainStep
+}
+
+type drainStep struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// NewLifecycle creates a new Lifecycle instance
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Ready reports whether the server should receive traffic
+func (l *Lifecycle) Ready() bool {
+	return l != nil && l.ready.Load() && !l.draining.Load()
+}
+
+// Draining reports whether shutdown has started
+func (l *Lifecycle) Draining() bool {
+	return l != nil && l.draining.Load()
+}
+
+// OnDrain registers background work to wait for after the server stopped
+// taking requests. Steps run in the order they were registered.
+func (l *Lifecycle) OnDrain(name string, fn func(context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.drains = append(l.drains, drainStep{name: name, fn: fn})
+}
+
+// Serve runs srv until a shutdown signal, then drains and returns. It
+// returns an error when the server fails or the drain does not finish in time.
+func (l *Lifecycle) Serve(srv *http.Server, opts ShutdownOptions) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	l.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case sig := <-signals:
+		slog.Info("🛑 Signal received, draining", "signal", sig.String(), "delay", opts.DrainDelay, "timeout", opts.Timeout)
+	}
+
+	l.draining.Store(true)
+	time.Sleep(opts.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+	slog.Info("✅ HTTP server stopped, waiting for background work")
+
+	l.mu.Lock()
+	drains := append([]drainStep(nil), l.drains...)
+	l.mu.Unlock()
+	for _, step := range drains {
+		if err := step.fn(ctx); err != nil {
+			slog.Warn("⚠️ Drain did not finish", "step", step.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		slog.Info("✅ Drained", "step", step.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// waitGroup waits for wg or until ctx is done
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const loginStateClaim = "lti_login"
+
+// LoginStateTTL is how long a learner has between the OIDC login and the launch
+const LoginStateTTL = 10 * time.Minute
+
+// LoginState is what the tool needs from the OIDC login to check the launch
+// that follows. It travels signed as the state parameter, so nothing is kept
+// on the server between the two requests.
+type LoginState struct {
+	ID            string `json:"id"`    // random; names the state cookie and the platform storage key
+	Nonce         string `json:"nonce"` // the id_token must carry it
+	Issuer        string `json:"iss"`
+	ClientID      string `json:"client_id"`
+	StorageTarget string `json:"storage_target,omitempty"` // lti_storage_target of the login, when the platform offers postMessage storage
+}
+
+// usedNonces remembers the nonces of launches already accepted until their
+// state expires, so an id_token cannot be launched twice. Một instance: with
+// several replicas a replay can still hit another one.
+var usedNonces = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+// NewLoginState creates the state of a login with a fresh ID and nonce
+func NewLoginState(issuer, clientID, storageTarget string) (*LoginState, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &LoginState{ID: id, Nonce: nonce, Issuer: issuer, ClientID: clientID, StorageTarget: storageTarget}, nil
+}
+
+// IssueLoginState signs state for the state parameter of the authentication request
+func (s *SessionService) IssueLoginState(state *LoginState) (string, error) {
+	now := time.Now()
+	token, err := jwt.NewBuilder().
+		Issuer(s.issuer).
+		Audience([]string{loginStateClaim}).
+		IssuedAt(now).
+		Expiration(now.Add(LoginStateTTL)).
+		Claim(loginStateClaim, state).
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to build login state: %w", err)
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, s.key))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign login state: %w", err)
+	}
+	return string(signed), nil
+}
+
+// VerifyLoginState checks a state parameter returned by the platform
+func (s *SessionService) VerifyLoginState(stateParam string) (*LoginState, error) {
+	token, err := jwt.Parse([]byte(stateParam),
+		jwt.WithKey(jwa.HS256, s.key),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(loginStateClaim),
+		jwt.WithTypedClaim(loginStateClaim, LoginState{}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid login state: %w", err)
+	}
+
+	value, ok := token.Get(loginStateClaim)
+	if !ok {
+		return nil, fmt.Errorf("login state has no %s claim", loginStateClaim)
+	}
+	state, ok := value.(LoginState)
+	if !ok || state.ID == "" || state.Nonce == "" {
+		return nil, fmt.Errorf("malformed %s claim", loginStateClaim)
+	}
+	return &state, nil
+}
+
+// UseNonce marks the nonce of a login state as launched. It returns false
+// when it already was.
+func UseNonce(nonce string) bool {
+	usedNonces.Lock()
+	defer usedNonces.Unlock()
+
+	now := time.Now()
+	for n, until := range usedNonces.until {
+		if now.After(until) {
+			delete(usedNonces.until, n)
+		}
+	}
+	if _, used := usedNonces.until[nonce]; used {
+		return false
+	}
+	usedNonces.until[nonce] = now.Add(LoginStateTTL)
+	return true
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate login state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
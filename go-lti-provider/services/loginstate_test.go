@@ -0,0 +1,148 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-lti-provider/models"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+func newTestSessions(t *testing.T, secret string) *SessionService {
+	t.Helper()
+	s, err := NewSessionService(secret, time.Hour, "https://tool.example.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// signLoginState signs a login state claim the way IssueLoginState does, with
+// the given expiry and audience
+func signLoginState(t *testing.T, s *SessionService, state *LoginState, exp time.Time, audience string) string {
+	t.Helper()
+	token, err := jwt.NewBuilder().
+		Issuer(s.issuer).
+		Audience([]string{audience}).
+		IssuedAt(exp.Add(-LoginStateTTL)).
+		Expiration(exp).
+		Claim(loginStateClaim, state).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, s.key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func TestVerifyLoginState(t *testing.T) {
+	s := newTestSessions(t, "state-secret")
+	login, err := NewLoginState("https://lms.example.edu", "tool", "_parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := s.IssueLoginState(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.VerifyLoginState(issued)
+	if err != nil {
+		t.Fatalf("VerifyLoginState() of an issued state = %v", err)
+	}
+	if *got != *login {
+		t.Errorf("VerifyLoginState() = %+v, want %+v", got, login)
+	}
+
+	sessionToken, err := s.Issue(&models.LaunchSession{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, err := NewSessionService("state-secret", time.Hour, "https://other.example.edu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromOtherIssuer, err := otherIssuer.IssueLoginState(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromOtherKey, err := newTestSessions(t, "other-secret").IssueLoginState(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		state string
+	}{
+		{"empty", ""},
+		{"not a JWT", "state-from-the-url"},
+		{"tampered signature", issued[:len(issued)-2] + flip(issued[len(issued)-2:])},
+		{"other key", fromOtherKey},
+		{"other issuer", fromOtherIssuer},
+		{"expired", signLoginState(t, s, login, time.Now().Add(-time.Minute), loginStateClaim)},
+		{"session token", sessionToken},
+		{"other audience", signLoginState(t, s, login, time.Now().Add(time.Minute), "lti_session")},
+		{"no nonce", signLoginState(t, s, &LoginState{ID: login.ID}, time.Now().Add(time.Minute), loginStateClaim)},
+		{"no ID", signLoginState(t, s, &LoginState{Nonce: login.Nonce}, time.Now().Add(time.Minute), loginStateClaim)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := s.VerifyLoginState(tt.state); err == nil {
+				t.Errorf("VerifyLoginState() = %+v, want an error", got)
+			}
+		})
+	}
+}
+
+// flip changes every character of s, keeping it base64url
+func flip(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'A' {
+			return 'B'
+		}
+		return 'A'
+	}, s)
+}
+
+func TestNewLoginStateIsRandom(t *testing.T) {
+	a, err := NewLoginState("https://lms.example.edu", "tool", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewLoginState("https://lms.example.edu", "tool", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == b.ID || a.Nonce == b.Nonce || a.ID == a.Nonce {
+		t.Errorf("login states share values: %+v, %+v", a, b)
+	}
+}
+
+func TestUseNonce(t *testing.T) {
+	nonce, err := randomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !UseNonce(nonce) {
+		t.Fatal("UseNonce() of a new nonce = false")
+	}
+	if UseNonce(nonce) {
+		t.Error("UseNonce() of a used nonce = true, want the replay rejected")
+	}
+
+	// An expired entry is forgotten
+	usedNonces.Lock()
+	usedNonces.until[nonce] = time.Now().Add(-time.Second)
+	usedNonces.Unlock()
+	if !UseNonce(nonce) {
+		t.Error("UseNonce() after the state expired = false")
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+// ApplyOverride sets an instructor's grade on a submission. With an explicit
+// score that score is used; otherwise the rubric points adjust the autograde.
+// The result is kept between 0 and the submission's maximum score.
+func ApplyOverride(record *models.SubmissionRecord, problem *models.Problem, score *float64,
+	rubric []models.RubricScore, comment, gradedBy string) (*models.GradeOverride, error) {
+
+	if score == nil && len(rubric) == 0 {
+		return nil, fmt.Errorf("a score or rubric is required")
+	}
+
+	criteria := make(map[string]models.RubricCriterion)
+	if problem != nil {
+		for _, c := range problem.Rubric {
+			criteria[c.ID] = c
+		}
+	}
+
+	adjustment := 0.0
+	for _, rs := range rubric {
+		criterion, ok := criteria[rs.CriterionID]
+		if !ok {
+			return nil, fmt.Errorf("unknown rubric criterion %q", rs.CriterionID)
+		}
+		if math.Abs(rs.Points) > criterion.MaxPoints {
+			return nil, fmt.Errorf("rubric criterion %q allows at most %g points", criterion.Name, criterion.MaxPoints)
+		}
+		adjustment += rs.Points
+	}
+
+	// The autograde is what the rubric adjusts, also on later overrides
+	autoScore := record.Score
+	if record.AutoScore != nil {
+		autoScore = *record.AutoScore
+	}
+
+	newScore := autoScore + adjustment
+	if score != nil {
+		newScore = *score
+	}
+	newScore = math.Max(0, math.Min(newScore, record.MaxScore))
+
+	override := &models.GradeOverride{
+		Score:         newScore,
+		PreviousScore: record.Score,
+		Comment:       comment,
+		Rubric:        rubric,
+		GradedBy:      gradedBy,
+		GradedAt:      time.Now(),
+	}
+
+	if record.AutoScore == nil {
+		record.AutoScore = &autoScore
+	}
+	record.Score = newScore
+	record.Override = override
+	record.GradingProgress = lti.GradingFullyGraded
+	return override, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+func TestApplyOverride(t *testing.T) {
+	problem := &models.Problem{Rubric: []models.RubricCriterion{
+		{ID: "style", Name: "Style", MaxPoints: 2},
+		{ID: "tests", Name: "Tests", MaxPoints: 5},
+	}}
+	score := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name          string
+		autoScore     *float64 // nil = not overridden before
+		recordScore   float64
+		score         *float64
+		rubric        []models.RubricScore
+		wantErr       bool
+		wantScore     float64
+		wantAutoScore float64
+	}{
+		{name: "explicit score", recordScore: 6, score: score(8), wantScore: 8, wantAutoScore: 6},
+		{name: "rubric points add up", recordScore: 5,
+			rubric:    []models.RubricScore{{CriterionID: "style", Points: 1.5}, {CriterionID: "tests", Points: 2}},
+			wantScore: 8.5, wantAutoScore: 5},
+		{name: "negative rubric points", recordScore: 5,
+			rubric:    []models.RubricScore{{CriterionID: "style", Points: -2}, {CriterionID: "tests", Points: 0.5}},
+			wantScore: 3.5, wantAutoScore: 5},
+		{name: "explicit score wins over the rubric", recordScore: 5, score: score(7),
+			rubric:    []models.RubricScore{{CriterionID: "tests", Points: 1}},
+			wantScore: 7, wantAutoScore: 5},
+		{name: "clamped to the maximum score", recordScore: 9,
+			rubric:    []models.RubricScore{{CriterionID: "tests", Points: 4}},
+			wantScore: 10, wantAutoScore: 9},
+		{name: "explicit score clamped to the maximum", recordScore: 5, score: score(12), wantScore: 10, wantAutoScore: 5},
+		{name: "clamped to zero", recordScore: 1,
+			rubric:    []models.RubricScore{{CriterionID: "tests", Points: -5}},
+			wantScore: 0, wantAutoScore: 1},
+		{name: "explicit score clamped to zero", recordScore: 5, score: score(-3), wantScore: 0, wantAutoScore: 5},
+		{name: "later override adjusts the autograde", autoScore: score(4), recordScore: 9,
+			rubric:    []models.RubricScore{{CriterionID: "style", Points: 1}},
+			wantScore: 5, wantAutoScore: 4},
+		{name: "later explicit score keeps the autograde", autoScore: score(4), recordScore: 9, score: score(2),
+			wantScore: 2, wantAutoScore: 4},
+		{name: "no score or rubric", recordScore: 5, wantErr: true},
+		{name: "unknown criterion", recordScore: 5,
+			rubric: []models.RubricScore{{CriterionID: "speed", Points: 1}}, wantErr: true},
+		{name: "points above the criterion", recordScore: 5,
+			rubric: []models.RubricScore{{CriterionID: "style", Points: 3}}, wantErr: true},
+		{name: "points below the criterion", recordScore: 5,
+			rubric: []models.RubricScore{{CriterionID: "style", Points: -3}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &models.SubmissionRecord{Score: tt.recordScore, MaxScore: 10, AutoScore: tt.autoScore}
+			override, err := ApplyOverride(record, problem, tt.score, tt.rubric, "looked at it", "teacher")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyOverride() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if record.Score != tt.recordScore || record.Override != nil || record.AutoScore != tt.autoScore {
+					t.Errorf("rejected override changed the record: %+v", record)
+				}
+				return
+			}
+
+			if override.Score != tt.wantScore || record.Score != tt.wantScore {
+				t.Errorf("score = %g (record %g), want %g", override.Score, record.Score, tt.wantScore)
+			}
+			if record.AutoScore == nil || *record.AutoScore != tt.wantAutoScore {
+				t.Errorf("auto score = %v, want %g", record.AutoScore, tt.wantAutoScore)
+			}
+			if override.PreviousScore != tt.recordScore {
+				t.Errorf("previous score = %g, want %g", override.PreviousScore, tt.recordScore)
+			}
+			if record.Override != override || override.GradedBy != "teacher" || override.Comment != "looked at it" {
+				t.Errorf("override = %+v", override)
+			}
+			if record.GradingProgress != lti.GradingFullyGraded {
+				t.Errorf("grading progress = %q, want %q", record.GradingProgress, lti.GradingFullyGraded)
+			}
+		})
+	}
+}
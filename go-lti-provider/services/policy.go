@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+// Custom launch parameters that override the problem's assignment policy.
+// Moodle can fill due_at with the $ResourceLink.submission.endDateTime substitution.
+const (
+	customMaxAttempts        = "max_attempts"
+	customCooldown           = "cooldown"
+	customDueAt              = "due_at"
+	customCutoffAt           = "cutoff_at"
+	customLatePenaltyPercent = "late_penalty_percent"
+	customLatePenaltyPer     = "late_penalty_per"
+	customLatePenaltyMax     = "late_penalty_max"
+	customGradeAggregation   = "grade_aggregation"
+)
+
+// PolicyViolation is returned when an attempt is not allowed by the policy
+type PolicyViolation struct {
+	Reason     string
+	RetryAfter time.Duration // set for cooldowns
+}
+
+func (v *PolicyViolation) Error() string {
+	return v.Reason
+}
+
+// ResolvePolicy combines the problem's policy with the line item's
+// endDateTime and the launch custom parameters, in increasing precedence
+func ResolvePolicy(base models.AssignmentPolicy, lineItem *lti.LineItem, custom map[string]string) models.AssignmentPolicy {
+	policy := base
+
+	if lineItem != nil && lineItem.EndDateTime != "" {
+		if dueAt, err := time.Parse(time.RFC3339, lineItem.EndDateTime); err == nil {
+			policy.DueAt = &dueAt
+		}
+	}
+
+	for name, value := range custom {
+		value = strings.TrimSpace(value)
+		// Unresolved Moodle substitutions are sent as-is
+		if value == "" || strings.HasPrefix(value, "$") {
+			continue
+		}
+
+		// Invalid values keep the setting from the problem or line item
+		resolved := policy
+		var err error
+		switch name {
+		case customMaxAttempts:
+			resolved.MaxAttempts, err = strconv.Atoi(value)
+		case customCooldown:
+			resolved.CooldownSeconds, err = parseSeconds(value)
+		case customDueAt:
+			resolved.DueAt, err = parseTime(value)
+		case customCutoffAt:
+			resolved.CutoffAt, err = parseTime(value)
+		case customLatePenaltyPercent:
+			resolved.LatePenalty.Percent, err = strconv.ParseFloat(value, 64)
+		case customLatePenaltyPer:
+			resolved.LatePenalty.Per = models.PenaltyUnit(value)
+			if !resolved.LatePenalty.Per.Valid() {
+				err = fmt.Errorf("unknown late penalty unit")
+			}
+		case customLatePenaltyMax:
+			resolved.LatePenalty.MaxPercent, err = strconv.ParseFloat(value, 64)
+		case customGradeAggregation:
+			resolved.Aggregation = models.GradeAggregation(value)
+			if !resolved.Aggregation.Valid() {
+				err = fmt.Errorf("unknown grade aggregation")
+			}
+		}
+		if err != nil {
+			slog.Warn("⚠️ Ignoring invalid custom parameter", "name", name, "value", value, "error", err)
+			continue
+		}
+		policy = resolved
+	}
+
+	return policy
+}
+
+// LineItemPolicy resolves the policy outside of a launch: the problem's
+// policy and the due date of the line item, without custom parameters
+func LineItemPolicy(ctx context.Context, ags *AGSService, problem *models.Problem, lineItemURL string) models.AssignmentPolicy {
+	var base models.AssignmentPolicy
+	if problem != nil {
+		base = problem.Policy
+	}
+
+	var lineItem *lti.LineItem
+	if ags != nil && lineItemURL != "" {
+		item, err := ags.GetLineItem(ctx, lineItemURL)
+		if err != nil {
+			slog.WarnContext(ctx, "⚠️ Could not read line item", "lineitem", lineItemURL, "error", err)
+		} else {
+			lineItem = item
+		}
+	}
+	return ResolvePolicy(base, lineItem, nil)
+}
+
+// CheckAttempt reports whether a new graded attempt may be made at now,
+// given the learner's previous attempts (newest first)
+func CheckAttempt(policy models.AssignmentPolicy, attempts []models.SubmissionRecord, now time.Time) error {
+	if policy.CutoffAt != nil && now.After(*policy.CutoffAt) {
+		return &PolicyViolation{Reason: "The submission deadline has passed"}
+	}
+
+	if policy.MaxAttempts > 0 {
+		if used := CountAttempts(attempts); used >= policy.MaxAttempts {
+			return &PolicyViolation{Reason: fmt.Sprintf("Maximum of %d attempts reached", policy.MaxAttempts)}
+		}
+	}
+
+	if cooldown := policy.Cooldown(); cooldown > 0 && len(attempts) > 0 {
+		if wait := attempts[0].CreatedAt.Add(cooldown).Sub(now); wait > 0 {
+			return &PolicyViolation{
+				Reason:     fmt.Sprintf("Please wait %s before the next attempt", wait.Round(time.Second)),
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	return nil
+}
+
+// CountAttempts counts the attempts that use up the attempt limit: graded
+// ones and ones still being graded. Attempts that failed to run are free.
+func CountAttempts(attempts []models.SubmissionRecord) int {
+	used := 0
+	for _, a := range attempts {
+		if a.Status != models.SubmissionFailed {
+			used++
+		}
+	}
+	return used
+}
+
+// ApplyLatePenalty records the late penalty of an attempt and reduces its score
+func ApplyLatePenalty(policy models.AssignmentPolicy, record *models.SubmissionRecord) {
+	record.RawScore = record.Score
+	record.LatePenalty = policy.PenaltyAt(record.CreatedAt)
+	record.Score = record.RawScore * (1 - record.LatePenalty/100)
+}
+
+// parseSeconds accepts a Go duration ("90s", "5m") or a number of seconds
+func parseSeconds(value string) (int, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return int(d / time.Second), nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseTime accepts RFC 3339 or a Unix timestamp (what Moodle substitutes)
+func parseTime(value string) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC 3339 time or unix timestamp")
+	}
+	t := time.Unix(unix, 0)
+	return &t, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+func TestCheckAttempt(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	attempt := func(status models.SubmissionStatus, ago time.Duration) models.SubmissionRecord {
+		return models.SubmissionRecord{Status: status, CreatedAt: now.Add(-ago)}
+	}
+	twoDone := []models.SubmissionRecord{
+		attempt(models.SubmissionCompleted, time.Hour),
+		attempt(models.SubmissionCompleted, 2*time.Hour),
+	}
+
+	tests := []struct {
+		name       string
+		policy     models.AssignmentPolicy
+		attempts   []models.SubmissionRecord
+		wantErr    bool
+		retryAfter time.Duration
+	}{
+		{"no policy", models.AssignmentPolicy{}, twoDone, false, 0},
+		{"below max attempts", models.AssignmentPolicy{MaxAttempts: 3}, twoDone, false, 0},
+		{"max attempts reached", models.AssignmentPolicy{MaxAttempts: 2}, twoDone, true, 0},
+		{"pending attempts count", models.AssignmentPolicy{MaxAttempts: 2},
+			[]models.SubmissionRecord{attempt(models.SubmissionPending, time.Minute), attempt(models.SubmissionCompleted, time.Hour)}, true, 0},
+		{"failed attempts are free", models.AssignmentPolicy{MaxAttempts: 2},
+			[]models.SubmissionRecord{attempt(models.SubmissionFailed, time.Minute), attempt(models.SubmissionCompleted, time.Hour)}, false, 0},
+		{"cooldown running", models.AssignmentPolicy{CooldownSeconds: 300},
+			[]models.SubmissionRecord{attempt(models.SubmissionCompleted, time.Minute)}, true, 4 * time.Minute},
+		{"cooldown over", models.AssignmentPolicy{CooldownSeconds: 300},
+			[]models.SubmissionRecord{attempt(models.SubmissionCompleted, 10*time.Minute)}, false, 0},
+		{"cooldown without attempts", models.AssignmentPolicy{CooldownSeconds: 300}, nil, false, 0},
+		{"before cutoff", models.AssignmentPolicy{CutoffAt: &future}, nil, false, 0},
+		{"after cutoff", models.AssignmentPolicy{CutoffAt: &past}, nil, true, 0},
+		{"after due date but before cutoff", models.AssignmentPolicy{DueAt: &past, CutoffAt: &future}, nil, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckAttempt(tt.policy, tt.attempts, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckAttempt() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			var violation *PolicyViolation
+			if !errors.As(err, &violation) {
+				t.Fatalf("CheckAttempt() error = %T, want *PolicyViolation", err)
+			}
+			if violation.RetryAfter != tt.retryAfter {
+				t.Errorf("RetryAfter = %s, want %s", violation.RetryAfter, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestApplyLatePenalty(t *testing.T) {
+	due := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		penalty     models.LatePenalty
+		late        time.Duration
+		wantPenalty float64
+		wantScore   float64
+	}{
+		{"on time", models.LatePenalty{Percent: 10}, -time.Minute, 0, 80},
+		{"exactly at due date", models.LatePenalty{Percent: 10}, 0, 0, 80},
+		{"first started hour", models.LatePenalty{Percent: 10}, time.Minute, 10, 72},
+		{"three started hours", models.LatePenalty{Percent: 10}, 2*time.Hour + time.Second, 30, 56},
+		{"per day", models.LatePenalty{Percent: 20, Per: models.PenaltyPerDay}, 25 * time.Hour, 40, 48},
+		{"capped", models.LatePenalty{Percent: 10, MaxPercent: 25}, 10 * time.Hour, 25, 60},
+		{"never above 100%", models.LatePenalty{Percent: 50}, 5 * time.Hour, 100, 0},
+		{"no penalty configured", models.LatePenalty{}, 5 * time.Hour, 0, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := models.AssignmentPolicy{DueAt: &due, LatePenalty: tt.penalty}
+			record := &models.SubmissionRecord{Score: 80, CreatedAt: due.Add(tt.late)}
+
+			ApplyLatePenalty(policy, record)
+
+			if record.RawScore != 80 {
+				t.Errorf("RawScore = %g, want 80", record.RawScore)
+			}
+			if record.LatePenalty != tt.wantPenalty {
+				t.Errorf("LatePenalty = %g, want %g", record.LatePenalty, tt.wantPenalty)
+			}
+			if math.Abs(record.Score-tt.wantScore) > 1e-9 {
+				t.Errorf("Score = %g, want %g", record.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestResolvePolicy(t *testing.T) {
+	problemDue := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	base := models.AssignmentPolicy{MaxAttempts: 3, CooldownSeconds: 60, DueAt: &problemDue, Aggregation: models.GradeBest}
+
+	t.Run("problem policy alone", func(t *testing.T) {
+		got := ResolvePolicy(base, nil, nil)
+		if got.MaxAttempts != 3 || got.CooldownSeconds != 60 || !got.DueAt.Equal(problemDue) {
+			t.Errorf("ResolvePolicy() = %+v", got)
+		}
+	})
+
+	t.Run("line item end date overrides the problem", func(t *testing.T) {
+		got := ResolvePolicy(base, &lti.LineItem{EndDateTime: "2026-03-05T10:00:00Z"}, nil)
+		if want := time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC); !got.DueAt.Equal(want) {
+			t.Errorf("DueAt = %s, want %s", got.DueAt, want)
+		}
+	})
+
+	t.Run("custom parameters override both", func(t *testing.T) {
+		got := ResolvePolicy(base, &lti.LineItem{EndDateTime: "2026-03-05T10:00:00Z"}, map[string]string{
+			"max_attempts":         "5",
+			"cooldown":             "2m",
+			"due_at":               "1773000000",
+			"cutoff_at":            "2026-03-10T00:00:00Z",
+			"late_penalty_percent": "10",
+			"late_penalty_per":     "day",
+			"late_penalty_max":     "50",
+			"grade_aggregation":    "last",
+		})
+		if got.MaxAttempts != 5 || got.CooldownSeconds != 120 {
+			t.Errorf("MaxAttempts, CooldownSeconds = %d, %d; want 5, 120", got.MaxAttempts, got.CooldownSeconds)
+		}
+		if want := time.Unix(1773000000, 0); !got.DueAt.Equal(want) {
+			t.Errorf("DueAt = %s, want %s", got.DueAt, want)
+		}
+		if got.CutoffAt == nil || !got.CutoffAt.Equal(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("CutoffAt = %v", got.CutoffAt)
+		}
+		want := models.LatePenalty{Percent: 10, Per: models.PenaltyPerDay, MaxPercent: 50}
+		if got.LatePenalty != want || got.Aggregation != models.GradeLast {
+			t.Errorf("LatePenalty, Aggregation = %+v, %q", got.LatePenalty, got.Aggregation)
+		}
+	})
+
+	t.Run("unresolved substitutions and invalid values are ignored", func(t *testing.T) {
+		got := ResolvePolicy(base, &lti.LineItem{EndDateTime: "not a date"}, map[string]string{
+			"max_attempts":      "many",
+			"cutoff_at":         "tomorrow",
+			"cooldown":          "",
+			"due_at":            "$ResourceLink.submission.endDateTime",
+			"late_penalty_per":  "week",
+			"grade_aggregation": "worst",
+		})
+		if got.MaxAttempts != 3 || got.CooldownSeconds != 60 || !got.DueAt.Equal(problemDue) {
+			t.Errorf("ResolvePolicy() = %+v, want the problem's policy", got)
+		}
+		if got.LatePenalty.Per != "" || got.Aggregation != models.GradeBest {
+			t.Errorf("LatePenalty.Per, Aggregation = %q, %q; want the problem's", got.LatePenalty.Per, got.Aggregation)
+		}
+		if got.CutoffAt != nil {
+			t.Errorf("CutoffAt = %v, want none", got.CutoffAt)
+		}
+	})
+
+	t.Run("base is not modified", func(t *testing.T) {
+		ResolvePolicy(base, nil, map[string]string{"max_attempts": "9"})
+		if base.MaxAttempts != 3 {
+			t.Errorf("base.MaxAttempts = %d, want 3", base.MaxAttempts)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-lti-provider/models"
+)
+
+// ProblemStore keeps the problem configured for each resource link, persisted
+// to a JSON file. Problems from problem directories are used for resource
+// links an instructor has not configured.
+type ProblemStore struct {
+	mu       sync.RWMutex
+	path     string
+	problems map[string]models.Problem // ResourceKey.String() -> problem
+	shipped  map[string]models.Problem // from problem directories, read-only
+}
+
+// NewProblemStore loads the problems saved at path (if any) and the *.json
+// problem files in dirs
+func NewProblemStore(path string, dirs ...string) (*ProblemStore, error) {
+	s := &ProblemStore{
+		path:     path,
+		problems: make(map[string]models.Problem),
+		shipped:  make(map[string]models.Problem),
+	}
+
+	if path != "" {
+		if err := readJSONFile(path, &s.problems); err != nil {
+			return nil, fmt.Errorf("failed to load problems: %w", err)
+		}
+	}
+
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list problems in %s: %w", dir, err)
+		}
+		for _, file := range files {
+			var pf models.ProblemFile
+			if err := readJSONFile(file, &pf); err != nil {
+				return nil, fmt.Errorf("failed to load problem %s: %w", file, err)
+			}
+			if pf.Issuer == "" || pf.ContextID == "" || pf.ResourceLinkID == "" {
+				return nil, fmt.Errorf("problem %s: issuer, context_id and resource_link_id are required", file)
+			}
+			normalizeProblem(&pf.Problem)
+			s.shipped[pf.ResourceKey.String()] = pf.Problem
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the problem configured for a resource link
+func (s *ProblemStore) Get(key models.ResourceKey) (*models.Problem, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	problem, ok := s.problems[key.String()]
+	if !ok {
+		problem, ok = s.shipped[key.String()]
+	}
+	if !ok {
+		return nil, false
+	}
+	return &problem, true
+}
+
+// Save stores the problem for a resource link and persists the store
+func (s *ProblemStore) Save(key models.ResourceKey, problem models.Problem, updatedBy string) (*models.Problem, error) {
+	normalizeProblem(&problem)
+	problem.UpdatedAt = time.Now()
+	problem.UpdatedBy = updatedBy
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.problems[key.String()] = problem
+	if s.path != "" {
+		if err := writeJSONFile(s.path, s.problems); err != nil {
+			return nil, err
+		}
+	}
+	return &problem, nil
+}
+
+// normalizeProblem fills in the default maximum score and test case IDs
+func normalizeProblem(problem *models.Problem) {
+	if problem.MaxScore <= 0 {
+		problem.MaxScore = 100
+	}
+	for i := range problem.TestCases {
+		if problem.TestCases[i].ID == "" {
+			problem.TestCases[i].ID = fmt.Sprintf("t%d", i+1)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a per-key token bucket: each key may make Limit requests
+// per Window, refilled continuously
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per window and
+// key. A limit of 0 or less disables limiting.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Window: window, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token for key. When none is left it returns false and how
+// long until the next token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.Limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	rate := float64(l.Limit) / float64(l.Window) // tokens per nanosecond
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Limit), updated: now}
+		l.buckets[key] = b
+		l.pruneLocked(now)
+	} else {
+		b.tokens += float64(now.Sub(b.updated)) * rate
+		if b.tokens > float64(l.Limit) {
+			b.tokens = float64(l.Limit)
+		}
+		b.updated = now
+	}
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// pruneLocked drops buckets that have been full for a while, so the map
+// does not grow with every user ever seen
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	if len(l.buckets) < 1024 {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > l.Window {
+			delete(l.buckets, key)
+		}
+	}
+}
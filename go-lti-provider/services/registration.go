@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-lti-provider/models"
+)
+
+const toolConfigurationClaim = "https://purl.imsglobal.org/spec/lti-tool-configuration"
+
+// PlatformConfiguration is the OpenID configuration a platform publishes for
+// LTI Dynamic Registration
+type PlatformConfiguration struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	RegistrationEndpoint  string   `json:"registration_endpoint"`
+	ScopesSupported       []string `json:"scopes_supported"`
+	LTIPlatform           struct {
+		ProductFamilyCode string `json:"product_family_code"`
+		Version           string `json:"version"`
+		MessagesSupported []struct {
+			Type string `json:"type"`
+		} `json:"messages_supported"`
+	} `json:"https://purl.imsglobal.org/spec/lti-platform-configuration"`
+}
+
+// ToolRegistration is the client registration request sent to the platform
+type ToolRegistration struct {
+	ApplicationType         string            `json:"application_type"`
+	ResponseTypes           []string          `json:"response_types"`
+	GrantTypes              []string          `json:"grant_types"`
+	InitiateLoginURI        string            `json:"initiate_login_uri"`
+	RedirectURIs            []string          `json:"redirect_uris"`
+	ClientName              string            `json:"client_name"`
+	JWKSURI                 string            `json:"jwks_uri"`
+	TokenEndpointAuthMethod string            `json:"token_endpoint_auth_method"`
+	Scope                   string            `json:"scope"`
+	ToolConfiguration       ToolConfiguration `json:"https://purl.imsglobal.org/spec/lti-tool-configuration"`
+}
+
+// ToolConfiguration is the LTI specific part of a tool registration
+type ToolConfiguration struct {
+	Domain           string            `json:"domain"`
+	TargetLinkURI    string            `json:"target_link_uri"`
+	CustomParameters map[string]string `json:"custom_parameters,omitempty"`
+	Claims           []string          `json:"claims"`
+	Messages         []ToolMessage     `json:"messages"`
+	DeploymentID     string            `json:"deployment_id,omitempty"`
+}
+
+// ToolMessage declares an LTI message type the tool supports
+type ToolMessage struct {
+	Type          string   `json:"type"`
+	TargetLinkURI string   `json:"target_link_uri,omitempty"`
+	Label         string   `json:"label,omitempty"`
+	Placements    []string `json:"placements,omitempty"`
+}
+
+// ToolInfo describes the tool's own endpoints for registration
+type ToolInfo struct {
+	Name      string
+	LoginURL  string
+	LaunchURL string
+	JWKSURL   string
+	Scopes    []string
+	Messages  []ToolMessage
+}
+
+// RegistrationService performs the IMS LTI Dynamic Registration flow
+type RegistrationService struct {
+	Tool   ToolInfo
+	client *http.Client
+}
+
+// NewRegistrationService creates a new RegistrationService instance
+func NewRegistrationService(tool ToolInfo) *RegistrationService {
+	return &RegistrationService{
+		Tool:   tool,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Register fetches the platform configuration, registers the tool with the
+// platform and returns the resulting platform registration
+func (s *RegistrationService) Register(openIDConfigURL, registrationToken string) (*models.PlatformRegistration, error) {
+	platformCfg, err := s.FetchPlatformConfiguration(openIDConfigURL)
+	if err != nil {
+		return nil, err
+	}
+
+	registration := s.buildToolRegistration(platformCfg)
+
+	jsonData, err := json.Marshal(registration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tool registration: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", platformCfg.RegistrationEndpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registration request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if registrationToken != "" {
+		req.Header.Set("Authorization", "Bearer "+registrationToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register tool: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("tool registration failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var registered struct {
+		ClientID          string            `json:"client_id"`
+		ToolConfiguration ToolConfiguration `json:"https://purl.imsglobal.org/spec/lti-tool-configuration"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return nil, fmt.Errorf("failed to decode registration response: %w", err)
+	}
+	if registered.ClientID == "" {
+		return nil, fmt.Errorf("registration response has no client_id")
+	}
+
+	var deployments []string
+	if registered.ToolConfiguration.DeploymentID != "" {
+		deployments = []string{registered.ToolConfiguration.DeploymentID}
+	}
+
+	return &models.PlatformRegistration{
+		Issuer:        platformCfg.Issuer,
+		ClientID:      registered.ClientID,
+		DeploymentIDs: deployments,
+		AuthLoginURL:  platformCfg.AuthorizationEndpoint,
+		TokenURL:      platformCfg.TokenEndpoint,
+		JWKSURL:       platformCfg.JWKSURI,
+		ProductFamily: platformCfg.LTIPlatform.ProductFamilyCode,
+		RegisteredAt:  time.Now(),
+	}, nil
+}
+
+// FetchPlatformConfiguration downloads and validates the platform's OpenID configuration
+func (s *RegistrationService) FetchPlatformConfiguration(openIDConfigURL string) (*PlatformConfiguration, error) {
+	u, err := url.Parse(openIDConfigURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid openid_configuration URL")
+	}
+
+	resp, err := s.client.Get(openIDConfigURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch platform configuration: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("platform configuration request failed with status %d", resp.StatusCode)
+	}
+
+	var platformCfg PlatformConfiguration
+	if err := json.NewDecoder(resp.Body).Decode(&platformCfg); err != nil {
+		return nil, fmt.Errorf("failed to decode platform configuration: %w", err)
+	}
+
+	// The configuration URL must live under the issuer it declares
+	if !issuerServes(platformCfg.Issuer, u) {
+		return nil, fmt.Errorf("issuer %q does not match openid_configuration URL", platformCfg.Issuer)
+	}
+	if platformCfg.RegistrationEndpoint == "" || platformCfg.AuthorizationEndpoint == "" ||
+		platformCfg.TokenEndpoint == "" || platformCfg.JWKSURI == "" {
+		return nil, fmt.Errorf("platform configuration is missing required endpoints")
+	}
+
+	return &platformCfg, nil
+}
+
+// issuerServes reports whether configURL is on the issuer's scheme and host
+// and under its path: https://lms.example.com serves
+// https://lms.example.com/mod/lti/openid-configuration.php, but not
+// https://lms.example.com.evil.net/... nor https://lms.example.com/other
+// for the issuer https://lms.example.com/moodle
+func issuerServes(issuer string, configURL *url.URL) bool {
+	iss, err := url.Parse(issuer)
+	if err != nil || iss.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(iss.Scheme, configURL.Scheme) || !strings.EqualFold(iss.Host, configURL.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(iss.EscapedPath(), "/")
+	path := configURL.EscapedPath()
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func (s *RegistrationService) buildToolRegistration(platformCfg *PlatformConfiguration) ToolRegistration {
+	domain := ""
+	if u, err := url.Parse(s.Tool.LaunchURL); err == nil {
+		domain = u.Host
+	}
+
+	return ToolRegistration{
+		ApplicationType:         "web",
+		ResponseTypes:           []string{"id_token"},
+		GrantTypes:              []string{"implicit", "client_credentials"},
+		InitiateLoginURI:        s.Tool.LoginURL,
+		RedirectURIs:            []string{s.Tool.LaunchURL},
+		ClientName:              s.Tool.Name,
+		JWKSURI:                 s.Tool.JWKSURL,
+		TokenEndpointAuthMethod: "private_key_jwt",
+		Scope:                   strings.Join(supportedScopes(s.Tool.Scopes, platformCfg.ScopesSupported), " "),
+		ToolConfiguration: ToolConfiguration{
+			Domain:        domain,
+			TargetLinkURI: s.Tool.LaunchURL,
+			Claims:        []string{"iss", "sub", "name", "given_name", "family_name", "email"},
+			Messages:      s.Tool.Messages,
+		},
+	}
+}
+
+// supportedScopes keeps the requested scopes the platform advertises. If the
+// platform does not advertise any, all requested scopes are kept.
+func supportedScopes(requested, supported []string) []string {
+	if len(supported) == 0 {
+		return requested
+	}
+
+	var scopes []string
+	for _, scope := range requested {
+		for _, s := range supported {
+			if scope == s {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	return scopes
+}
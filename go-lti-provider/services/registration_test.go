@@ -0,0 +1,39 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestIssuerServes(t *testing.T) {
+	tests := []struct {
+		issuer    string
+		configURL string
+		want      bool
+	}{
+		{"https://lms.example.com", "https://lms.example.com/mod/lti/openid-configuration.php", true},
+		{"https://lms.example.com/", "https://lms.example.com/.well-known/openid-configuration", true},
+		{"https://LMS.example.com", "https://lms.example.com/openid", true},
+		{"https://lms.example.com/moodle", "https://lms.example.com/moodle/mod/lti/openid-configuration.php", true},
+		{"https://lms.example.com/moodle", "https://lms.example.com/moodle", true},
+		{"https://lms.example.com/moodle", "https://lms.example.com/moodle2/openid", false},
+		{"https://lms.example.com/moodle", "https://lms.example.com/other/openid", false},
+		{"https://lms.example.com", "https://lms.example.com.evil.net/openid", false},
+		{"https://lms.example.com", "https://evil.net/lms.example.com/openid", false},
+		{"https://lms.example.com", "https://evil-lms.example.com/openid", false},
+		{"https://lms.example.com", "http://lms.example.com/openid", false},
+		{"https://lms.example.com", "https://lms.example.com:8443/openid", false},
+		{"lms.example.com", "https://lms.example.com/openid", false},
+		{"", "https://lms.example.com/openid", false},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.configURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := issuerServes(tt.issuer, u); got != tt.want {
+			t.Errorf("issuerServes(%q, %q) = %v, want %v", tt.issuer, tt.configURL, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/models"
+)
+
+// PlatformRegistry keeps the LTI platforms the tool trusts. Platforms added
+// through dynamic registration are persisted to a JSON file; platforms from
+// the environment configuration are kept in memory only.
+type PlatformRegistry struct {
+	mu      sync.RWMutex
+	path    string
+	static  []models.PlatformRegistration
+	dynamic []models.PlatformRegistration
+}
+
+// NewPlatformRegistry loads persisted registrations from path (if any) and
+// adds the given static registrations
+func NewPlatformRegistry(path string, static ...models.PlatformRegistration) (*PlatformRegistry, error) {
+	r := &PlatformRegistry{path: path, static: static}
+
+	if path != "" {
+		if err := readJSONFile(path, &r.dynamic); err != nil {
+			return nil, fmt.Errorf("failed to load platform registry: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// PlatformFromConfig builds the registration described by the environment
+// configuration. ok is false when no client ID is configured.
+func PlatformFromConfig(cfg *config.Config) (reg models.PlatformRegistration, ok bool) {
+	if cfg.ClientID == "" {
+		return models.PlatformRegistration{}, false
+	}
+
+	var deployments []string
+	if cfg.DeploymentID != "" {
+		deployments = []string{cfg.DeploymentID}
+	}
+
+	return models.PlatformRegistration{
+		Issuer:        cfg.PlatformIssuer,
+		ClientID:      cfg.ClientID,
+		DeploymentIDs: deployments,
+		AuthLoginURL:  cfg.AuthLoginURL,
+		TokenURL:      cfg.TokenEndpoint,
+		JWKSURL:       cfg.KeysetEndpoint,
+	}, true
+}
+
+// PlatformsFromConfig builds the registrations of the configuration: the
+// one from the environment settings (if any) and the ones in the config file
+func PlatformsFromConfig(cfg *config.Config) []models.PlatformRegistration {
+	var regs []models.PlatformRegistration
+	if reg, ok := PlatformFromConfig(cfg); ok {
+		regs = append(regs, reg)
+	}
+	for _, p := range cfg.Platforms {
+		regs = append(regs, models.PlatformRegistration{
+			Issuer:        p.Issuer,
+			ClientID:      p.ClientID,
+			DeploymentIDs: p.DeploymentIDs,
+			AuthLoginURL:  p.AuthLoginURL,
+			TokenURL:      p.TokenURL,
+			JWKSURL:       p.JWKSURL,
+			ProductFamily: p.ProductFamily,
+		})
+	}
+	return regs
+}
+
+// SetStatic replaces the registrations that come from the configuration,
+// e.g. after a config reload. Dynamic registrations are kept.
+func (r *PlatformRegistry) SetStatic(static []models.PlatformRegistration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.static = static
+}
+
+// Find returns the registration for an issuer and client ID. An empty client
+// ID matches the first registration of the issuer; an empty issuer matches
+// only when exactly one platform is registered.
+func (r *PlatformRegistry) Find(issuer, clientID string) (*models.PlatformRegistration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := r.allLocked()
+	if issuer == "" {
+		if len(all) == 1 {
+			reg := all[0]
+			return &reg, true
+		}
+		return nil, false
+	}
+
+	for _, reg := range all {
+		if reg.Issuer == issuer && (clientID == "" || reg.ClientID == clientID) {
+			reg := reg
+			return &reg, true
+		}
+	}
+	return nil, false
+}
+
+// List returns all registrations, dynamic ones first
+func (r *PlatformRegistry) List() []models.PlatformRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.allLocked()
+}
+
+// Register adds or updates a dynamically registered platform and persists the
+// registry. Deployment IDs are merged with any already known for the client.
+func (r *PlatformRegistry) Register(reg models.PlatformRegistration) error {
+	if reg.Issuer == "" || reg.ClientID == "" {
+		return fmt.Errorf("issuer and client_id are required")
+	}
+	if reg.RegisteredAt.IsZero() {
+		reg.RegisteredAt = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	updated := false
+	for i, existing := range r.dynamic {
+		if existing.Issuer == reg.Issuer && existing.ClientID == reg.ClientID {
+			reg.DeploymentIDs = mergeDeployments(existing.DeploymentIDs, reg.DeploymentIDs)
+			r.dynamic[i] = reg
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		r.dynamic = append(r.dynamic, reg)
+	}
+
+	if r.path == "" {
+		return nil
+	}
+	return writeJSONFile(r.path, r.dynamic)
+}
+
+func (r *PlatformRegistry) allLocked() []models.PlatformRegistration {
+	all := make([]models.PlatformRegistration, 0, len(r.dynamic)+len(r.static))
+	all = append(all, r.dynamic...)
+	all = append(all, r.static...)
+	return all
+}
+
+func mergeDeployments(existing, added []string) []string {
+	merged := append([]string(nil), existing...)
+	for _, id := range added {
+		found := false
+		for _, e := range merged {
+			if e == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, id)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-lti-provider/logging"
+	"go-lti-provider/models"
+)
+
+// ErrRegradeRunning is returned when a resource link is already being regraded
+var ErrRegradeRunning = errors.New("a regrade is already running for this resource link")
+
+// RegradeOptions control a regrade job
+type RegradeOptions struct {
+	Publish     bool   // send the new grades to the platform when done
+	Concurrency int    // attempts run on Judge0 at the same time, 0 = the regrader's default
+	PerMinute   int    // grade posts per minute when publishing
+	LineItem    string // line item for attempts that did not record one
+	By          string
+}
+
+// Regrader runs the stored attempts of a resource link again against the
+// problem's current test cases, in the background and a few at a time
+type Regrader struct {
+	Grading     *GradingService
+	Resync      *GradeResync
+	Concurrency int
+
+	mu      sync.Mutex
+	jobs    map[string]*models.RegradeJob
+	running map[models.ResourceKey]string // resource link -> running job
+	latest  map[models.ResourceKey]string // resource link -> last job
+
+	tasks sync.WaitGroup // running jobs
+}
+
+// NewRegrader creates a new Regrader instance
+func NewRegrader(grading *GradingService, resync *GradeResync, concurrency int) *Regrader {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Regrader{
+		Grading:     grading,
+		Resync:      resync,
+		Concurrency: concurrency,
+		jobs:        make(map[string]*models.RegradeJob),
+		running:     make(map[models.ResourceKey]string),
+		latest:      make(map[models.ResourceKey]string),
+	}
+}
+
+// Start begins regrading every completed attempt on the resource link and
+// returns the job; its progress is read with Get. The job keeps the values of
+// ctx (request ID) but not its cancellation.
+func (r *Regrader) Start(ctx context.Context, ags *AGSService, key models.ResourceKey, opts RegradeOptions) (models.RegradeJob, error) {
+	var problem *models.Problem
+	if r.Resync.Problems != nil {
+		problem, _ = r.Resync.Problems.Get(key)
+	}
+	if problem == nil || len(problem.TestCases) == 0 {
+		return models.RegradeJob{}, fmt.Errorf("the problem has no test cases to regrade against")
+	}
+
+	var ids []string
+	for _, record := range r.Resync.Submissions.ListForResource(key) {
+		if record.Status == models.SubmissionCompleted && record.Source != "" {
+			ids = append(ids, record.ID)
+		}
+	}
+
+	id, err := newSubmissionID()
+	if err != nil {
+		return models.RegradeJob{}, err
+	}
+	job := &models.RegradeJob{
+		ID:          id,
+		ResourceKey: key,
+		Status:      models.RegradeRunning,
+		Publish:     opts.Publish,
+		By:          opts.By,
+		Total:       len(ids),
+		StartedAt:   time.Now(),
+	}
+
+	r.mu.Lock()
+	if _, busy := r.running[key]; busy {
+		r.mu.Unlock()
+		return models.RegradeJob{}, ErrRegradeRunning
+	}
+	r.jobs[id] = job
+	r.running[key] = id
+	r.latest[key] = id
+	snapshot := *job
+	r.mu.Unlock()
+
+	ctx = logging.With(context.WithoutCancel(ctx), "regrade_job", id)
+	slog.InfoContext(ctx, "🔁 Regrading attempts", "resource", key.String(), "attempts", len(ids))
+	r.tasks.Add(1)
+	go r.run(ctx, job, ags, problem, ids, opts)
+	return snapshot, nil
+}
+
+// Get returns a job's progress
+func (r *Regrader) Get(id string) (models.RegradeJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return models.RegradeJob{}, false
+	}
+	return *job, true
+}
+
+// Latest returns the last job started for a resource link
+func (r *Regrader) Latest(key models.ResourceKey) (models.RegradeJob, bool) {
+	r.mu.Lock()
+	id, ok := r.latest[key]
+	r.mu.Unlock()
+	if !ok {
+		return models.RegradeJob{}, false
+	}
+	return r.Get(id)
+}
+
+// Wait blocks until the running jobs are done, or until ctx is done
+func (r *Regrader) Wait(ctx context.Context) error {
+	return waitGroup(ctx, &r.tasks)
+}
+
+func (r *Regrader) run(ctx context.Context, job *models.RegradeJob, ags *AGSService, problem *models.Problem, ids []string, opts RegradeOptions) {
+	defer r.tasks.Done()
+	concurrency := opts.Concurrency
+	if concurrency <= 0 || concurrency > r.Concurrency {
+		concurrency = r.Concurrency
+	}
+
+	// The late penalty depends on the line item's due date; read each once
+	var policyMu sync.Mutex
+	policies := make(map[string]models.AssignmentPolicy)
+	policyFor := func(lineItem string) models.AssignmentPolicy {
+		policyMu.Lock()
+		defer policyMu.Unlock()
+		policy, ok := policies[lineItem]
+		if !ok {
+			policy = LineItemPolicy(ctx, ags, problem, lineItem)
+			policies[lineItem] = policy
+		}
+		return policy
+	}
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				changed, err := r.regrade(ctx, job.ID, id, problem, opts.LineItem, policyFor)
+				if err != nil {
+					slog.WarnContext(ctx, "⚠️ Regrade of submission failed", "submission_id", id, "error", err)
+				}
+
+				r.mu.Lock()
+				job.Done++
+				if err != nil {
+					job.Failed++
+				}
+				if changed {
+					job.Changed++
+				}
+				r.mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	var report *models.ResyncReport
+	var publishErr error
+	if opts.Publish {
+		report, publishErr = r.Resync.Run(ctx, ags, job.ResourceKey, ResyncOptions{
+			PerMinute: opts.PerMinute,
+			LineItem:  opts.LineItem,
+			By:        opts.By,
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Resync = report
+	job.Status = models.RegradeCompleted
+	if publishErr != nil {
+		job.Status = models.RegradeFailed
+		job.Error = "publishing grades failed: " + publishErr.Error()
+	}
+	delete(r.running, job.ResourceKey)
+
+	slog.InfoContext(ctx, "✅ Regrade done", "done", job.Done, "total", job.Total,
+		"changed", job.Changed, "failed", job.Failed)
+}
+
+// regrade runs one attempt again and stores the new results next to the old
+// ones. It reports whether the attempt's score changed.
+func (r *Regrader) regrade(ctx context.Context, jobID, id string, problem *models.Problem, defaultLineItem string,
+	policyFor func(string) models.AssignmentPolicy) (bool, error) {
+
+	record, ok := r.Resync.Submissions.Get(id)
+	if !ok {
+		return false, fmt.Errorf("submission not found")
+	}
+
+	// Same path as a graded submit, on a copy of the attempt
+	run := *record
+	run.Tests, run.Result, run.Judge0Tokens, run.Error = nil, nil, nil, ""
+	execErr := r.Grading.Execute(ctx, &run, problem)
+
+	entry := models.Regrade{
+		JobID:  jobID,
+		At:     time.Now(),
+		Status: run.Status,
+		Error:  run.Error,
+		Tests:  run.Tests,
+	}
+	if execErr == nil {
+		lineItem := record.LineItem
+		if lineItem == "" {
+			lineItem = defaultLineItem
+		}
+		ApplyLatePenalty(policyFor(lineItem), &run)
+		entry.Score, entry.RawScore, entry.LatePenalty = run.Score, run.RawScore, run.LatePenalty
+	}
+
+	// Reload so changes made while Judge0 ran (e.g. an override) are kept
+	current, ok := r.Resync.Submissions.Get(id)
+	if !ok {
+		return false, fmt.Errorf("submission deleted during regrade")
+	}
+	entry.PreviousScore = current.Score
+	if execErr == nil && current.Override == nil {
+		entry.Applied = true
+		entry.PreviousTests = current.Tests
+		current.Tests = run.Tests
+		current.Score, current.RawScore, current.LatePenalty = run.Score, run.RawScore, run.LatePenalty
+		current.MaxScore = run.MaxScore
+	}
+	current.Regrades = append(current.Regrades, entry)
+
+	if err := r.Resync.Submissions.Save(current); err != nil {
+		return false, fmt.Errorf("failed to save regrade: %w", err)
+	}
+	return entry.Applied && entry.Score != entry.PreviousScore, execErr
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+// ResyncOptions control a grade resync
+type ResyncOptions struct {
+	DryRun    bool   // compare only, nothing is saved or sent
+	PerMinute int    // grade posts per minute, 0 = unlimited
+	LineItem  string // line item for attempts that did not record one
+	By        string // who started the resync, for the audit log
+}
+
+// GradeResync recomputes the grades of a resource link from the stored
+// submissions and sends the ones the platform does not have
+type GradeResync struct {
+	Submissions *SubmissionStore
+	Problems    *ProblemStore
+	Audit       *AuditLog
+}
+
+// NewGradeResync creates a new GradeResync instance
+func NewGradeResync(submissions *SubmissionStore, problems *ProblemStore, audit *AuditLog) *GradeResync {
+	return &GradeResync{
+		Submissions: submissions,
+		Problems:    problems,
+		Audit:       audit,
+	}
+}
+
+// Run walks every learner with attempts on the resource link. Each grade is
+// recomputed with the current policy of the problem (late penalty and
+// aggregation; the due date of the line item counts, launch custom
+// parameters do not), compared with the platform's AGS result and re-posted
+// when they differ. Instructor overrides are kept as they are.
+func (s *GradeResync) Run(ctx context.Context, ags *AGSService, key models.ResourceKey, opts ResyncOptions) (*models.ResyncReport, error) {
+	if s.Submissions == nil {
+		return nil, fmt.Errorf("no submission store")
+	}
+
+	report := &models.ResyncReport{
+		ResourceKey: key,
+		DryRun:      opts.DryRun,
+		Counts:      make(map[models.ResyncAction]int),
+		StartedAt:   time.Now(),
+	}
+
+	var problem *models.Problem
+	if s.Problems != nil {
+		problem, _ = s.Problems.Get(key)
+	}
+	defaultLineItem := opts.LineItem
+	if defaultLineItem == "" && problem != nil {
+		defaultLineItem = problem.LineItem
+	}
+
+	// Attempts per learner, newest first
+	byUser := make(map[string][]models.SubmissionRecord)
+	var users []string
+	for _, record := range s.Submissions.ListForResource(key) {
+		if _, ok := byUser[record.UserID]; !ok {
+			users = append(users, record.UserID)
+		}
+		byUser[record.UserID] = append(byUser[record.UserID], record)
+	}
+
+	policies := make(map[string]models.AssignmentPolicy)
+	results := make(map[string]map[string]lti.Result)
+
+	var interval time.Duration
+	if opts.PerMinute > 0 {
+		interval = time.Minute / time.Duration(opts.PerMinute)
+	}
+	var lastPost time.Time
+
+	for _, userID := range users {
+		attempts := byUser[userID]
+		item := models.ResyncItem{UserID: userID, LineItem: defaultLineItem}
+		for _, a := range attempts {
+			if a.LineItem != "" {
+				item.LineItem = a.LineItem
+				break
+			}
+		}
+
+		if item.LineItem == "" {
+			item.Action = models.ResyncSkipped
+			item.Error = "no line item"
+			report.Add(item)
+			continue
+		}
+
+		// Policy and platform results are fetched once per line item
+		policy, ok := policies[item.LineItem]
+		if !ok {
+			policy = LineItemPolicy(ctx, ags, problem, item.LineItem)
+			policies[item.LineItem] = policy
+		}
+		platform, ok := results[item.LineItem]
+		if !ok {
+			list, err := ags.GetResults(ctx, item.LineItem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read results of %s: %w", item.LineItem, err)
+			}
+			platform = make(map[string]lti.Result, len(list))
+			for _, r := range list {
+				platform[r.UserID] = r
+			}
+			results[item.LineItem] = platform
+		}
+
+		// Late penalties under the current policy
+		var rescored []*models.SubmissionRecord
+		for i := range attempts {
+			if RecomputeLatePenalty(policy, &attempts[i]) {
+				rescored = append(rescored, &attempts[i])
+			}
+		}
+		item.Rescored = len(rescored)
+
+		grade, ok := policy.AggregateScore(attempts)
+		if !ok {
+			item.Action = models.ResyncSkipped
+			item.Error = "no graded attempt"
+			report.Add(item)
+			continue
+		}
+		latest := latestGraded(attempts)
+		item.Grade = grade
+		item.MaxScore = latest.MaxScore
+
+		if result, ok := platform[userID]; ok {
+			item.PlatformScore = result.ResultScore
+			item.PlatformMaximum = result.ResultMaximum
+		}
+
+		if sameGrade(item) {
+			item.Action = models.ResyncUnchanged
+		} else {
+			item.Action = models.ResyncChanged
+		}
+		if opts.DryRun {
+			report.Add(item)
+			continue
+		}
+
+		for _, record := range rescored {
+			if err := s.Submissions.Save(record); err != nil {
+				slog.WarnContext(ctx, "⚠️ Failed to save rescored submission", "submission_id", record.ID, "error", err)
+			}
+		}
+		if item.Action == models.ResyncUnchanged {
+			report.Add(item)
+			continue
+		}
+
+		// Rate limit the posts, e.g. to stay under the platform's web service limits
+		if wait := interval - time.Since(lastPost); interval > 0 && !lastPost.IsZero() && wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		lastPost = time.Now()
+
+		err := ags.SubmitGrade(ctx, models.AGSGradeRequest{
+			LineItemURL:     item.LineItem,
+			UserID:          userID,
+			Score:           &grade,
+			MaxScore:        item.MaxScore,
+			Comment:         fmt.Sprintf("Grade resynced at %s", time.Now().Format(time.RFC3339)),
+			GradingProgress: latest.GradingProgress,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "❌ Resync of learner failed", "learner_id", userID, "error", err)
+			item.Action = models.ResyncFailed
+			item.Error = err.Error()
+		} else {
+			item.Action = models.ResyncUpdated
+		}
+		s.audit(ctx, key, item, opts.By)
+		report.Add(item)
+	}
+
+	report.FinishedAt = time.Now()
+	slog.InfoContext(ctx, "🔄 Grade resync done", "resource", key.String(), "counts", report.Counts, "dry_run", opts.DryRun)
+	return report, nil
+}
+
+func (s *GradeResync) audit(ctx context.Context, key models.ResourceKey, item models.ResyncItem, by string) {
+	if s.Audit == nil {
+		return
+	}
+
+	entry := models.AuditEntry{
+		ResourceKey: key,
+		Action:      models.AuditGradeResync,
+		By:          by,
+		UserID:      item.UserID,
+		LineItem:    item.LineItem,
+		NewScore:    item.Grade,
+		MaxScore:    item.MaxScore,
+		Comment:     item.Error,
+		Synced:      item.Action == models.ResyncUpdated,
+	}
+	if item.PlatformScore != nil {
+		old := platformFraction(item) * item.MaxScore
+		entry.OldScore = &old
+	}
+	if err := s.Audit.Append(entry); err != nil {
+		slog.WarnContext(ctx, "⚠️ Failed to write audit log", "error", err)
+	}
+}
+
+// RecomputeLatePenalty applies the policy's late penalty to a completed,
+// autograded attempt again. It reports whether the score changed.
+func RecomputeLatePenalty(policy models.AssignmentPolicy, record *models.SubmissionRecord) bool {
+	if record.Status != models.SubmissionCompleted || record.Override != nil {
+		return false
+	}
+
+	// Attempts stored before late penalties only have a score
+	raw := record.RawScore
+	if raw == 0 && record.LatePenalty == 0 {
+		raw = record.Score
+	}
+
+	penalty := policy.PenaltyAt(record.CreatedAt)
+	score := raw * (1 - penalty/100)
+	if score == record.Score && penalty == record.LatePenalty {
+		return false
+	}
+
+	record.RawScore = raw
+	record.LatePenalty = penalty
+	record.Score = score
+	return true
+}
+
+// latestGraded returns the newest completed attempt
+func latestGraded(attempts []models.SubmissionRecord) models.SubmissionRecord {
+	for _, a := range attempts {
+		if a.Status == models.SubmissionCompleted {
+			return a
+		}
+	}
+	return models.SubmissionRecord{}
+}
+
+// sameGrade compares the grades as a share of their maximum, since the
+// platform may scale scores to the gradebook's maximum
+func sameGrade(item models.ResyncItem) bool {
+	if item.PlatformScore == nil || item.MaxScore <= 0 {
+		return false
+	}
+	return math.Abs(platformFraction(item)-item.Grade/item.MaxScore) < 1e-4
+}
+
+func platformFraction(item models.ResyncItem) float64 {
+	maximum := 1.0
+	if item.PlatformMaximum != nil && *item.PlatformMaximum > 0 {
+		maximum = *item.PlatformMaximum
+	}
+	return *item.PlatformScore / maximum
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"go-lti-provider/models"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const sessionClaim = "lti_session"
+
+// SessionService issues and verifies the signed launch session tokens the
+// frontend sends back on API calls
+type SessionService struct {
+	key    []byte
+	ttl    time.Duration
+	issuer string
+}
+
+// NewSessionService creates a new SessionService instance. When secret is
+// empty a random key is generated, so sessions do not survive a restart.
+func NewSessionService(secret string, ttl time.Duration, issuer string) (*SessionService, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+	}
+	if ttl <= 0 {
+		ttl = 8 * time.Hour
+	}
+
+	return &SessionService{key: key, ttl: ttl, issuer: issuer}, nil
+}
+
+// Issue signs a session token. The session's ExpiresAt is set from the TTL.
+func (s *SessionService) Issue(session *models.LaunchSession) (string, error) {
+	now := time.Now()
+	session.ExpiresAt = now.Add(s.ttl)
+
+	token, err := jwt.NewBuilder().
+		Issuer(s.issuer).
+		Subject(session.UserID).
+		IssuedAt(now).
+		Expiration(session.ExpiresAt).
+		Claim(sessionClaim, session).
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to build session token: %w", err)
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.HS256, s.key))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return string(signed), nil
+}
+
+// Verify checks a session token and returns the session it carries
+func (s *SessionService) Verify(tokenString string) (*models.LaunchSession, error) {
+	token, err := jwt.Parse([]byte(tokenString),
+		jwt.WithKey(jwa.HS256, s.key),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithTypedClaim(sessionClaim, models.LaunchSession{}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session token: %w", err)
+	}
+
+	value, ok := token.Get(sessionClaim)
+	if !ok {
+		return nil, fmt.Errorf("session token has no %s claim", sessionClaim)
+	}
+	session, ok := value.(models.LaunchSession)
+	if !ok {
+		return nil, fmt.Errorf("malformed %s claim", sessionClaim)
+	}
+	return &session, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+// SubmissionStore keeps every attempt, keyed by platform, context, resource
+// link and user. Records are held in memory and persisted as one JSON file
+// per user and resource link under dir, so a save only rewrites that file.
+type SubmissionStore struct {
+	mu      sync.RWMutex
+	dir     string
+	records map[string]*models.SubmissionRecord // id -> record
+}
+
+// NewSubmissionStore loads all submissions saved under dir (if any)
+func NewSubmissionStore(dir string) (*SubmissionStore, error) {
+	s := &SubmissionStore{dir: dir, records: make(map[string]*models.SubmissionRecord)}
+	if dir == "" {
+		return s, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submissions: %w", err)
+	}
+	for _, file := range files {
+		var records []*models.SubmissionRecord
+		if err := readJSONFile(file, &records); err != nil {
+			return nil, fmt.Errorf("failed to load submissions: %w", err)
+		}
+		for _, record := range records {
+			s.records[record.ID] = record
+		}
+	}
+
+	return s, nil
+}
+
+// Save inserts or updates a record and persists its user's file. New records
+// get an ID.
+func (s *SubmissionStore) Save(record *models.SubmissionRecord) error {
+	if record.ID == "" {
+		id, err := newSubmissionID()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *record
+	s.records[record.ID] = &stored
+	return s.persistLocked(record.ResourceKey, record.UserID)
+}
+
+// Reserve saves a new attempt as pending if check, given the user's other
+// attempts on the resource link (newest first), allows it. The check and the
+// save hold the same lock, so parallel submits cannot both pass the limits.
+func (s *SubmissionStore) Reserve(record *models.SubmissionRecord, check func(attempts []models.SubmissionRecord) error) error {
+	id, err := newSubmissionID()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := check(s.listLocked(record.ResourceKey, record.UserID)); err != nil {
+		return err
+	}
+
+	record.ID = id
+	record.Status = models.SubmissionPending
+	record.GradingProgress = lti.GradingPending
+	stored := *record
+	s.records[record.ID] = &stored
+	return s.persistLocked(record.ResourceKey, record.UserID)
+}
+
+// FailInterrupted marks the attempts left pending by a crash or restart as
+// failed, so they no longer count toward the attempt limit. Call it once at
+// startup, before grading starts. It returns how many were failed.
+func (s *SubmissionStore) FailInterrupted() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failed := 0
+	touched := make(map[string]*models.SubmissionRecord)
+	for _, record := range s.records {
+		if record.Status != models.SubmissionPending {
+			continue
+		}
+		record.Status = models.SubmissionFailed
+		record.GradingProgress = lti.GradingFailed
+		record.Error = "grading was interrupted by a restart"
+		record.CompletedAt = time.Now()
+		touched[record.ResourceKey.String()+"|"+record.UserID] = record
+		failed++
+	}
+	for _, record := range touched {
+		if err := s.persistLocked(record.ResourceKey, record.UserID); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// Get returns a record by ID
+func (s *SubmissionStore) Get(id string) (*models.SubmissionRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *record
+	return &copied, true
+}
+
+// ListForUser returns a user's attempts on a resource link, newest first
+func (s *SubmissionStore) ListForUser(key models.ResourceKey, userID string) []models.SubmissionRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listLocked(key, userID)
+}
+
+// ListForResource returns all attempts on a resource link, newest first
+func (s *SubmissionStore) ListForResource(key models.ResourceKey) []models.SubmissionRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listLocked(key, "")
+}
+
+// ResourceKeys returns every resource link that has attempts
+func (s *SubmissionStore) ResourceKeys() []models.ResourceKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[models.ResourceKey]bool)
+	var keys []models.ResourceKey
+	for _, record := range s.records {
+		if !seen[record.ResourceKey] {
+			seen[record.ResourceKey] = true
+			keys = append(keys, record.ResourceKey)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func (s *SubmissionStore) listLocked(key models.ResourceKey, userID string) []models.SubmissionRecord {
+	var records []models.SubmissionRecord
+	for _, record := range s.records {
+		if record.ResourceKey == key && (userID == "" || record.UserID == userID) {
+			records = append(records, *record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	return records
+}
+
+func (s *SubmissionStore) persistLocked(key models.ResourceKey, userID string) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	records := s.listLocked(key, userID)
+	path := filepath.Join(s.dir, hashKey(key.String()), hashKey(userID)+".json")
+	if len(records) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	return writeJSONFile(path, records)
+}
+
+// hashKey turns identifiers from the platform into safe file names
+func hashKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:16])
+}
+
+func newSubmissionID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate submission id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
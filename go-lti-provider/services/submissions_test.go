@@ -0,0 +1,107 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go-lti-provider/lti"
+	"go-lti-provider/models"
+)
+
+func TestSubmissionStoreReserve(t *testing.T) {
+	store, err := NewSubmissionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := models.ResourceKey{Issuer: "https://lms.example.edu", ContextID: "c1", ResourceLinkID: "r1"}
+	policy := models.AssignmentPolicy{MaxAttempts: 3}
+
+	// Parallel submits of one learner: only MaxAttempts of them get through
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record := &models.SubmissionRecord{ResourceKey: key, UserID: "learner", CreatedAt: time.Now()}
+			err := store.Reserve(record, func(attempts []models.SubmissionRecord) error {
+				return CheckAttempt(policy, attempts, record.CreatedAt)
+			})
+			if err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != policy.MaxAttempts {
+		t.Errorf("%d attempts accepted, want %d", accepted, policy.MaxAttempts)
+	}
+	attempts := store.ListForUser(key, "learner")
+	if len(attempts) != policy.MaxAttempts {
+		t.Fatalf("%d attempts stored, want %d", len(attempts), policy.MaxAttempts)
+	}
+	for _, a := range attempts {
+		if a.ID == "" || a.Status != models.SubmissionPending {
+			t.Errorf("reserved attempt = %+v, want a pending attempt with an ID", a)
+		}
+	}
+
+	// Another learner has their own limit
+	other := &models.SubmissionRecord{ResourceKey: key, UserID: "other", CreatedAt: time.Now()}
+	if err := store.Reserve(other, func(attempts []models.SubmissionRecord) error {
+		return CheckAttempt(policy, attempts, other.CreatedAt)
+	}); err != nil {
+		t.Errorf("Reserve() for another learner = %v", err)
+	}
+}
+
+func TestSubmissionStoreFailInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSubmissionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := models.ResourceKey{Issuer: "https://lms.example.edu", ContextID: "c1", ResourceLinkID: "r1"}
+	for _, status := range []models.SubmissionStatus{models.SubmissionPending, models.SubmissionCompleted} {
+		if err := store.Save(&models.SubmissionRecord{ResourceKey: key, UserID: "learner", Status: status, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// As after a restart: the pending attempt is no longer being graded
+	restarted, err := NewSubmissionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := restarted.FailInterrupted()
+	if err != nil || failed != 1 {
+		t.Fatalf("FailInterrupted() = %d, %v; want 1, nil", failed, err)
+	}
+
+	attempts := restarted.ListForUser(key, "learner")
+	if used := CountAttempts(attempts); used != 1 {
+		t.Errorf("CountAttempts() = %d after the restart, want 1", used)
+	}
+	for _, a := range attempts {
+		if a.Status == models.SubmissionPending {
+			t.Errorf("attempt %s still pending", a.ID)
+		}
+		if a.Status == models.SubmissionFailed && a.GradingProgress != lti.GradingFailed {
+			t.Errorf("GradingProgress = %q, want %q", a.GradingProgress, lti.GradingFailed)
+		}
+	}
+
+	// The change is persisted
+	reloaded, err := NewSubmissionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed, _ := reloaded.FailInterrupted(); failed != 0 {
+		t.Errorf("FailInterrupted() after reload = %d, want 0", failed)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+
+	"go-lti-provider/models"
+)
+
+// defaultThemeKey is the entry of the themes file used for platforms
+// without their own theme
+const defaultThemeKey = "default"
+
+// ThemeStore holds the page themes per platform issuer, loaded from a JSON
+// file such as {"default": {...}, "https://moodle.example.edu": {...}}
+type ThemeStore struct {
+	themes map[string]models.Theme
+}
+
+// NewThemeStore loads the themes saved at path (if any)
+func NewThemeStore(path string) (*ThemeStore, error) {
+	s := &ThemeStore{themes: make(map[string]models.Theme)}
+
+	if path != "" {
+		if err := readJSONFile(path, &s.themes); err != nil {
+			return nil, fmt.Errorf("failed to load themes: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// For returns the theme of a platform, filled in from the default theme
+func (s *ThemeStore) For(issuer string) models.Theme {
+	base := models.DefaultTheme
+	if s == nil {
+		return base
+	}
+	if theme, ok := s.themes[defaultThemeKey]; ok {
+		base = theme.Merge(base)
+	}
+	if theme, ok := s.themes[issuer]; ok && issuer != "" {
+		return theme.Merge(base)
+	}
+	return base
+}
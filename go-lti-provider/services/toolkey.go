@@ -0,0 +1,151 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// clientAssertionType is the client_assertion_type of private_key_jwt
+// (RFC 7523), which LTI 1.3 tools use at the platform's token endpoint
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionTTL is how long a client assertion is valid
+const clientAssertionTTL = 5 * time.Minute
+
+// ToolKey is the tool's RSA signing key. Its public half is published at
+// /.well-known/jwks.json (the jwks_uri given to platforms), and it signs the
+// client assertions of the tool's token requests.
+type ToolKey struct {
+	private jwk.Key
+	public  jwk.Set
+}
+
+// LoadToolKey reads the tool's private key (PKCS#1 or PKCS#8 PEM) from
+// path. The first time, when path does not exist, a key is generated and
+// saved there so it stays the same across restarts.
+func LoadToolKey(path string) (*ToolKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = generateToolKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tool key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to load tool key: %s is not PEM", path)
+	}
+	raw, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err8 != nil {
+			return nil, fmt.Errorf("failed to parse tool key: %w", err)
+		}
+		var ok bool
+		if raw, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("tool key must be an RSA key")
+		}
+	}
+	return NewToolKey(raw)
+}
+
+// NewToolKey wraps an RSA private key; its key ID is the JWK thumbprint
+func NewToolKey(raw *rsa.PrivateKey) (*ToolKey, error) {
+	private, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap tool key: %w", err)
+	}
+	if err := jwk.AssignKeyID(private); err != nil {
+		return nil, fmt.Errorf("failed to assign key id: %w", err)
+	}
+	private.Set(jwk.AlgorithmKey, jwa.RS256)
+	private.Set(jwk.KeyUsageKey, jwk.ForSignature)
+
+	public, err := jwk.PublicKeyOf(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive public key: %w", err)
+	}
+	set := jwk.NewSet()
+	set.AddKey(public)
+
+	return &ToolKey{private: private, public: set}, nil
+}
+
+// KeyID returns the kid of the key
+func (k *ToolKey) KeyID() string {
+	return k.private.KeyID()
+}
+
+// PublicSet returns the JWK Set published at the tool's jwks_uri
+func (k *ToolKey) PublicSet() jwk.Set {
+	return k.public
+}
+
+// ClientAssertion signs the JWT the tool authenticates with at a platform's
+// token endpoint (private_key_jwt): issued by and for clientID, to tokenURL
+func (k *ToolKey) ClientAssertion(clientID, tokenURL string) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	token, err := jwt.NewBuilder().
+		Issuer(clientID).
+		Subject(clientID).
+		Audience([]string{tokenURL}).
+		IssuedAt(now).
+		Expiration(now.Add(clientAssertionTTL)).
+		JwtID(jti).
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to build client assertion: %w", err)
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, k.private))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return string(signed), nil
+}
+
+// generateToolKey creates a 2048-bit key at path, unless another process
+// created it first, and returns the PEM in the file
+func generateToolKey(path string) ([]byte, error) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(raw)})
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
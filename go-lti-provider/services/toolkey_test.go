@@ -0,0 +1,99 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+func TestLoadToolKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "tool_key.pem")
+
+	generated, err := LoadToolKey(path)
+	if err != nil {
+		t.Fatalf("LoadToolKey() of a missing file = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("generated key was not saved: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("key file mode = %o, want 600", mode)
+	}
+
+	loaded, err := LoadToolKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.KeyID() == "" || loaded.KeyID() != generated.KeyID() {
+		t.Errorf("KeyID() = %q after a reload, want %q", loaded.KeyID(), generated.KeyID())
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadToolKey(notPEM); err == nil {
+		t.Error("LoadToolKey() of a file that is not PEM succeeded")
+	}
+}
+
+func TestToolKeyClientAssertion(t *testing.T) {
+	key, err := LoadToolKey(filepath.Join(t.TempDir(), "tool_key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, ok := key.PublicSet().Key(0)
+	if !ok || key.PublicSet().Len() != 1 {
+		t.Fatalf("PublicSet() has %d keys, want 1", key.PublicSet().Len())
+	}
+	if public.KeyID() != key.KeyID() || public.Algorithm() != jwa.RS256 {
+		t.Errorf("public key kid, alg = %q, %q", public.KeyID(), public.Algorithm())
+	}
+	if _, isPrivate := public.(jwk.RSAPrivateKey); isPrivate {
+		t.Fatal("PublicSet() publishes the private key")
+	}
+
+	const tokenURL = "https://lms.example.edu/mod/lti/token.php"
+	assertion, err := key.ClientAssertion("tool-client", tokenURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The platform verifies it with the keys at the tool's jwks_uri
+	token, err := jwt.Parse([]byte(assertion),
+		jwt.WithKeySet(key.PublicSet()),
+		jwt.WithIssuer("tool-client"),
+		jwt.WithSubject("tool-client"),
+		jwt.WithAudience(tokenURL),
+	)
+	if err != nil {
+		t.Fatalf("client assertion does not verify with the public set: %v", err)
+	}
+	if token.JwtID() == "" {
+		t.Error("client assertion has no jti")
+	}
+	if ttl := token.Expiration().Sub(token.IssuedAt()); ttl != clientAssertionTTL {
+		t.Errorf("client assertion valid for %s, want %s", ttl, clientAssertionTTL)
+	}
+
+	again, err := key.ClientAssertion("tool-client", tokenURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == assertion {
+		t.Error("two client assertions are the same; the jti must not repeat")
+	}
+
+	other, err := LoadToolKey(filepath.Join(t.TempDir(), "other_key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse([]byte(assertion), jwt.WithKeySet(other.PublicSet())); err == nil {
+		t.Error("client assertion verifies with another tool's key")
+	}
+}
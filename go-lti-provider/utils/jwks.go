@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-lti-provider/config"
+	"go-lti-provider/tracing"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// platformKeys caches the key sets of the platforms by JWKS URL, so a launch
+// storm at the start of class does not fetch them from the platform every
+// time. The cache refreshes each set in the background when the platform's
+// Cache-Control max-age (or JWKS_MIN_REFRESH_INTERVAL) has passed; a failed
+// refresh keeps the last set fetched.
+var platformKeys = struct {
+	once  sync.Once
+	cache *jwk.Cache
+
+	sync.Mutex
+	registered map[string]bool
+	forced     map[string]time.Time // last refresh not done by the cache itself
+}{
+	registered: make(map[string]bool),
+	forced:     make(map[string]time.Time),
+}
+
+// platformKeySet returns the key set published at jwksURL. When it has no key
+// kid (the platform rotated its keys) or could not be fetched so far, it is
+// fetched again, at most once per JWKS_KID_REFRESH_INTERVAL per platform. If
+// that fails the last set fetched is returned.
+func platformKeySet(ctx context.Context, jwksURL, kid string) (set jwk.Set, refreshed bool, err error) {
+	cfg := config.LoadConfig()
+	if err := registerJWKS(jwksURL, cfg.JWKSMinRefreshInterval); err != nil {
+		return nil, false, err
+	}
+
+	set, err = platformKeys.cache.Get(ctx, jwksURL)
+	if err == nil && (kid == "" || hasKey(set, kid)) {
+		return set, false, nil
+	}
+
+	if !allowRefresh(jwksURL, cfg.JWKSKidRefreshInterval) {
+		// Vừa refresh xong (hoặc đang refresh): Get chờ và trả về set mới nhất
+		set, err = platformKeys.cache.Get(ctx, jwksURL)
+		return set, false, err
+	}
+
+	reason := "unknown kid"
+	if err != nil {
+		reason = "no key set"
+	}
+	slog.InfoContext(ctx, "🔑 Refreshing platform JWKS", "url", jwksURL, "kid", kid, "reason", reason)
+	fresh, refreshErr := platformKeys.cache.Refresh(ctx, jwksURL)
+	switch {
+	case refreshErr == nil:
+		return fresh, true, nil
+	case err == nil:
+		slog.WarnContext(ctx, "⚠️ JWKS refresh failed, using the last key set", "url", jwksURL, "error", refreshErr)
+		return set, false, nil
+	default:
+		return nil, false, refreshErr
+	}
+}
+
+// registerJWKS adds jwksURL to the cache on its first use
+func registerJWKS(jwksURL string, minRefresh time.Duration) error {
+	platformKeys.once.Do(func() {
+		platformKeys.cache = jwk.NewCache(context.Background(), jwk.WithErrSink(jwksErrorLog{}))
+	})
+
+	platformKeys.Lock()
+	defer platformKeys.Unlock()
+	if platformKeys.registered[jwksURL] {
+		return nil
+	}
+	err := platformKeys.cache.Register(jwksURL,
+		jwk.WithMinRefreshInterval(minRefresh),
+		jwk.WithHTTPClient(tracing.HTTPClient(30*time.Second)), // fetches show up in the launch traces
+	)
+	if err != nil {
+		return err
+	}
+	platformKeys.registered[jwksURL] = true
+	return nil
+}
+
+// allowRefresh rate limits the refreshes forced by launches
+func allowRefresh(jwksURL string, interval time.Duration) bool {
+	platformKeys.Lock()
+	defer platformKeys.Unlock()
+	if last, ok := platformKeys.forced[jwksURL]; ok && time.Since(last) < interval {
+		return false
+	}
+	platformKeys.forced[jwksURL] = time.Now()
+	return true
+}
+
+func hasKey(set jwk.Set, kid string) bool {
+	_, ok := set.LookupKeyID(kid)
+	return ok
+}
+
+// jwksErrorLog logs the background refreshes that failed
+type jwksErrorLog struct{}
+
+func (jwksErrorLog) Error(err error) {
+	slog.Warn("⚠️ Background JWKS refresh failed, keeping the last key set", "error", err)
+}
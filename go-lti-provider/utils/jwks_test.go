@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-lti-provider/config"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// testPlatform publishes a JWKS that the test can rotate or break, and counts
+// the fetches
+type testPlatform struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu     sync.Mutex
+	keys   map[string]jwk.Key // kid → private key
+	public jwk.Set
+	fail   bool
+}
+
+func newTestPlatform(t *testing.T, kids ...string) *testPlatform {
+	p := &testPlatform{keys: make(map[string]jwk.Key), public: jwk.NewSet()}
+	for _, kid := range kids {
+		p.publish(t, kid)
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.fetches.Add(1)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.fail {
+			http.Error(w, "maintenance", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.public)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// publish adds a new signing key to the platform's JWKS
+func (p *testPlatform) publish(t *testing.T, kid string) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, kid)
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+	public, err := key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys[kid] = key
+	p.public.AddKey(public)
+}
+
+func (p *testPlatform) setFailing(fail bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = fail
+}
+
+// sign issues an id_token signed with the key kid; a kid the platform never
+// published is signed with a throwaway key
+func (p *testPlatform) sign(t *testing.T, kid string) string {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if !ok {
+		raw, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, err = jwk.FromRaw(raw); err != nil {
+			t.Fatal(err)
+		}
+		key.Set(jwk.KeyIDKey, kid)
+	}
+
+	token, err := jwt.NewBuilder().Issuer(p.URL).Audience([]string{"tool"}).
+		IssuedAt(time.Now()).Expiration(time.Now().Add(time.Minute)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+// useJWKSConfig keeps the background refresh out of the way and allows one
+// forced refresh per minute
+func useJWKSConfig(t *testing.T) {
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.JWKSMinRefreshInterval = time.Hour
+	cfg.JWKSKidRefreshInterval = time.Minute
+	previous := config.LoadConfig()
+	config.Use(cfg)
+	t.Cleanup(func() { config.Use(previous) })
+}
+
+func TestVerifyIDTokenCachesKeys(t *testing.T) {
+	useJWKSConfig(t)
+	platform := newTestPlatform(t, "k1")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+			t.Fatalf("verification %d: %v", i+1, err)
+		}
+	}
+	if got := platform.fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestVerifyIDTokenRotatedKey(t *testing.T) {
+	useJWKSConfig(t)
+	platform := newTestPlatform(t, "k1")
+	ctx := context.Background()
+
+	if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// The platform rotates its keys: the new kid triggers one refresh
+	platform.publish(t, "k2")
+	if _, err := VerifyIDToken(ctx, platform.sign(t, "k2"), platform.URL); err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	if got := platform.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+
+	// Both keys are in the refreshed set
+	for _, kid := range []string{"k1", "k2"} {
+		if _, err := VerifyIDToken(ctx, platform.sign(t, kid), platform.URL); err != nil {
+			t.Errorf("token signed with %s: %v", kid, err)
+		}
+	}
+	if got := platform.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestVerifyIDTokenUnknownKidRateLimited(t *testing.T) {
+	useJWKSConfig(t)
+	platform := newTestPlatform(t, "k1")
+	ctx := context.Background()
+
+	if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens with made-up kids cannot make the tool hammer the platform
+	for i, kid := range []string{"forged-1", "forged-1", "forged-2", "forged-3"} {
+		_, err := VerifyIDToken(ctx, platform.sign(t, kid), platform.URL)
+		if err == nil {
+			t.Fatalf("token with unknown kid %s verified", kid)
+		}
+		if errors.Is(err, ErrJWKSUnavailable) {
+			t.Errorf("token %d: %v, want a signature error", i+1, err)
+		}
+	}
+	if got := platform.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2 (the first fetch and one refresh)", got)
+	}
+
+	// The known key still verifies without another fetch
+	if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got := platform.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestVerifyIDTokenPlatformDown(t *testing.T) {
+	tests := []struct {
+		name        string
+		down        func(*testPlatform)
+		wantFetches int32 // the failed refresh only counts when the server answers
+	}{
+		{"server error", func(p *testPlatform) { p.setFailing(true) }, 2},
+		{"unreachable", func(p *testPlatform) { p.Close() }, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useJWKSConfig(t)
+			platform := newTestPlatform(t, "k1")
+			ctx := context.Background()
+
+			if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+				t.Fatal(err)
+			}
+			tt.down(platform)
+
+			// The forced refresh fails: the token is checked against the
+			// last good set instead of failing the launch on the JWKS
+			_, err := VerifyIDToken(ctx, platform.sign(t, "k2"), platform.URL)
+			if err == nil || errors.Is(err, ErrJWKSUnavailable) {
+				t.Errorf("token with unknown kid: %v, want a signature error", err)
+			}
+
+			if _, err := VerifyIDToken(ctx, platform.sign(t, "k1"), platform.URL); err != nil {
+				t.Errorf("token signed with the last good key: %v", err)
+			}
+			if got := platform.fetches.Load(); got != tt.wantFetches {
+				t.Errorf("JWKS fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
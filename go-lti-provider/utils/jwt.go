@@ -4,34 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/lestrrat-go/jwx/v2/jwa"
-	"github.com/lestrrat-go/jwx/v2/jwk"
-	"github.com/lestrrat-go/jwx/v2/jwt"
-)
+	"go-lti-provider/config"
+	"go-lti-provider/lti"
+	"go-lti-provider/tracing"
 
-// Moodle local endpoints
-const (
-	moodleJWKSURL = "http://localhost:8888/mod/lti/certs.php"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// OAuth2 Token Response
-type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
-	Scope       string `json:"scope"`
-}
-
 // GetAccessToken retrieves OAuth2 access token for AGS
 func GetAccessToken() (string, error) {
-	tokenURL := "http://localhost:8888/mod/lti/token.php"
-	clientID := "wAWXk7ifY0o9tCU"
-	clientSecret := "your-client-secret"
-	scope := "https://purl.imsglobal.org/spec/lti-ags/scope/score"
+	cfg := config.LoadConfig()
+	tokenURL := cfg.TokenEndpoint
+	clientID := cfg.ClientID
+	clientSecret := cfg.ClientSecret
+	scope := cfg.AGSScope
 
 	data := fmt.Sprintf("grant_type=client_credentials&client_id=%s&client_secret=%s&scope=%s",
 		clientID, clientSecret, scope)
@@ -54,7 +45,7 @@ func GetAccessToken() (string, error) {
 		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
 	}
 
-	var tokenResp TokenResponse
+	var tokenResp lti.TokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
 		return "", fmt.Errorf("failed to decode token response: %w", err)
 	}
@@ -62,81 +53,23 @@ func GetAccessToken() (string, error) {
 	return tokenResp.AccessToken, nil
 }
 
-// VerifyJWT verifies JWT token từ Moodle sử dụng JWKS
-func VerifyJWT(tokenString string) (map[string]interface{}, error) {
-	// Fetch JWKS từ Moodle
-	keySet, err := jwk.Fetch(context.Background(), moodleJWKSURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", moodleJWKSURL, err)
-	}
-
-	// Parse và verify JWT token
-	token, err := jwt.Parse([]byte(tokenString), jwt.WithKeySet(keySet))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse and verify JWT: %w", err)
-	}
+// ErrJWKSUnavailable is returned by VerifyIDToken when the platform's keys
+// could not be fetched
+var ErrJWKSUnavailable = errors.New("failed to fetch JWKS")
 
-	// Convert token claims to map
-	claims := make(map[string]interface{})
-	for key, value := range token.PrivateClaims() {
-		claims[key] = value
-	}
+// VerifyIDToken verifies an LTI id_token against the JWKS published at
+// jwksURL and decodes its claims. The key set comes from the platform key
+// cache and is fetched again when the token names a key it does not have.
+func VerifyIDToken(ctx context.Context, idToken, jwksURL string) (*lti.Claims, error) {
+	kid, _ := lti.PeekKeyID(idToken) // token hỏng: ParseIDToken báo lỗi
 
-	// Add standard claims
-	if iss := token.Issuer(); iss != "" {
-		claims["iss"] = iss
-	}
-	if sub := token.Subject(); sub != "" {
-		claims["sub"] = sub
-	}
-	if aud := token.Audience(); len(aud) > 0 {
-		claims["aud"] = aud
-	}
-	if exp := token.Expiration(); !exp.IsZero() {
-		claims["exp"] = exp.Unix()
-	}
-	if iat := token.IssuedAt(); !iat.IsZero() {
-		claims["iat"] = iat.Unix()
-	}
-	if nbf := token.NotBefore(); !nbf.IsZero() {
-		claims["nbf"] = nbf.Unix()
-	}
-	if jti := token.JwtID(); jti != "" {
-		claims["jti"] = jti
-	}
-
-	return claims, nil
-}
-
-// VerifyJWTWithKey verifies JWT với specific key (for testing)
-func VerifyJWTWithKey(tokenString string, key interface{}) (map[string]interface{}, error) {
-	token, err := jwt.Parse([]byte(tokenString), jwt.WithKey(jwa.RS256, key))
+	ctx, span := tracing.Start(ctx, "jwks.get", attribute.String("url.full", jwksURL), attribute.String("jwks.kid", kid))
+	keySet, refreshed, err := platformKeySet(ctx, jwksURL, kid)
+	span.SetAttributes(attribute.Bool("jwks.refreshed", refreshed))
+	tracing.End(span, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse and verify JWT with key: %w", err)
-	}
-
-	// Convert token claims to map
-	claims := make(map[string]interface{})
-	for key, value := range token.PrivateClaims() {
-		claims[key] = value
-	}
-
-	// Add standard claims
-	if iss := token.Issuer(); iss != "" {
-		claims["iss"] = iss
-	}
-	if sub := token.Subject(); sub != "" {
-		claims["sub"] = sub
-	}
-	if aud := token.Audience(); len(aud) > 0 {
-		claims["aud"] = aud
-	}
-	if exp := token.Expiration(); !exp.IsZero() {
-		claims["exp"] = exp.Unix()
-	}
-	if iat := token.IssuedAt(); !iat.IsZero() {
-		claims["iat"] = iat.Unix()
+		return nil, fmt.Errorf("%w from %s: %w", ErrJWKSUnavailable, jwksURL, err)
 	}
 
-	return claims, nil
+	return lti.ParseIDToken(idToken, keySet)
 }
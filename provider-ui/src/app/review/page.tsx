@@ -0,0 +1,93 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import { useSearchParams } from "next/navigation";
+import { useLTIContext } from "@/hooks/useLTIContext";
+import { Alert, AlertDescription } from "@/components/ui/alert";
+import { Card } from "@/components/ui/card";
+import { ReviewResponse } from "@/types";
+
+// Submission review: opened from the gradebook (LtiSubmissionReviewRequest)
+export default function Review() {
+  const { context, error } = useLTIContext();
+  const searchParams = useSearchParams();
+  const selected = searchParams.get("submission");
+  const [review, setReview] = useState<ReviewResponse | null>(null);
+  const [loadError, setLoadError] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (!context?.session) return;
+
+    fetch("/api/review", {
+      headers: { Authorization: `Bearer ${context.session}` },
+    })
+      .then(async (res) => {
+        const data = await res.json().catch(() => null);
+        if (!res.ok) {
+          throw new Error(data?.error || `HTTP error! status: ${res.status}`);
+        }
+        setReview(data);
+      })
+      .catch((e) =>
+        setLoadError(e instanceof Error ? e.message : "Failed to load review")
+      );
+  }, [context]);
+
+  const message = error || loadError;
+  if (message || !review) {
+    return (
+      <div className="max-w-xl mx-auto py-12">
+        <Alert variant={message ? "destructive" : "default"}>
+          <AlertDescription>{message || "Loading submissions..."}</AlertDescription>
+        </Alert>
+      </div>
+    );
+  }
+
+  return (
+    <div className="max-w-2xl mx-auto py-12">
+      <h1 className="text-2xl font-bold mb-2">Submissions</h1>
+      <p className="text-sm text-gray-600 dark:text-gray-400 mb-8">
+        Learner: {review.for_user} • {review.submissions.length} attempt(s)
+      </p>
+
+      {review.submissions.length === 0 && (
+        <Alert>
+          <AlertDescription>No submissions yet.</AlertDescription>
+        </Alert>
+      )}
+
+      <div className="space-y-4">
+        {review.submissions.map((s) => (
+          <Card
+            key={s.id}
+            className={`p-4 ${s.id === selected ? "border-blue-500" : ""}`}
+          >
+            <div className="flex justify-between text-sm mb-2">
+              <span>
+                {new Date(s.created_at).toLocaleString()} • {s.language}
+              </span>
+              <span>
+                {s.status === "completed"
+                  ? `${s.score.toFixed(2)} / ${s.max_score}`
+                  : s.status}
+                {s.late_penalty ? ` (late -${s.late_penalty}%)` : ""}
+              </span>
+            </div>
+            {s.tests && (
+              <p className="text-xs text-gray-600 dark:text-gray-400 mb-2">
+                {s.tests.filter((t) => t.passed).length}/{s.tests.length} tests
+                passed
+              </p>
+            )}
+            {s.source && (
+              <pre className="text-xs bg-gray-100 dark:bg-gray-900 p-2 rounded overflow-x-auto">
+                {s.source}
+              </pre>
+            )}
+          </Card>
+        ))}
+      </div>
+    </div>
+  );
+}
@@ -13,6 +13,7 @@ import { Card } from "@/components/ui/card";
 import {
   LTIContext,
   ExecuteResponse,
+  RunResponse,
   SUPPORTED_LANGUAGES,
   SupportedLanguage,
 } from "@/types";
@@ -24,43 +25,80 @@ interface Props {
 export function CodeExecutor({ context }: Props) {
   const [code, setCode] = useState("");
   const [lang, setLang] = useState<SupportedLanguage>("python");
-  const [result, setResult] = useState<ExecuteResponse | null>(null);
-  const [loading, setLoading] = useState(false);
+  const [stdin, setStdin] = useState("");
+  const [result, setResult] = useState<ExecuteResponse | RunResponse | null>(
+    null
+  );
+  const [loading, setLoading] = useState<"run" | "submit" | null>(null);
   const [error, setError] = useState<string | null>(null);
 
-  async function handleSubmit() {
-    setLoading(true);
+  async function post(path: string, body: object) {
+    const res = await fetch(path, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        ...(context.session
+          ? { Authorization: `Bearer ${context.session}` }
+          : {}),
+      },
+      body: JSON.stringify(body),
+    });
+
+    const data = await res.json().catch(() => null);
+    if (!res.ok && !data?.error) {
+      throw new Error(`HTTP error! status: ${res.status}`);
+    }
+    return data;
+  }
+
+  // Practice run: own stdin + visible sample tests, not graded
+  async function handleRun() {
+    setLoading("run");
     setResult(null);
     setError(null);
 
     try {
-      const res = await fetch("/api/execute", {
-        method: "POST",
-        headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({
-          code,
-          language: lang,
-          user_id: context.user,
-          lineitem: context.lineitem,
-          id_token: context.id_token,
-          max_score: 100,
-        }),
+      const data: RunResponse = await post("/api/run", {
+        code,
+        language: lang,
+        stdin,
       });
+      setResult(data);
 
-      if (!res.ok) {
-        throw new Error(`HTTP error! status: ${res.status}`);
+      if (!data.success) {
+        setError(data.error || "Unknown error occurred");
       }
+    } catch (e) {
+      setError(e instanceof Error ? e.message : "Failed to run code");
+    } finally {
+      setLoading(null);
+    }
+  }
+
+  // Graded submit: all tests, counts as an attempt
+  async function handleSubmit() {
+    setLoading("submit");
+    setResult(null);
+    setError(null);
 
-      const data: ExecuteResponse = await res.json();
+    try {
+      const data: ExecuteResponse = await post("/api/submit", {
+        code,
+        language: lang,
+        user_id: context.user,
+        lineitem: context.lineitem,
+        id_token: context.id_token,
+        max_score: 100,
+      });
       setResult(data);
 
       if (!data.success) {
         setError(data.error || "Unknown error occurred");
       }
     } catch (e) {
-      setError(e instanceof Error ? e.message : "Failed to execute code");
+      setError(e instanceof Error ? e.message : "Failed to submit code");
     } finally {
-      setLoading(false);
+      setLoading(null);
     }
   }
 
@@ -83,8 +121,16 @@ export function CodeExecutor({ context }: Props) {
           </SelectContent>
         </Select>
 
-        <Button onClick={handleSubmit} disabled={loading || !code}>
-          {loading ? "Running..." : "Run Code"}
+        <Button
+          variant="outline"
+          onClick={handleRun}
+          disabled={loading !== null || !code}
+        >
+          {loading === "run" ? "Running..." : "Run"}
+        </Button>
+
+        <Button onClick={handleSubmit} disabled={loading !== null || !code}>
+          {loading === "submit" ? "Submitting..." : "Submit"}
         </Button>
       </div>
 
@@ -95,6 +141,13 @@ export function CodeExecutor({ context }: Props) {
         placeholder={`Enter your ${lang} code here...`}
       />
 
+      <Textarea
+        className="min-h-[60px] font-mono text-sm"
+        value={stdin}
+        onChange={(e) => setStdin(e.target.value)}
+        placeholder="Custom input (stdin) for Run"
+      />
+
       {error && (
         <Alert variant="destructive">
           <AlertDescription>{error}</AlertDescription>
@@ -129,14 +182,30 @@ export function CodeExecutor({ context }: Props) {
               </pre>
             </div>
           )}
+        </Card>
+      )}
 
-          {typeof result.score === "number" && (
-            <div className="text-sm text-gray-600 dark:text-gray-400">
-              Score: {result.score}/100
+      {result?.tests && result.tests.length > 0 && (
+        <Card className="p-4 space-y-2">
+          <h3 className="font-semibold">Tests:</h3>
+          {result.tests.map((t) => (
+            <div key={t.test_case_id} className="text-sm">
+              {t.passed ? "✅" : "❌"} {t.name || t.test_case_id}
+              {t.hidden ? " (hidden)" : ""} — {t.status.description}
             </div>
-          )}
+          ))}
         </Card>
       )}
+
+      {result && "score" in result && typeof result.score === "number" && (
+        <div className="text-sm text-gray-600 dark:text-gray-400">
+          Score: {result.score}
+          {result.late_penalty ? ` (late penalty ${result.late_penalty}%)` : ""}
+          {typeof result.grade === "number" && ` · Grade: ${result.grade}`}
+          {typeof result.attempts_left === "number" &&
+            ` · Attempts left: ${result.attempts_left}`}
+        </div>
+      )}
     </div>
   );
 }
@@ -20,6 +20,8 @@ export function useLTIContext() {
 
       // Get optional parameters
       const lineitem = searchParams.get("lineitem") || undefined;
+      const session = searchParams.get("session") || undefined;
+      const role = (searchParams.get("role") || undefined) as LTIContext["role"];
 
       // Set context
       setContext({
@@ -27,6 +29,8 @@ export function useLTIContext() {
         user,
         context: contextId,
         lineitem,
+        session,
+        role,
       });
     } catch (e) {
       setError(e instanceof Error ? e.message : "Failed to get LTI context");
@@ -3,6 +3,8 @@ export interface LTIContext {
   user: string;
   context: string;
   lineitem?: string;
+  session?: string;
+  role?: "instructor" | "teaching_assistant" | "learner";
 }
 
 export interface ExecuteRequest {
@@ -30,10 +32,42 @@ export interface Judge0Response {
   memory?: number;
 }
 
+export interface TestResult {
+  test_case_id: string;
+  name?: string;
+  hidden?: boolean;
+  passed: boolean;
+  status: {
+    id: number;
+    description: string;
+  };
+  stdout?: string;
+  stderr?: string;
+  compile_output?: string;
+}
+
 export interface ExecuteResponse {
   success: boolean;
+  submission_id?: string;
   result?: Judge0Response;
+  tests?: TestResult[];
   score?: number;
+  late_penalty?: number;
+  grade?: number;
+  attempts_left?: number;
+  error?: string;
+}
+
+export interface RunRequest {
+  code: string;
+  language: string;
+  stdin?: string;
+}
+
+export interface RunResponse {
+  success: boolean;
+  result?: Judge0Response;
+  tests?: TestResult[];
   error?: string;
 }
 
@@ -47,3 +81,22 @@ export const SUPPORTED_LANGUAGES = [
 ] as const;
 
 export type SupportedLanguage = (typeof SUPPORTED_LANGUAGES)[number]["value"];
+
+export interface SubmissionRecord {
+  id: string;
+  user_id: string;
+  source?: string;
+  language: string;
+  status: "pending" | "completed" | "failed";
+  grading_progress?: string;
+  tests?: TestResult[];
+  score: number;
+  max_score: number;
+  late_penalty?: number;
+  created_at: string;
+}
+
+export interface ReviewResponse {
+  for_user: string;
+  submissions: SubmissionRecord[];
+}